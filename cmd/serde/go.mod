@@ -19,3 +19,5 @@ require (
 	golang.org/x/net v0.31.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
 )
+
+replace github.com/pktgen/go-pktgen/internal/fserde => ../../internal/fserde
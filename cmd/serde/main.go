@@ -25,9 +25,12 @@ type SerdeTool struct {
 	buildDate string             // Build date of tool
 	serde     *fserde.FrameSerde // pointer to fserde.FrameSerde structure
 	tomlData  struct {
-		OutputPcapFile string  `toml:"pcap-output-file"`
-		Packets        []frame `toml:"Packets"`
-		Defaults       []frame `toml:"Defaults"`
+		OutputPcapFile string              `toml:"pcap-output-file"`
+		Packets        []frame             `toml:"Packets"`
+		Defaults       []frame             `toml:"Defaults"`
+		Vars           map[string]string   `toml:"Vars"`
+		PortVars       []map[string]string `toml:"Ports"`
+		MTU            int                 `toml:"mtu"`
 	}
 }
 
@@ -102,7 +105,12 @@ func main() {
 				for _, p := range serdeTool.tomlData.Defaults {
 					packets = append(packets, p.data)
 				}
-				defs := &fserde.FrameSerdeConfig{Defaults: packets}
+				defs := &fserde.FrameSerdeConfig{
+					Defaults: packets,
+					Vars:     serdeTool.tomlData.Vars,
+					PortVars: serdeTool.tomlData.PortVars,
+					MTU:      serdeTool.tomlData.MTU,
+				}
 				if fg, err := fserde.Create("Convert", defs); err != nil {
 					fmt.Printf("*** create failed %v\n", err)
 					os.Exit(1)
@@ -0,0 +1,301 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// The ARP() protocol layer is an Ethernet/IPv4 ARP packet following the Ether() layer,
+// i.e., Ether()/ARP(op=request, spa=10.0.0.1, tpa=10.0.0.2)
+//
+// op - is the operation request, reply or the operation code, defaults to request.
+// sha, tha - are the sender and target hardware addresses, sha defaults to the
+//            Ether source MAC address.
+// spa, tpa - are the sender and target IPv4 addresses.
+// gratuitous - announces the sender address, the target IPv4 address is set to the
+//              sender IPv4 address and a reply also sets the target hardware address
+//              to the sender hardware address.
+//
+// The Ether EtherType defaults to 0x0806 and the Ether destination MAC address defaults
+// to the broadcast address for requests and gratuitous ARPs, or the target hardware
+// address for replies.
+
+const (
+	ARPHeaderLen      = 28
+	ARPHardwareEther  = 1 // Ethernet hardware type
+	ARPOpRequest      = 1
+	ARPOpReply        = 2
+	ARPProtocolIPv4   = EtherTypeIPv4
+	ARPProtocolAddLen = net.IPv4len
+)
+
+type ARPHdr struct {
+	Op       uint16           // Operation code
+	SenderHW net.HardwareAddr // Sender hardware address
+	SenderIP net.IP           // Sender IPv4 address
+	TargetHW net.HardwareAddr // Target hardware address
+	TargetIP net.IP           // Target IPv4 address
+}
+
+type ARPLayer struct {
+	hdr        *LayerHdr
+	arpHdr     ARPHdr
+	gratuitous bool // Gratuitous ARP
+}
+
+func (l *ARPLayer) String() string {
+	h := l.arpHdr
+
+	op := strconv.Itoa(int(h.Op))
+	switch h.Op {
+	case ARPOpRequest:
+		op = "request"
+	case ARPOpReply:
+		op = "reply"
+	}
+
+	s := fmt.Sprintf("%s(op=%s, sha=%v, spa=%v, tha=%v, tpa=%v", l.Name(), op,
+		h.SenderHW, h.SenderIP, h.TargetHW, h.TargetIP)
+	if l.gratuitous {
+		s += ", gratuitous=true"
+	}
+	return s + ")"
+}
+
+func ARPNew(fr *Frame) *ARPLayer {
+	return &ARPLayer{
+		hdr: LayerConstructor(fr, LayerARP, LayerARPType),
+	}
+}
+
+func (l *ARPLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func (l *ARPLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "op":
+			switch val {
+			case "request":
+				l.arpHdr.Op = ARPOpRequest
+			case "reply":
+				l.arpHdr.Op = ARPOpReply
+			default:
+				if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+					return fmt.Errorf("invalid arp op: %s", val)
+				} else {
+					l.arpHdr.Op = uint16(v)
+				}
+			}
+		case "sha", "tha":
+			mac, err := ToHardwareAddr(val)
+			if err != nil {
+				return err
+			}
+			if key == "sha" {
+				l.arpHdr.SenderHW = mac
+			} else {
+				l.arpHdr.TargetHW = mac
+			}
+		case "spa", "tpa":
+			ip := net.ParseIP(val).To4()
+			if ip == nil {
+				return fmt.Errorf("invalid arp %s address: %s", key, val)
+			}
+			if key == "spa" {
+				l.arpHdr.SenderIP = ip
+			} else {
+				l.arpHdr.TargetIP = ip
+			}
+		case "gratuitous":
+			switch val {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				l.gratuitous = true
+			case "off", "no", "false", "disable", "disabled", "0":
+				l.gratuitous = false
+			default:
+				return fmt.Errorf("gratuitous invalid value: %s", val)
+			}
+		default:
+			return fmt.Errorf("unknown arp option: [%s]", opt)
+		}
+	}
+
+	h := &l.arpHdr
+	if isZeroMac(h.SenderHW) {
+		h.SenderHW = make(net.HardwareAddr, HardwareAddrLen)
+	}
+	if isZeroMac(h.TargetHW) {
+		h.TargetHW = make(net.HardwareAddr, HardwareAddrLen)
+	}
+	if isIPZero(h.SenderIP) {
+		h.SenderIP = net.IPv4zero
+	}
+	if isIPZero(h.TargetIP) {
+		h.TargetIP = net.IPv4zero
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = ARPHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *ARPLayer) ApplyDefaults() error {
+
+	// The addresses and the Ether header are filled in after the Ether layer has
+	// applied the default frame values.
+	defer l.applyEther()
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerARP, l.hdr.index).(*ARPLayer)
+	if !ok {
+		return nil
+	}
+
+	h, dh := &l.arpHdr, &dl.arpHdr
+	if h.Op == 0 && dh.Op != 0 {
+		h.Op = dh.Op
+	}
+	if isZeroMac(h.SenderHW) && !isZeroMac(dh.SenderHW) {
+		h.SenderHW = dh.SenderHW
+	}
+	if isIPZero(h.SenderIP) && !isIPZero(dh.SenderIP) {
+		h.SenderIP = dh.SenderIP
+	}
+	if isZeroMac(h.TargetHW) && !isZeroMac(dh.TargetHW) {
+		h.TargetHW = dh.TargetHW
+	}
+	if isIPZero(h.TargetIP) && !isIPZero(dh.TargetIP) {
+		h.TargetIP = dh.TargetIP
+	}
+	if !l.gratuitous && dl.gratuitous {
+		l.gratuitous = true
+	}
+
+	return nil
+}
+
+// applyEther sets the ARP operation and addresses not given, then sets the destination
+// MAC address of the Ether layer in front of the ARP layer.
+func (l *ARPLayer) applyEther() {
+
+	h := &l.arpHdr
+	ether, _ := l.hdr.fr.outerLayer(&l.hdr.proto, LayerEther).(*EtherLayer)
+
+	if h.Op == 0 {
+		h.Op = ARPOpRequest
+	}
+	if isZeroMac(h.SenderHW) && ether != nil {
+		h.SenderHW = ether.ether.SrcMac
+	}
+	if l.gratuitous {
+		h.TargetIP = h.SenderIP
+		if h.Op == ARPOpReply {
+			h.TargetHW = h.SenderHW
+		}
+	}
+
+	if ether == nil {
+		return
+	}
+	if isZeroMac(ether.ether.DstMac) {
+		if h.Op == ARPOpRequest || l.gratuitous {
+			ether.ether.DstMac = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+		} else if !isZeroMac(h.TargetHW) {
+			ether.ether.DstMac = h.TargetHW
+		}
+	}
+}
+
+func (l *ARPLayer) WriteLayer() error {
+
+	h := &l.arpHdr
+	data := l.hdr.fr.frame
+
+	data.Append(uint16(ARPHardwareEther))
+	data.Append(uint16(ARPProtocolIPv4))
+	data.Append(uint8(HardwareAddrLen))
+	data.Append(uint8(ARPProtocolAddLen))
+	data.Append(h.Op)
+	data.Append(h.SenderHW)
+	data.Append(h.SenderIP)
+	data.Append(h.TargetHW)
+	data.Append(h.TargetIP)
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains an Ethernet/IPv4 ARP packet. The
+// sender hardware address must be set when the Ether source MAC address is set and the
+// Ether destination MAC address must be set, as these are filled in when not given.
+func (l *ARPLayer) decodeValid(data []byte) bool {
+
+	if len(data) < ARPHeaderLen {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[0:]) != ARPHardwareEther || binary.BigEndian.Uint16(data[2:]) != ARPProtocolIPv4 ||
+		data[4] != HardwareAddrLen || data[5] != ARPProtocolAddLen || binary.BigEndian.Uint16(data[6:]) == 0 {
+		return false
+	}
+
+	ether, ok := l.hdr.fr.outerLayer(nil, LayerEther).(*EtherLayer)
+	if !ok || isZeroMac(ether.ether.DstMac) {
+		return false
+	}
+	return !isZeroMac(data[8:14]) || isZeroMac(ether.ether.SrcMac)
+}
+
+// Decode the ARP packet from the binary frame data.
+func (l *ARPLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < ARPHeaderLen {
+		return 0, fmt.Errorf("arp packet too short: %d bytes", len(data))
+	}
+
+	h := &l.arpHdr
+	h.Op = binary.BigEndian.Uint16(data[6:])
+	h.SenderHW = net.HardwareAddr(bytes.Clone(data[8:14]))
+	h.SenderIP = net.IP(bytes.Clone(data[14:18]))
+	h.TargetHW = net.HardwareAddr(bytes.Clone(data[18:24]))
+	h.TargetIP = net.IP(bytes.Clone(data[24:28]))
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = ARPHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return ARPHeaderLen, nil
+}
@@ -12,7 +12,10 @@ package fserde
 
 import (
 	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"net"
+	"strconv"
 
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
@@ -34,6 +37,50 @@ func SwapIPv4Addr(x net.IP) net.IP {
 	return net.IPv4(b[0], b[1], b[2], b[3])
 }
 
+// checksumOverride is a checksum given in the frame string to build a malformed frame, the
+// checksum is written as a bad checksum or a fixed value in place of the computed checksum.
+type checksumOverride struct {
+	set   bool   // Checksum is overridden
+	bad   bool   // Checksum is made invalid
+	value uint16 // Checksum value when not bad
+}
+
+// parseChecksumOverride parses the checksum option value of bad or a 16-bit value.
+func parseChecksumOverride(val string) (checksumOverride, error) {
+
+	if val == "bad" {
+		return checksumOverride{set: true, bad: true}, nil
+	}
+	v, err := strconv.ParseUint(val, 0, 16)
+	if err != nil {
+		return checksumOverride{}, fmt.Errorf("checksum invalid value: %s", val)
+	}
+	return checksumOverride{set: true, value: uint16(v)}, nil
+}
+
+// checksum returns the checksum to write in place of the computed checksum. The bad checksum
+// is the computed checksum plus one, skipping 0 and 0xffff as both are a zero sum.
+func (c checksumOverride) checksum(cksum uint16) uint16 {
+
+	switch {
+	case !c.set:
+		return cksum
+	case !c.bad:
+		return c.value
+	}
+	if cksum++; cksum == 0 || cksum == 0xffff {
+		cksum = 1
+	}
+	return cksum
+}
+
+func (c checksumOverride) String() string {
+	if c.bad {
+		return "bad"
+	}
+	return fmt.Sprintf("%#04x", c.value)
+}
+
 func reduceChecksum(sum uint32) uint16 {
 	sum = (sum >> 16) + (sum & 0xffff)
 	sum += (sum >> 16)
@@ -74,7 +121,7 @@ func IPv4HeaderChecksum(hdr *ipv4.Header) uint16 {
 	arr[0] = uint16(hl)<<8 | uint16(hdr.TOS)
 	arr[1] = uint16(hdr.TotalLen)
 	arr[2] = uint16(hdr.ID)
-	arr[3] = uint16(hdr.FragOff)
+	arr[3] = uint16(hdr.Flags<<13) | uint16(hdr.FragOff)
 	arr[4] = uint16(uint16(hdr.TTL<<8) | uint16(hdr.Protocol))
 	arr[5] = uint16(0) // Checksum
 	arr[6] = uint16(src >> 16)
@@ -182,7 +229,7 @@ func IPv4TCPChecksum(ip *ipv4.Header, tcp *TCPHdr, data []byte) uint16 {
 	arr := make([]uint16, 12)
 
 	// Create pseudo-header for TCP/IPv4
-	sum := PseudoHdrIPv4Checksum(ip.Src, ip.Dst, ip.Protocol, tcp.HdrLen+uint16(len(data)))
+	sum := PseudoHdrIPv4Checksum(ip.Src, ip.Dst, ip.Protocol, TCPHeaderLen+uint16(len(data)))
 
 	// TCP header
 	arr[2] = uint16(tcp.SrcPort)
@@ -242,16 +289,18 @@ func IPv6AddrChecksum(ip *ipv6.Header) uint32 {
 
 // IPv6UDPChecksum calculates UDP checksum for case if L3 protocol is IPv6.
 func IPv6UDPChecksum(ip *ipv6.Header, udp *UDPHdr, data []byte) uint16 {
-	dataLength := SwapUint16(uint16(ip.PayloadLen))
 
-	sum := dataChecksum(data, int(dataLength-UDPDefaultLen))
+	// Create pseudo-header for UDP/IPv6
+	sum := IPv6AddrChecksum(ip) +
+		uint32(udp.Length) +
+		uint32(ip.NextHeader)
 
-	sum += IPv6AddrChecksum(ip) +
-		uint32(SwapUint16(udp.Length)) +
-		uint32(ip.NextHeader) +
-		uint32(SwapUint16(udp.SrcPort)) +
-		uint32(SwapUint16(udp.DstPort)) +
-		uint32(SwapUint16(udp.Length))
+	// UDP header
+	sum += uint32(udp.SrcPort) +
+		uint32(udp.DstPort) +
+		uint32(udp.Length)
+
+	sum += dataChecksum(data, len(data))
 
 	retSum := ^reduceChecksum(sum)
 	// If the checksum calculation results in the value zero (all 16 bits 0) it
@@ -263,46 +312,62 @@ func IPv6UDPChecksum(ip *ipv6.Header, udp *UDPHdr, data []byte) uint16 {
 }
 
 // IPv6TCPChecksum calculates TCP checksum for case if L3 protocol is IPv6.
+// Here data pointer should point to end of minimal TCP header because we
+// consider TCP options as part of data.
 func IPv6TCPChecksum(ip *ipv6.Header, tcp *TCPHdr, data []byte) uint16 {
-	dataLength := SwapUint16(uint16(ip.PayloadLen))
 
-	sum := dataChecksum(data, int(dataLength-TCPMinLen))
+	// Create pseudo-header for TCP/IPv6
+	sum := IPv6AddrChecksum(ip) +
+		uint32(TCPHeaderLen+len(data)) +
+		uint32(ip.NextHeader)
 
-	sum += IPv6AddrChecksum(ip) +
-		uint32(dataLength) +
-		uint32(ip.NextHeader) +
-		TCPChecksum(tcp)
+	// TCP header
+	sum += uint32(tcp.SrcPort) +
+		uint32(tcp.DstPort) +
+		tcp.SeqNum>>16 + tcp.SeqNum&0xFFFF +
+		tcp.AckNum>>16 + tcp.AckNum&0xFFFF +
+		uint32(((tcp.HdrLen>>2)<<12)|tcp.Flags) +
+		uint32(tcp.Window) +
+		uint32(tcp.Urgent)
 
-	return ^reduceChecksum(sum)
+	sum += dataChecksum(data, len(data))
+
+	retSum := ^reduceChecksum(sum)
+	// If the checksum calculation results in the value zero (all 16 bits 0) it
+	// should be sent as the one's complement (all 1s).
+	if retSum == 0 {
+		retSum = ^retSum
+	}
+	return retSum
 }
 
 // IPv4ICMPChecksum calculates ICMP checksum in case if L3
-// protocol is IPv4.
+// protocol is IPv4. Here data pointer should point to end of the ICMP header.
 func IPv4ICMPChecksum(hdr *ipv4.Header, icmp *ICMPHeader, data []byte) uint16 {
-	dataLength := SwapUint16(uint16(hdr.TotalLen)) - IPv4MinLen - ICMPv4MinLen
 
 	sum := uint32(uint16(icmp.Type)<<8|uint16(icmp.Code)) +
-		uint32(SwapUint16(icmp.Identifier)) +
-		uint32(SwapUint16(icmp.SeqNum)) +
-		dataChecksum(data, int(dataLength))
+		uint32(icmp.Identifier) +
+		uint32(icmp.SeqNum) +
+		dataChecksum(data, len(data))
 
 	return ^reduceChecksum(sum)
 }
 
 // IPv6ICMPChecksum calculates ICMP checksum in case if L3 protocol is IPv6.
+// Here data pointer should point to end of the ICMP header.
 func IPv6ICMPChecksum(ip *ipv6.Header, icmp *ICMPHeader, data []byte) uint16 {
-	dataLength := SwapUint16(uint16(ip.PayloadLen))
 
-	// ICMP payload
-	sum := dataChecksum(data, int(dataLength-ICMPv6MinLen))
+	// Create pseudo-header for ICMP/IPv6
+	sum := IPv6AddrChecksum(ip) +
+		uint32(ICMPv6HeaderLen+len(data)) +
+		uint32(ProtocolICMPv6)
 
-	sum += IPv6AddrChecksum(ip) + // IPv6 Header
-		uint32(dataLength) +
-		uint32(ip.NextHeader) +
-		// ICMP header excluding checksum
-		uint32(uint16(icmp.Type)<<8|uint16(icmp.Code)) +
-		uint32(SwapUint16(icmp.Identifier)) +
-		uint32(SwapUint16(icmp.SeqNum))
+	// ICMP header excluding checksum
+	sum += uint32(uint16(icmp.Type)<<8|uint16(icmp.Code)) +
+		uint32(icmp.Identifier) +
+		uint32(icmp.SeqNum)
+
+	sum += dataChecksum(data, len(data))
 
 	return ^reduceChecksum(sum)
 }
@@ -355,3 +420,19 @@ func PseudoHdrIPv6UDPChecksum(hdr *ipv6.Header, udp *UDPHdr) uint16 {
 		uint32(SwapUint16(udp.Length))
 	return reduceChecksum(pHdrCksum)
 }
+
+var sctpCRC32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SCTPChecksum calculates the CRC32c checksum of the SCTP packet in data. The checksum
+// field of the SCTP common header is treated as zero.
+func SCTPChecksum(data []byte) uint32 {
+	var zero [4]byte
+
+	if len(data) < SCTPHeaderLen {
+		return 0
+	}
+
+	crc := crc32.Update(0, sctpCRC32cTable, data[:SCTPChecksumOffset])
+	crc = crc32.Update(crc, sctpCRC32cTable, zero[:])
+	return crc32.Update(crc, sctpCRC32cTable, data[SCTPChecksumOffset+4:])
+}
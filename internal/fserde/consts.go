@@ -4,25 +4,29 @@
 
 package fserde
 
-import (
-	"strings"
-)
-
 const (
 	EtherTypeIPv4   = 0x0800 // IPv4 EtherType value
 	EtherTypeIPv6   = 0x86dd // IPv6 EtherType value
 	EtherTypeARP    = 0x0806 // ARP EtherType value
+	EtherTypeTEB    = 0x6558 // Transparent Ethernet Bridging EtherType value
+	EtherTypeMPLS   = 0x8847 // MPLS unicast EtherType value
+	EtherTypeMPLSMc = 0x8848 // MPLS multicast EtherType value
 	Dot1QID         = 0x8100 // IEEE 802.1Q VLAN ID EtherType value
 	QinQID          = 0x88a8 // IEEE 802.1Q QinQ VLAN ID EtherType value
 	HardwareAddrLen = 6      // Length of hardware MAC address
 	MinPacketLen    = 60     // Minimum Ethernet frame length without FCS
-	MaxPacketLen    = 1514   // Maximum Ethernet frame length without FCS
+	FCSLen          = 4      // Length of the Ethernet frame check sequence
+	MinMTU          = 68     // Minimum MTU of the frames
+	DefaultMTU      = 1500   // Default MTU of the frames
+	JumboMTU        = 9216   // MTU of the jumbo frames
 	ProtocolUDP     = 17     // UDP protocol number
 	ProtocolTCP     = 6      // TCP protocol number
 	ProtocolIPv4    = 4      // IPv4 protocol number
 	ProtocolIPv6    = 41     // IPv6 protocol number
 	ProtocolICMPv4  = 1      // ICMPv4 protocol number
 	ProtocolICMPv6  = 58     // ICMPv6 protocol number
+	ProtocolSCTP    = 132    // SCTP protocol number
+	ProtocolGRE     = 47     // GRE protocol number
 )
 
 type LayerType int    // Layer type index value
@@ -34,48 +38,82 @@ const (
 	LayerDot1QType
 	LayerQinQType
 	LayerDot1ADType
+	LayerMPLSType
 	LayerIPv4Type
 	LayerIPv6Type
+	LayerARPType
 	LayerTCPType
 	LayerUDPType
 	LayerICMPv4Type
 	LayerICMPv6Type
 	LayerSCTPType
+	LayerSCTPInitType
+	LayerSCTPHeartbeatType
+	LayerSCTPDataType
 	LayerVxLanType
+	LayerGREType
+	LayerNVGREType
+	LayerGeneveType
 	LayerEchoType
 	LayerTSCType
 	LayerPayloadType
 	LayerDefaultsType
 	LayerCountType
+	LayerIMIXType
+	LayerTruncateType
+	LayerFragmentType
+	LayerVlanType
+	LayerRawType
+	LayerPatchType
 	MaxLayerType
 )
 
 // String returns the string representation of the layer name string
 func (l LayerType) String() LayerName {
-	return LayerNames[l]
+	if l >= 0 && int(l) < len(LayerNames) {
+		return LayerNames[l]
+	}
+	if e := lookupLayerType(l); e != nil {
+		return e.name
+	}
+	return LayerName("LayerName-Unknown")
 }
 
 const (
 	// These strings are used for displaying layer names.
 	// Must match the order of the layers above.
-	LayerEther    LayerName = "Ether"
-	LayerDot1Q    LayerName = "Dot1Q"
-	LayerQinQ     LayerName = "QinQ"
-	LayerDot1AD   LayerName = "Dot1AD"
-	LayerIPv4     LayerName = "IPv4"
-	LayerIPv6     LayerName = "IPv6"
-	LayerTCP      LayerName = "TCP"
-	LayerUDP      LayerName = "UDP"
-	LayerICMPv4   LayerName = "ICMPv4"
-	LayerICMPv6   LayerName = "ICMPv6"
-	LayerSCTP     LayerName = "SCTP"
-	LayerVxLan    LayerName = "VxLan"
-	LayerEcho     LayerName = "Echo"
-	LayerTSC      LayerName = "TSC"
-	LayerPayload  LayerName = "Payload"
-	LayerDefaults LayerName = "Defaults"
-	LayerCount    LayerName = "Count"
-	LayerDone     LayerName = "Done"
+	LayerEther         LayerName = "Ether"
+	LayerDot1Q         LayerName = "Dot1Q"
+	LayerQinQ          LayerName = "QinQ"
+	LayerDot1AD        LayerName = "Dot1AD"
+	LayerMPLS          LayerName = "MPLS"
+	LayerIPv4          LayerName = "IPv4"
+	LayerIPv6          LayerName = "IPv6"
+	LayerARP           LayerName = "ARP"
+	LayerTCP           LayerName = "TCP"
+	LayerUDP           LayerName = "UDP"
+	LayerICMPv4        LayerName = "ICMPv4"
+	LayerICMPv6        LayerName = "ICMPv6"
+	LayerSCTP          LayerName = "SCTP"
+	LayerSCTPInit      LayerName = "SCTPInit"
+	LayerSCTPHeartbeat LayerName = "SCTPHeartbeat"
+	LayerSCTPData      LayerName = "SCTPData"
+	LayerVxLan         LayerName = "VxLan"
+	LayerGRE           LayerName = "GRE"
+	LayerNVGRE         LayerName = "NVGRE"
+	LayerGeneve        LayerName = "Geneve"
+	LayerEcho          LayerName = "Echo"
+	LayerTSC           LayerName = "TSC"
+	LayerPayload       LayerName = "Payload"
+	LayerDefaults      LayerName = "Defaults"
+	LayerCount         LayerName = "Count"
+	LayerIMIX          LayerName = "IMIX"
+	LayerTruncate      LayerName = "Truncate"
+	LayerFragment      LayerName = "Fragment"
+	LayerVlan          LayerName = "Vlan"
+	LayerRaw           LayerName = "Raw"
+	LayerPatch         LayerName = "Patch"
+	LayerDone          LayerName = "Done"
 )
 
 var LayerNames = [...]LayerName{
@@ -83,39 +121,51 @@ var LayerNames = [...]LayerName{
 	LayerDot1Q,
 	LayerQinQ,
 	LayerDot1AD,
+	LayerMPLS,
 	LayerIPv4,
 	LayerIPv6,
+	LayerARP,
 	LayerTCP,
 	LayerUDP,
 	LayerICMPv4,
 	LayerICMPv6,
 	LayerSCTP,
+	LayerSCTPInit,
+	LayerSCTPHeartbeat,
+	LayerSCTPData,
 	LayerVxLan,
+	LayerGRE,
+	LayerNVGRE,
+	LayerGeneve,
 	LayerEcho,
 	LayerTSC,
 	LayerPayload,
 	LayerDefaults,
 	LayerCount,
+	LayerIMIX,
+	LayerTruncate,
+	LayerFragment,
+	LayerVlan,
+	LayerRaw,
+	LayerPatch,
 	LayerDone,
 }
 
-// layerTypeFromNames returns the layer type from the layer name string
+// layerTypeFromNames returns the layer type from the layer name string, the name is one
+// of the registered layers.
 func layerTypeFromName(name string) LayerType {
 
-	for i := range LayerNames {
-		if strings.EqualFold(name, string(LayerNames[i])) {
-			return LayerType(i)
-		}
+	if e := lookupLayer(name); e != nil {
+		return e.layerType
 	}
 	return MaxLayerType
 }
 
 // findLayerName returns the layer type from the layer name string
 func findLayerName(lName string) LayerName {
-	for i := range LayerNames {
-		if strings.EqualFold(lName, string(LayerNames[i])) {
-			return LayerNames[i]
-		}
+
+	if e := lookupLayer(lName); e != nil {
+		return e.name
 	}
 	return LayerName("LayerName-Unknown")
 }
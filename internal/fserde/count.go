@@ -22,7 +22,7 @@ func (l *CountLayer) String() string {
 	return fmt.Sprintf("%s(%d)", l.hdr.layerName, l.count)
 }
 
-// CountNew creates a new CountLayer and is called the NewFuncs structure.
+// CountNew creates a new CountLayer and is registered as the Count layer create function.
 func CountNew(fr *Frame) *CountLayer {
 	return &CountLayer{
 		hdr: LayerConstructor(fr, LayerCount, LayerCountType),
@@ -56,7 +56,7 @@ func (l *CountLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerCount).(*CountLayer)
+	dl, ok := d.GetLayerIndex(LayerCount, l.hdr.index).(*CountLayer)
 	if !ok {
 		return nil
 	}
@@ -45,6 +45,158 @@ The example defines the name of the frame "Frame1". The protocol-layers are:
 Ether, IPv4 and Payload. Each protocol-layer has a set of protocol-value pairs in a
 key=value format. Look into each protocol file for more information about each protocol.
 
+# Quoted strings and errors
+
+An option value containing the delimiter characters '/', ',', '=' or brackets must be a
+quoted string using ' or " characters, the escapes \\, \', \", \n, \r, \t and \xHH are
+allowed in a quoted string. A value may also be a list in brackets, i.e., flags=[SYN | ACK]
+or QinQ(Dot1q{vlan=1}, Dot1q{vlan=2}). The frame string may span more than one line.
+
+Frame1:=Ether(proto=0x800)/IPv4()/UDP()/Payload(string='GET /index.html, \'A\'\r\n')
+
+The errors of a frame string are returned as a *ParseError with the frame name plus the
+line and column of the error in the frame string.
+
+# Protocol fields
+
+The protocol fields giving the type of the following layer, i.e., the Ether EtherType,
+the IPv4 protocol, the IPv6 next header and the GRE or Geneve protocol type, are set from
+the following layer when the value is not given in the frame string. A value given in the
+frame string is used as is and Frame.Warnings() returns a warning when the value does not
+match the following layer.
+
+Frame1:=Ether()/Dot1Q(vlan=10)/IPv6()/UDP()
+
+# VLAN tags
+
+The Vlan(tpid=, pcp=, dei=, vid=) layer is a VLAN tag and is repeated to stack the tags to
+any depth. The TPID of a tag not given in the frame string is 0x88a8 when the tag is
+followed by another tag and 0x8100 for the inner most tag. The Dot1Q() and Dot1AD() layers
+are aliases of Vlan() with the TPID 0x8100 and 0x88a8, Frame.Warnings() returns a warning
+when a 0x88a8 tag follows a 0x8100 tag.
+
+Frame1:=Ether()/Vlan(vid=100)/Vlan(vid=200)/Vlan(vid=300)/IPv4()/UDP()
+
+# Repeated layers
+
+A protocol-layer may be given more than once in a frame to build tunnels, i.e., IP-in-IP
+Ether()/IPv4()/IPv4()/UDP(). The repeated layers are addressed by position, where index
+zero is the outer most layer, using the layer name with an index "IPv4[1]" or the
+Frame.GetLayerIndex() function. The lengths and checksums are computed for each
+encapsulation level and a layer takes the default values from the layer with the same
+index in the default frame.
+
+# Field modifiers
+
+An option value may be a modifier which changes the value for each of the frames
+produced by the Count(N) layer, the modifiers are a range "10.0.0.1..10.0.0.254 step 1",
+an increment "inc(00:11:22:33:44:00, 16)" or a random value "rand(1024, 65535)".
+
+Frame1:=Ether(proto=0x800)/IPv4(src=10.0.0.1..10.0.0.254)/UDP(sport=rand(1024,65535))/
+	Count(1000)
+
+Each frame is encoded with the modifier values, so the lengths and checksums are correct
+for every frame. The frames are returned by Frame.Variants() and written by WritePCAP().
+
+# Adding protocol layers
+
+A protocol-layer is added outside of this package with RegisterLayer(name, newFn), where
+newFn returns a new value implementing the Layer interface for the frame. The layer uses
+LayerConstructor() with the returned layer type, LayerHdr.AddProtocol() in Parse() to add
+the protocol length and Frame.Data() in WriteLayer() to append the layer data. The layer
+name can then be used in the frame strings like the protocol-layers of this package.
+
+# Frame sizes
+
+The Payload(framesize=N) option sets the payload length to give a frame of N bytes including
+the 4 byte FCS, or without the FCS using Payload(framesize=N, fcs=false). The IMIX() layer
+expands the frame into a weighted mix of frame sizes, using the simple IMIX 64:7, 594:4 and
+1518:1 or a list of <size>:<weight> values. The frames are returned by Frame.Variants().
+
+Frame1:=Ether()/IPv4()/UDP()/IMIX(64:7, 594:4, 1518:1)
+
+# FCS and jumbo frames
+
+The Ether(fcs=true) option appends the CRC32 FCS to the frame, the frame is padded to 60 bytes
+first, and WritePCAP() sets the FCS bits of the pcap link type for the frames with an FCS. The
+frame data following the Ether header and VLAN tags is at most the FrameSerdeConfig.MTU bytes,
+which defaults to DefaultMTU and is set to JumboMTU or another value for jumbo frames, the
+same limit applies to the frames decoded by BinaryToString(). The fcs option can not be used
+with the Truncate() layer, as the truncated frame would not end with the FCS the pcap file
+says the frame has.
+
+Frame1:=Ether(fcs=true)/IPv4()/UDP()/Payload(framesize=9234)
+
+# Payload data
+
+The Payload() layer data is zeros unless a fill value is given, fill=0xaa, fill16, fill32,
+fill64, string='text' or hex='deadbeef'. The generated data is random with a seed giving the
+same data each time, Payload(size=64, random, seed=7), or incrementing byte values starting
+at zero or the given value, Payload(size=64, inc8=0x10). The data may also be read from a
+file with Payload(file='blob.bin', offset=16, len=64), where len defaults to the rest of
+the file.
+
+# IPv4 options and fragments
+
+The IPv4(opts=[...]) option adds the header options eol, nop, ra{value=N}, rr{slots=N} and
+ts{slots=N, flag=F}, the options are padded to a multiple of 4 bytes and the header length
+includes the options. The Fragment(mtu=N) layer splits the outer IPv4 packet into fragments
+of at most N bytes, the fragments have the MF flag and offsets set and are returned by
+Frame.Variants() in order.
+
+Frame1:=Ether()/IPv4(opts=[ra, rr{slots=4}])/UDP()/Payload(size=3000)/Fragment(mtu=1500)
+
+# TCP options
+
+The TCP(options=[...]) option adds the TCP options eol, nop, mss=N, wscale=N, sackok,
+ts=(val,ecr) and sack=[(left,right), ...], the options are padded to a multiple of 4 bytes
+and the data offset includes the options.
+
+Frame1:=Ether()/IPv4()/TCP(flags=[syn], options=[mss=1460, sackok, ts=(1,0), nop, wscale=7])
+
+# Malformed frames
+
+The lengths and checksums are computed unless given in the frame string, which is used to
+build malformed frames to test the error handling of a device. The IPv4, UDP and TCP
+checksum=bad option writes an invalid checksum and checksum=0x1234 writes the given value.
+The IPv4(totallen=N) and UDP(len=N) options write the given length, Ether(fcs=bad) appends
+an invalid FCS and Truncate(N) cuts the frame to N bytes after the frame is encoded.
+
+Frame1:=Ether(fcs=bad)/IPv4(checksum=bad)/UDP(len=200)/Payload(size=100)
+Frame2:=Ether()/IPv4()/UDP()/Payload(size=100)/Truncate(64)
+
+# Raw bytes and patches
+
+The Raw(hex='...') layer inserts the given bytes as a protocol of its own, i.e., a header not
+known to this package, and the bytes are included in the lengths and checksums of the outer
+layers. The Patch(offset=N, hex='...') layer writes the bytes over the frame data after the
+lengths and checksums are written, the checksums are computed again from the patched frame
+data with Patch(offset=N, hex='...', recompute=true).
+
+Frame1:=Ether()/IPv4()/UDP(dport=7777)/Raw(hex='01 02 00 10')/Payload(size=16)/
+	Patch(offset=26, hex='0a000009', recompute=true)
+
+# Frame variables
+
+The frame strings may reference the FrameSerdeConfig.Vars variables as ${NAME} and the
+FrameSerdeConfig.PortVars variables of port n as ${PORT<n>_NAME}, the references are replaced
+with the values before the frame string is parsed. An integer, MAC or IP address value may be
+offset as ${NAME}+N or ${NAME}-N and a reference to an undefined variable is an error.
+
+Frame1:=Ether(dst=${PORT0_MAC})/IPv4(src=${BASE_IP}, dst=${BASE_IP}+3)/UDP()
+
+# Frame templates
+
+Frame.Compile() returns a Template of the frame data with the offsets and widths of the named
+fields, i.e., "IPv4.src", "UDP.sport" or "TSC.seq". Template.Render(dst, overrides...) copies
+the frame data into dst and writes the field values without allocating memory, the IPv4 and
+L4 checksums are updated incrementally and the VXLAN flow entropy source port and the FCS are
+computed again.
+
+	tmpl, _ := fr.Compile()
+	seq, _ := tmpl.Field("TSC.seq")
+	n, err := tmpl.Render(buf, Override{Field: seq, Value: 1})
+
 # Default frame-value format
 
 The API via the serde.Create(cfg FrameSerdeCfg) function is the main entry point to
@@ -4,57 +4,12 @@
 
 package fserde
 
-import (
-	"fmt"
-)
+// The Dot1AD() protocol layer is an alias of the Vlan() layer for the 802.1ad service tag
+// with the TPID 0x88a8, the options are the Vlan() options.
 
-type Dot1adLayer struct {
-	hdr *LayerHdr
-}
-
-func Dot1adNew(fr *Frame) *Dot1adLayer {
-	return &Dot1adLayer{
-		hdr: LayerConstructor(fr, LayerDot1AD, LayerDot1ADType),
-	}
-}
-
-func (l *Dot1adLayer) String() string {
-	return fmt.Sprintf("%s()", l.hdr.layerName)
-}
-
-func (d *Dot1adLayer) Name() LayerName {
-	return d.hdr.layerName
-}
-
-func (l *Dot1adLayer) Parse(opts string) error {
-
-	l.hdr.proto.name = l.Name()
-	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
-
-	l.hdr.fr.AddProtocol(&l.hdr.proto)
-
-	return nil
-}
-
-func (l *Dot1adLayer) ApplyDefaults() error {
-
-	d := l.hdr.fr.defaultsFrame
-	if d == nil {
-		return nil
-	}
-
-	dl, ok := d.GetLayer(LayerDot1AD).(*Dot1adLayer)
-	if !ok {
-		return nil
+func Dot1adNew(fr *Frame) *VlanLayer {
+	return &VlanLayer{
+		hdr:       LayerConstructor(fr, LayerDot1AD, LayerDot1ADType),
+		aliasTPID: QinQID,
 	}
-
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
-
-	return nil
-}
-
-func (l *Dot1adLayer) WriteLayer() error {
-
-	return nil
 }
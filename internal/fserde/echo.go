@@ -38,7 +38,7 @@ func (l *EchoLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerEcho).(*EchoLayer)
+	dl, ok := d.GetLayerIndex(LayerEcho, l.hdr.index).(*EchoLayer)
 	if !ok {
 		return nil
 	}
@@ -6,7 +6,9 @@ package fserde
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"net"
 	"strconv"
 	"strings"
@@ -16,7 +18,17 @@ import (
 // options may be specified.
 //
 // dst, src - are the destination and source MAC addresses.
-// [proto|ethertype] - is the EtherType value.
+// [proto|ethertype] - is the EtherType value, defaults to the EtherType of the layer
+//                     following the Ether layer and VLAN tags.
+// fcs - is true for the CRC32 of the frame, bad or a 32-bit value, the FCS is appended to
+//       the frame for the outer Ether layer, bad is the CRC32 of the frame with the bits
+//       inverted, 1 and 0 are true and false and fcs=0x1 is the value 1. The frame is
+//       padded to 60 bytes before the FCS is appended and the option can not be used with
+//       the Truncate() layer.
+
+const (
+	EtherHeaderLen = 14 // Length of the Ethernet header
+)
 
 type EtherHdr struct {
 	DstMac, SrcMac net.HardwareAddr
@@ -24,8 +36,13 @@ type EtherHdr struct {
 }
 
 type EtherLayer struct {
-	hdr   *LayerHdr
-	ether EtherHdr
+	hdr      *LayerHdr
+	ether    EtherHdr
+	protoSet bool   // EtherType was given in the options
+	fcsSet   bool   // FCS is appended to the frame
+	fcsCalc  bool   // FCS is the CRC32 of the frame
+	fcsBad   bool   // FCS is the CRC32 of the frame with the bits inverted
+	fcs      uint32 // FCS value when not bad
 }
 
 func ToHardwareAddr(mac string) (net.HardwareAddr, error) {
@@ -41,7 +58,15 @@ func ToHardwareAddr(mac string) (net.HardwareAddr, error) {
 }
 
 func (e *EtherLayer) String() string {
-	return fmt.Sprintf("Ether(dst=%v, src=%v, proto=%04x)", e.ether.DstMac, e.ether.SrcMac, e.ether.EtherType)
+	switch {
+	case e.fcsCalc:
+		return fmt.Sprintf("Ether(dst=%v, src=%v, proto=0x%04x, fcs=true)", e.ether.DstMac, e.ether.SrcMac, e.ether.EtherType)
+	case e.fcsBad:
+		return fmt.Sprintf("Ether(dst=%v, src=%v, proto=0x%04x, fcs=bad)", e.ether.DstMac, e.ether.SrcMac, e.ether.EtherType)
+	case e.fcsSet:
+		return fmt.Sprintf("Ether(dst=%v, src=%v, proto=0x%04x, fcs=%#08x)", e.ether.DstMac, e.ether.SrcMac, e.ether.EtherType, e.fcs)
+	}
+	return fmt.Sprintf("Ether(dst=%v, src=%v, proto=0x%04x)", e.ether.DstMac, e.ether.SrcMac, e.ether.EtherType)
 }
 
 func isZeroMac(mac net.HardwareAddr) bool {
@@ -63,7 +88,7 @@ func EtherNew(fr *Frame) *EtherLayer {
 // parseEther a formatted string into a byte array or frame.
 func (el *EtherLayer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
 
 	for _, opt := range options {
 
@@ -72,12 +97,12 @@ func (el *EtherLayer) Parse(opts string) error {
 			continue
 		}
 
-		kvp := strings.Split(opt, "=")
-		if len(kvp) != 2 {
-			return fmt.Errorf("option needs a key/value pair")
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
 		}
-		key := strings.ToLower(strings.TrimSpace(kvp[0]))
-		val := strings.ToLower(strings.TrimSpace(kvp[1]))
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
 
 		switch key {
 		case "dst":
@@ -99,6 +124,27 @@ func (el *EtherLayer) Parse(opts string) error {
 				return err
 			} else {
 				el.ether.EtherType = uint16(v)
+				el.protoSet = true
+			}
+
+		case "fcs":
+			if el.hdr.index != 0 {
+				return fmt.Errorf("fcs is only valid for the outer ether layer")
+			}
+			el.fcsSet, el.fcsCalc, el.fcsBad = true, false, false
+			switch val {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				el.fcsCalc = true
+			case "off", "no", "false", "disable", "disabled", "0":
+				el.fcsSet = false
+			case "bad":
+				el.fcsBad = true
+			default:
+				if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+					return fmt.Errorf("fcs invalid value: %s", val)
+				} else {
+					el.fcs = uint32(v)
+				}
 			}
 
 		default:
@@ -124,7 +170,7 @@ func (el *EtherLayer) Parse(opts string) error {
 
 	el.hdr.proto.name = el.Name()
 	el.hdr.proto.offset = el.hdr.fr.GetOffset(el.Name())
-	el.hdr.proto.length = 14
+	el.hdr.proto.length = EtherHeaderLen
 
 	el.hdr.fr.AddProtocol(&el.hdr.proto)
 
@@ -133,12 +179,17 @@ func (el *EtherLayer) Parse(opts string) error {
 
 func (l *EtherLayer) ApplyDefaults() error {
 
+	// The Truncate() layer would remove the FCS appended to the frame
+	if _, ok := l.hdr.fr.GetLayer(LayerTruncate).(*TruncateLayer); ok && l.fcsSet {
+		return fmt.Errorf("fcs can not be used with the %s layer", LayerTruncate)
+	}
+
 	d := l.hdr.fr.defaultsFrame
 	if d == nil {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerEther).(*EtherLayer)
+	dl, ok := d.GetLayerIndex(LayerEther, l.hdr.index).(*EtherLayer)
 	if !ok {
 		return nil
 	}
@@ -168,3 +219,65 @@ func (l *EtherLayer) WriteLayer() error {
 
 	return nil
 }
+
+// appendFCS appends the FCS to the frame data, the FCS is written in the byte order of the
+// CRC32 on the wire. The frame is padded to the minimum frame length first as the FCS is
+// the last four bytes of the frame on the wire.
+func (l *EtherLayer) appendFCS(b []byte) []byte {
+
+	if !l.fcsSet {
+		return b
+	}
+	if len(b) < MinPacketLen {
+		b = append(b, make([]byte, MinPacketLen-len(b))...)
+	}
+
+	fcs := l.fcs
+	switch {
+	case l.fcsCalc:
+		fcs = crc32.ChecksumIEEE(b)
+	case l.fcsBad:
+		fcs = ^crc32.ChecksumIEEE(b)
+	}
+	return binary.LittleEndian.AppendUint32(b, fcs)
+}
+
+// checkMTU returns an error when the frame data following the Ether header and the VLAN
+// tags is longer than the MTU of the frame-serde.
+func (l *EtherLayer) checkMTU(b []byte) error {
+
+	fr := l.hdr.fr
+
+	next := fr.nextProtocol(&l.hdr.proto)
+	for next != nil && isVlanLayer(next.name) {
+		next = fr.nextProtocol(next)
+	}
+	if next == nil || fr.serde == nil {
+		return nil
+	}
+
+	if n := len(b) - int(fr.protoOffset(next)); n > fr.serde.mtu {
+		return fmt.Errorf("frame has %d bytes following the ether header, more than the mtu %d", n, fr.serde.mtu)
+	}
+	return nil
+}
+
+// Decode the Ether header from the binary frame data.
+func (l *EtherLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < EtherHeaderLen {
+		return 0, fmt.Errorf("ether header too short: %d bytes", len(data))
+	}
+
+	l.ether.DstMac = net.HardwareAddr(bytes.Clone(data[0:HardwareAddrLen]))
+	l.ether.SrcMac = net.HardwareAddr(bytes.Clone(data[HardwareAddrLen : 2*HardwareAddrLen]))
+	l.ether.EtherType = binary.BigEndian.Uint16(data[2*HardwareAddrLen:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = EtherHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return EtherHeaderLen, nil
+}
@@ -0,0 +1,154 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/ipv4"
+)
+
+// Fragment() is a layer to split the outer IPv4 packet of the frame into fragments, each
+// fragment is at most mtu bytes of IPv4 header and data.
+//    e.g., Ether()/IPv4()/UDP()/Payload(size=3000)/Fragment(mtu=1500)
+//
+// The fragment data is a multiple of 8 bytes except for the last fragment, the fragments
+// have the MF flag set except for the last fragment and only the options with the copied
+// flag are in the fragments following the first fragment. The fragments are returned by
+// Frame.Variants() in order.
+
+const (
+	IPv4FragmentUnit = 8 // Fragment offsets are in units of 8 bytes
+)
+
+// FragmentLayer the structure holding the information on each layer.
+type FragmentLayer struct {
+	hdr *LayerHdr
+	mtu uint16
+}
+
+func (l *FragmentLayer) String() string {
+	return fmt.Sprintf("%s(mtu=%d)", l.hdr.layerName, l.mtu)
+}
+
+// FragmentNew creates a new FragmentLayer and is registered as the Fragment layer create function.
+func FragmentNew(fr *Frame) *FragmentLayer {
+	return &FragmentLayer{
+		hdr: LayerConstructor(fr, LayerFragment, LayerFragmentType),
+	}
+}
+
+// Name returns the name of the layer.
+func (l *FragmentLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// Parse parses the layer options string.
+func (l *FragmentLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "mtu":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.mtu = uint16(v)
+			}
+		default:
+			return fmt.Errorf("unknown fragment option: [%s]", opt)
+		}
+	}
+	if l.mtu == 0 {
+		return fmt.Errorf("fragment needs the mtu option")
+	}
+
+	return nil
+}
+
+// ApplyDefaults applies the default values for the layer.
+func (l *FragmentLayer) ApplyDefaults() error {
+
+	return nil
+}
+
+// WriteLayer writes the layer to hdr.frame []byte.
+func (l *FragmentLayer) WriteLayer() error {
+
+	return nil
+}
+
+// fragment returns the frame data of each fragment of the outer IPv4 packet, the frame is
+// not split when the IPv4 packet fits in the mtu.
+func (l *FragmentLayer) fragment() ([][]byte, error) {
+
+	fr := l.hdr.fr
+	ip, ok := fr.GetLayer(LayerIPv4).(*IPv4Layer)
+	if !ok {
+		return nil, fmt.Errorf("fragment needs an ipv4 layer")
+	}
+
+	b := fr.frame.Bytes()
+	off := int(fr.protoOffset(&ip.hdr.proto))
+	end := off + int(fr.protoLength(&ip.hdr.proto))
+	if end-off <= int(l.mtu) {
+		return [][]byte{bytes.Clone(b)}, nil
+	}
+
+	if ip.ipHdr.Flags&ipv4.DontFragment != 0 {
+		fr.warnings = append(fr.warnings, fmt.Sprintf("%s has the DF flag set and is fragmented for mtu %d",
+			ip.Name(), l.mtu))
+	}
+
+	copied := encodeIPv4Options(copiedIPv4Options(ip.options))
+	data := b[off+ip.ipHdr.Len : end]
+
+	frags := make([][]byte, 0)
+	for pos := 0; pos < len(data); {
+		hdr := ip.ipHdr
+		if pos > 0 {
+			hdr.Options = copied
+			hdr.Len = IPv4MinLen + len(copied)
+		}
+
+		size := (int(l.mtu) - hdr.Len) &^ (IPv4FragmentUnit - 1)
+		if size <= 0 {
+			return nil, fmt.Errorf("fragment mtu %d is too small for the %d byte ipv4 header", l.mtu, hdr.Len)
+		}
+		if pos+size >= len(data) {
+			size = len(data) - pos
+		} else {
+			hdr.Flags |= ipv4.MoreFragments
+		}
+		hdr.TotalLen = hdr.Len + size
+		hdr.FragOff = ip.ipHdr.FragOff + pos/IPv4FragmentUnit
+
+		f := make([]byte, 0, off+hdr.TotalLen)
+		f = append(f, b[:off]...)
+		f = append(f, ip.marshal(&hdr)...)
+		f = append(f, data[pos:pos+size]...)
+		frags = append(frags, f)
+
+		pos += size
+	}
+
+	return frags, nil
+}
@@ -6,6 +6,8 @@ package fserde
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 )
 
 // LayerHdr is the header for each layer containing common fields
@@ -13,68 +15,213 @@ type LayerHdr struct {
 	fr        *Frame    // Pointer to the frame that contains the layer
 	layerName LayerName // Name of the layer in display format.
 	layerType LayerType // Type of the layer LayerEtherType, LayerDot1QType, LayerQinQType, ...
+	index     int       // Index of the layer with the same name in the frame, zero is the outer most
 	proto     ProtoInfo // Protocol information for the layer.
 }
 
 // String returns a string representation of the layer header
 func (ln *LayerHdr) String() string {
-	return fmt.Sprintf("layerType=%+v, layerName=%v, proto=%+v, frame=%p",
-		ln.layerType, ln.layerName, ln.proto, ln.fr)
+	return fmt.Sprintf("layerType=%+v, layerName=%v, index=%d, proto=%+v, frame=%p",
+		ln.layerType, ln.layerName, ln.index, ln.proto, ln.fr)
 }
 
-// LayerConstructor is a function that creates a new layer header
+// LayerConstructor is a function that creates a new layer header, the index of the layer
+// is the number of layers with the same name already in the frame.
 func LayerConstructor(fr *Frame, layerName LayerName, layerType LayerType) *LayerHdr {
+	index := 0
+	if fr != nil {
+		index = fr.LayerCount(layerName)
+	}
 	return &LayerHdr{
 		fr:        fr,
 		layerName: layerName,
 		layerType: layerType,
+		index:     index,
 		proto:     ProtoInfo{},
 	}
 }
 
-// Structure to hold all of the layer create functions
-type NewFuncs struct {
-	countNewFn    func(fr *Frame) *CountLayer
-	dot1adNewFn   func(fr *Frame) *Dot1adLayer
-	dot1qNewFn    func(fr *Frame) *Dot1qLayer
-	echoNewFn     func(fr *Frame) *EchoLayer
-	etherNewFn    func(fr *Frame) *EtherLayer
-	icmpv4NewFn   func(fr *Frame) *ICMPv4Layer
-	icmpv6NewFn   func(fr *Frame) *ICMPv6Layer
-	ipv4NewFn     func(fr *Frame) *IPv4Layer
-	ipv6NewFn     func(fr *Frame) *IPv6Layer
-	payloadNewFn  func(fr *Frame) *PayloadLayer
-	qinqNewFn     func(fr *Frame) *QinQLayer
-	sctpNewFn     func(fr *Frame) *SCTPLayer
-	tcpNewFn      func(fr *Frame) *TCPLayer
-	tscNewFn      func(fr *Frame) *TSCLayer
-	udpNewFn      func(fr *Frame) *UDPLayer
-	vxlanNewFn    func(fr *Frame) *VxLanLayer
-	defaultsNewFn func(fr *Frame) *DefaultsLayer
-}
-
-var newFuncs NewFuncs
+// Layer is the interface of a protocol layer, the layers of a frame string are created by
+// the LayerFunc registered for the layer name and the methods are called in order:
+//
+// Parse - parses the layer options and adds the protocol to the frame.
+// ApplyDefaults - sets the values not given in the options from the default frame.
+// WriteLayer - appends the layer data to the frame data.
+// String - returns the layer in the frame string format.
+type Layer interface {
+	Name() LayerName
+	Parse(opts string) error
+	ApplyDefaults() error
+	WriteLayer() error
+	String() string
+}
+
+// LayerFunc is the function to create a new layer for the frame.
+type LayerFunc func(fr *Frame) Layer
+
+type layerEntry struct {
+	name      LayerName // Name of the layer in display format
+	layerType LayerType // Type of the layer
+	newFn     LayerFunc // Function to create the layer
+}
+
+var (
+	layerMu       sync.RWMutex
+	layerRegistry = make(map[string]*layerEntry) // Registered layers by lower case layer name
+	nextLayerType = MaxLayerType + 1             // Next layer type of a layer added by RegisterLayer
+)
+
+// RegisterLayer adds a new protocol layer to the frame strings, which allows protocols to
+// be added outside of this package. The layer name is case-insensitive in the frame
+// strings and must not be the name of a layer already registered. The returned layer
+// type is given to LayerConstructor() when creating the layer.
+func RegisterLayer(name LayerName, newFn LayerFunc) (LayerType, error) {
+
+	if strings.TrimSpace(string(name)) == "" || strings.ContainsAny(string(name), "()/[]=,") {
+		return MaxLayerType, fmt.Errorf("invalid layer name: '%s'", name)
+	}
+	if newFn == nil {
+		return MaxLayerType, fmt.Errorf("layer %s create function is nil", name)
+	}
+	if strings.EqualFold(string(name), string(LayerDone)) {
+		return MaxLayerType, fmt.Errorf("layer name %s is reserved", name)
+	}
+
+	layerMu.Lock()
+	defer layerMu.Unlock()
+
+	key := strings.ToLower(string(name))
+	if _, ok := layerRegistry[key]; ok {
+		return MaxLayerType, fmt.Errorf("layer %s already registered", name)
+	}
+
+	lType := nextLayerType
+	nextLayerType++
+	layerRegistry[key] = &layerEntry{name: name, layerType: lType, newFn: newFn}
+
+	return lType, nil
+}
+
+// unregisterLayer removes a layer added by RegisterLayer, the built-in layers are not removed.
+func unregisterLayer(name LayerName) {
+
+	layerMu.Lock()
+	defer layerMu.Unlock()
+
+	key := strings.ToLower(string(name))
+	if e, ok := layerRegistry[key]; ok && e.layerType > MaxLayerType {
+		delete(layerRegistry, key)
+	}
+}
+
+// lookupLayer returns the registered layer for the case-insensitive layer name or nil.
+func lookupLayer(name string) *layerEntry {
+
+	layerMu.RLock()
+	defer layerMu.RUnlock()
+
+	return layerRegistry[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// lookupLayerType returns the registered layer for the layer type or nil.
+func lookupLayerType(lType LayerType) *layerEntry {
+
+	layerMu.RLock()
+	defer layerMu.RUnlock()
+
+	for _, e := range layerRegistry {
+		if e.layerType == lType {
+			return e
+		}
+	}
+	return nil
+}
+
+// newLayer creates a new layer of the given layer name for the frame.
+func newLayer(fr *Frame, name LayerName) (Layer, error) {
+
+	e := lookupLayer(string(name))
+	if e == nil {
+		return nil, fmt.Errorf("invalid layer type: '%s'", name)
+	}
+	l := e.newFn(fr)
+	if l == nil {
+		return nil, fmt.Errorf("layer %s create function returned nil", name)
+	}
+	return l, nil
+}
+
+// Frame returns the frame that contains the layer.
+func (ln *LayerHdr) Frame() *Frame {
+	return ln.fr
+}
+
+// Index returns the index of the layer with the same name in the frame, zero is the
+// outer most layer.
+func (ln *LayerHdr) Index() int {
+	return ln.index
+}
+
+// AddProtocol adds the protocol of the layer with the given length to the frame, which
+// is called from the layer Parse() function to set the offsets of the following layers.
+func (ln *LayerHdr) AddProtocol(length uint16) error {
+
+	ln.proto.name = ln.layerName
+	ln.proto.offset = ln.fr.GetOffset(ln.layerName)
+	ln.proto.length = length
+
+	return ln.fr.AddProtocol(&ln.proto)
+}
+
+// Protocol returns the protocol information of the layer.
+func (ln *LayerHdr) Protocol() *ProtoInfo {
+	return &ln.proto
+}
+
+// builtinLayer converts a layer create function of this package to a LayerFunc.
+func builtinLayer[T Layer](fn func(fr *Frame) T) LayerFunc {
+	return func(fr *Frame) Layer { return fn(fr) }
+}
 
 func init() {
 
-	// Register the layer create functions to the global structure
-	newFuncs = NewFuncs{
-		countNewFn:    CountNew,
-		dot1adNewFn:   Dot1adNew,
-		dot1qNewFn:    Dot1qNew,
-		echoNewFn:     EchoNew,
-		etherNewFn:    EtherNew,
-		icmpv4NewFn:   ICMPv4New,
-		icmpv6NewFn:   ICMPv6New,
-		ipv4NewFn:     IPv4New,
-		ipv6NewFn:     IPv6New,
-		payloadNewFn:  PayloadNew,
-		qinqNewFn:     QinQNew,
-		sctpNewFn:     SCTPNew,
-		tcpNewFn:      TCPNew,
-		tscNewFn:      TSCNew,
-		udpNewFn:      UDPNew,
-		vxlanNewFn:    VxLanNew,
-		defaultsNewFn: DefaultsNew,
+	// Register the layers of this package using the layer type index of the layer name
+	builtins := map[LayerType]LayerFunc{
+		LayerEtherType:         builtinLayer(EtherNew),
+		LayerDot1QType:         builtinLayer(Dot1qNew),
+		LayerQinQType:          builtinLayer(QinQNew),
+		LayerDot1ADType:        builtinLayer(Dot1adNew),
+		LayerMPLSType:          builtinLayer(MPLSNew),
+		LayerIPv4Type:          builtinLayer(IPv4New),
+		LayerIPv6Type:          builtinLayer(IPv6New),
+		LayerARPType:           builtinLayer(ARPNew),
+		LayerTCPType:           builtinLayer(TCPNew),
+		LayerUDPType:           builtinLayer(UDPNew),
+		LayerICMPv4Type:        builtinLayer(ICMPv4New),
+		LayerICMPv6Type:        builtinLayer(ICMPv6New),
+		LayerSCTPType:          builtinLayer(SCTPNew),
+		LayerSCTPInitType:      builtinLayer(SCTPInitNew),
+		LayerSCTPHeartbeatType: builtinLayer(SCTPHeartbeatNew),
+		LayerSCTPDataType:      builtinLayer(SCTPDataNew),
+		LayerVxLanType:         builtinLayer(VxLanNew),
+		LayerGREType:           builtinLayer(GRENew),
+		LayerNVGREType:         builtinLayer(NVGRENew),
+		LayerGeneveType:        builtinLayer(GeneveNew),
+		LayerEchoType:          builtinLayer(EchoNew),
+		LayerTSCType:           builtinLayer(TSCNew),
+		LayerPayloadType:       builtinLayer(PayloadNew),
+		LayerDefaultsType:      builtinLayer(DefaultsNew),
+		LayerCountType:         builtinLayer(CountNew),
+		LayerIMIXType:          builtinLayer(IMIXNew),
+		LayerTruncateType:      builtinLayer(TruncateNew),
+		LayerFragmentType:      builtinLayer(FragmentNew),
+		LayerVlanType:          builtinLayer(VlanNew),
+		LayerRawType:           builtinLayer(RawNew),
+		LayerPatchType:         builtinLayer(PatchNew),
+	}
+
+	for lType, fn := range builtins {
+		name := LayerNames[lType]
+		layerRegistry[strings.ToLower(string(name))] = &layerEntry{name: name, layerType: lType, newFn: fn}
 	}
 }
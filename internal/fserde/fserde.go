@@ -5,6 +5,9 @@ package fserde
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -18,18 +21,22 @@ type FrameType int
 // ProtoInfo is the protocol offset and length information in the packet data.
 type ProtoInfo struct {
 	name   LayerName // Name of the protocol layer
+	index  int       // Index of the protocol with the same name, zero is the outer most
 	offset uint16    // Offset to the beginning of the protocol
 	length uint16    // Length of the protocol
+
+	fields map[string]uint16 // Offsets of the named fields from the beginning of the protocol
 }
 
-type ProtoMap map[LayerName]*ProtoInfo  // Protocol Mapping
-type LayerMap map[LayerName]interface{} // interface{} is the given layer structure
+type ProtoMap map[LayerName]*ProtoInfo    // Protocol Mapping
+type LayerMap map[LayerName][]interface{} // Layers of the same name in the order added, outer most first
 
 type LayerInfo struct {
 	Name  LayerName   // Name of the layer i.e., "TCP" or "UDP" or "ipv4" or ...
 	Type  LayerType   // Layer index or type value
 	Opts  string      // Layer strings
 	Layer interface{} // Layer structure pointer
+	pos   position    // Position of the layer in the frame string
 }
 
 // Frame is the representation of a packet in text and binary format.
@@ -40,10 +47,14 @@ type Frame struct {
 	frameType     FrameType    // frame type or index value
 	name          string       // Name of the frame used to map to the frame data.
 	layerInfo     []*LayerInfo // List of layers in the frame in added order.
-	layersMap     LayerMap     // Map of frame layers strings by layer name.
+	layersMap     LayerMap     // Map of frame layers by layer name, repeated layers in order.
 	protocols     []*ProtoInfo // List of protocols in the frame with offsets and lengths.
 	defaultsFrame *Frame       // The default frame data
 	frame         *MyBuffer    // Frame binary data.
+	variants      [][]byte     // Frame data of each Count() frame when field modifiers are used.
+	fragments     [][]byte     // Frame data of each fragment when the frame is fragmented.
+	warnings      []string     // Warnings found while encoding the frame.
+	variant       int          // Index of the Count() frame being encoded.
 }
 
 type FrameKey struct {
@@ -55,14 +66,19 @@ type FrameMap map[FrameKey]*Frame // Frame Mapping between frame type and frame
 // Serde is the main structure of the fserde package.
 // Holding the deserialized and serialized frame data.
 type FrameSerde struct {
-	name       string   // Name of the frame-serde instance
-	frames     FrameMap // Map of Frame structures
-	frameNames []string // List of frame names in same order as they were deserialized.
+	name       string            // Name of the frame-serde instance
+	frames     FrameMap          // Map of Frame structures
+	frameNames []string          // List of frame names in same order as they were deserialized.
+	vars       map[string]string // Variables referenced in the frame strings as ${NAME}
+	mtu        int               // Maximum length of the frame data following the Ether header
 }
 
 // FrameSerdeConfig is the configuration structure for the FrameSerde.Create() call.
 type FrameSerdeConfig struct {
-	Defaults []string // List of default layers to apply to the frames
+	Defaults []string            // List of default layers to apply to the frames
+	Vars     map[string]string   // Variables referenced in the frame strings as ${NAME}
+	PortVars []map[string]string // Variables of each port referenced as ${PORT<n>_NAME}
+	MTU      int                 // Maximum length of the frame data following the Ether header and VLAN tags, defaults to DefaultMTU
 }
 
 // String converts a Frame structure to a string.
@@ -79,15 +95,101 @@ func (p *ProtoInfo) String() string {
 	return fmt.Sprintf("offset=%v, length=%v", p.offset, p.length)
 }
 
-// GetLayer returns a pointer to the layer with the given name.
+// Variants returns the frame data of each of the Count() frames when the frame string
+// contains field modifiers, nil is returned when all of the frames are the same. The
+// fragments of each frame are returned in order when the frame has a Fragment() layer.
+func (fr *Frame) Variants() [][]byte {
+	return fr.variants
+}
+
+// Warnings returns the warnings found while encoding the frame, i.e., a protocol value
+// given in the frame string which does not match the following layer.
+func (fr *Frame) Warnings() []string {
+	return fr.warnings
+}
+
+// Data returns the frame data buffer, the layer WriteLayer() function appends the layer
+// data to the buffer.
+func (fr *Frame) Data() *MyBuffer {
+	return fr.frame
+}
+
+// DefaultsFrame returns the default frame given by the Defaults() layer or nil.
+func (fr *Frame) DefaultsFrame() *Frame {
+	return fr.defaultsFrame
+}
+
+// Name returns the layer name of the protocol.
+func (p *ProtoInfo) Name() LayerName {
+	return p.name
+}
+
+// Offset returns the offset of the protocol in the frame data.
+func (p *ProtoInfo) Offset() uint16 {
+	return p.offset
+}
+
+// Length returns the length of the protocol header.
+func (p *ProtoInfo) Length() uint16 {
+	return p.length
+}
+
+// FieldOffset returns the offset in the frame data of the named field of the protocol,
+// false is returned if the protocol does not have the named field.
+func (p *ProtoInfo) FieldOffset(field string) (uint16, bool) {
+
+	off, ok := p.fields[field]
+	if !ok {
+		return 0, false
+	}
+	return p.offset + off, true
+}
+
+// splitLayerIndex splits a layer name with an optional index i.e., "IPv4[1]" into the
+// layer name and index. The index of a repeated layer is the encapsulation level, where
+// zero is the outer most layer and is the default when no index is given.
+func splitLayerIndex(name LayerName) (LayerName, int) {
+
+	str := strings.TrimSpace(string(name))
+	if i := strings.IndexByte(str, '['); i > 0 && strings.HasSuffix(str, "]") {
+		if idx, err := strconv.Atoi(str[i+1 : len(str)-1]); err == nil && idx >= 0 {
+			return LayerName(strings.TrimSpace(str[:i])), idx
+		}
+		return LayerName(str), -1
+	}
+	return LayerName(str), 0
+}
+
+// addLayer adds the layer to the frame layers map after any layers of the same name.
+func (fr *Frame) addLayer(name LayerName, layer interface{}) {
+	fr.layersMap[name] = append(fr.layersMap[name], layer)
+}
+
+// GetLayer returns a pointer to the layer with the given name, the name may contain an
+// index i.e., "IPv4[1]" for the inner layer. The outer most layer is returned when no
+// index is given.
 func (fr *Frame) GetLayer(name LayerName) interface{} {
 
-	if v, ok := fr.layersMap[name]; ok {
-		return v
+	name, idx := splitLayerIndex(name)
+
+	return fr.GetLayerIndex(name, idx)
+}
+
+// GetLayerIndex returns a pointer to the layer with the given name and index, where
+// index zero is the outer most layer of the given name.
+func (fr *Frame) GetLayerIndex(name LayerName, index int) interface{} {
+
+	if v, ok := fr.layersMap[name]; ok && index >= 0 && index < len(v) {
+		return v[index]
 	}
 	return nil
 }
 
+// LayerCount returns the number of layers with the given name in the frame.
+func (fr *Frame) LayerCount(name LayerName) int {
+	return len(fr.layersMap[name])
+}
+
 func (fr *Frame) GetProtocolID() int {
 
 	if _, ok := fr.GetLayer(LayerUDP).(*UDPLayer); ok {
@@ -98,72 +200,204 @@ func (fr *Frame) GetProtocolID() int {
 		return ProtocolICMPv4
 	} else if _, ok := fr.GetLayer(LayerICMPv6).(*ICMPv6Layer); ok {
 		return ProtocolICMPv6
+	} else if _, ok := fr.GetLayer(LayerSCTP).(*SCTPLayer); ok {
+		return ProtocolSCTP
+	}
+	return 0
+}
+
+// layerProtocolIDs maps the layers to the IP protocol number used by the IPv4 protocol
+// or IPv6 next header field in front of the layer.
+var layerProtocolIDs = map[LayerName]int{
+	LayerUDP:    ProtocolUDP,
+	LayerTCP:    ProtocolTCP,
+	LayerICMPv4: ProtocolICMPv4,
+	LayerICMPv6: ProtocolICMPv6,
+	LayerSCTP:   ProtocolSCTP,
+	LayerIPv4:   ProtocolIPv4,
+	LayerIPv6:   ProtocolIPv6,
+	LayerGRE:    ProtocolGRE,
+	LayerNVGRE:  ProtocolGRE,
+}
+
+// layerEtherTypes maps the layers to the EtherType value used by the layer in front of
+// the layer, i.e., the GRE protocol type.
+var layerEtherTypes = map[LayerName]uint16{
+	LayerIPv4:  EtherTypeIPv4,
+	LayerIPv6:  EtherTypeIPv6,
+	LayerEther: EtherTypeTEB,
+	LayerMPLS:  EtherTypeMPLS,
+	LayerARP:   EtherTypeARP,
+}
+
+// nextProtocol returns the protocol following the given protocol or nil if the given
+// protocol is the last protocol in the frame.
+func (fr *Frame) nextProtocol(proto *ProtoInfo) *ProtoInfo {
+
+	for i, p := range fr.protocols {
+		if p == proto {
+			if i+1 < len(fr.protocols) {
+				return fr.protocols[i+1]
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// nextProtocolID returns the IP protocol number of the protocol following the given
+// protocol, zero is returned if the following protocol does not have a protocol number.
+func (fr *Frame) nextProtocolID(proto *ProtoInfo) int {
+
+	if p := fr.nextProtocol(proto); p != nil {
+		return layerProtocolIDs[p.name]
+	}
+	return 0
+}
+
+// nextEtherType returns the EtherType value of the protocol following the given
+// protocol, zero is returned if the following protocol does not have an EtherType.
+func (fr *Frame) nextEtherType(proto *ProtoInfo) uint16 {
+
+	if p := fr.nextProtocol(proto); p != nil {
+		return layerEtherTypes[p.name]
 	}
 	return 0
 }
 
+// outerLayer returns the closest layer in front of the given protocol matching one of
+// the layer names, i.e., the IPv4 or IPv6 layer carrying a UDP datagram. When the protocol
+// is not part of the frame yet, the last matching layer in the frame is returned.
+func (fr *Frame) outerLayer(proto *ProtoInfo, names ...LayerName) interface{} {
+
+	var found *ProtoInfo
+
+	for _, p := range fr.protocols {
+		if p == proto {
+			break
+		}
+		for _, name := range names {
+			if p.name == name {
+				found = p
+			}
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return fr.GetLayerIndex(found.name, found.index)
+}
+
+// AddProtocol adds the protocol to the end of the frame protocols, the offset and index
+// of the protocol are set from the protocols already in the frame.
 func (fr *Frame) AddProtocol(proto *ProtoInfo) error {
 
+	proto.offset = 0
+	proto.index = 0
+	for _, p := range fr.protocols {
+		proto.offset += p.length
+		if p.name == proto.name {
+			proto.index++
+		}
+	}
 	fr.protocols = append(fr.protocols, proto)
 
 	return nil
 }
 
-// GetProtocol returns a pointer to the protocol with the given name.
+// GetProtocol returns a pointer to the protocol with the given name, the name may
+// contain an index i.e., "IPv4[1]" for the inner protocol.
 func (fr *Frame) GetProtocol(name LayerName) *ProtoInfo {
 
+	name, idx := splitLayerIndex(name)
+
 	for _, proto := range fr.protocols {
-		if proto.name == name {
+		if proto.name == name && proto.index == idx {
 			return proto
 		}
 	}
 	return nil
 }
 
-// GetOffset returns the offset to the given layer name.
-// The offset is the offset to the beginning of the protocol layer name
-func (fr *Frame) GetOffset(name LayerName) uint16 {
+// protoOffset returns the offset of the protocol in the frame data.
+func (fr *Frame) protoOffset(proto *ProtoInfo) uint16 {
 
 	var offset uint16 = 0
 
-	for _, proto := range fr.protocols {
-		if proto.name == name { // Stop at the given layer
+	for _, p := range fr.protocols {
+		if p == proto { // Stop at the given protocol
 			break
 		}
-		offset += proto.length
+		offset += p.length
 	}
 	return offset
 }
 
-// GetLength returns the length of the following layers starting with the given layer name.
-func (fr *Frame) GetLength(name LayerName) uint16 {
+// protoLength returns the length of the given protocol plus all of the following
+// protocols, which is the length of the protocol at that encapsulation level.
+func (fr *Frame) protoLength(proto *ProtoInfo) uint16 {
 
 	var length uint16 = 0
 
 	found := false
-	for _, proto := range fr.protocols {
-		if !found && proto.name == name { // Start at the given layer
+	for _, p := range fr.protocols {
+		if p == proto { // Start at the given protocol
 			found = true
-			length += proto.length
-			continue
 		}
 		if found {
-			length += proto.length
+			length += p.length
 		}
 	}
 	return length
 }
 
+// GetOffset returns the offset to the given layer name, the name may contain an index
+// i.e., "IPv4[1]". The offset is the offset to the beginning of the protocol layer name
+// or the length of the frame protocols if the layer is not found.
+func (fr *Frame) GetOffset(name LayerName) uint16 {
+
+	if proto := fr.GetProtocol(name); proto != nil {
+		return fr.protoOffset(proto)
+	}
+	return fr.protoOffset(nil)
+}
+
+// GetLength returns the length of the following layers starting with the given layer name,
+// the name may contain an index i.e., "IPv4[1]".
+func (fr *Frame) GetLength(name LayerName) uint16 {
+
+	if proto := fr.GetProtocol(name); proto != nil {
+		return fr.protoLength(proto)
+	}
+	return 0
+}
+
 // Create a FrameSerde structure from the default values.
 // If the default values are present then parse them to the FrameSerde structure.
+// The MTU defaults to DefaultMTU when cfg.MTU is zero, a frame with more than the MTU bytes
+// following the Ether header and VLAN tags is an error in StringToBinary() and
+// BinaryToString(), set cfg.MTU to JumboMTU to encode or decode jumbo frames.
 func Create(name string, cfg *FrameSerdeConfig) (*FrameSerde, error) {
 
 	if len(name) == 0 {
 		return nil, fmt.Errorf("missing frame-serde name")
 	}
+	vars, err := newFrameVars(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mtu := DefaultMTU
+	if cfg != nil && cfg.MTU != 0 {
+		if cfg.MTU < MinMTU || cfg.MTU > math.MaxUint16 {
+			return nil, fmt.Errorf("mtu %d is not between %d and %d", cfg.MTU, MinMTU, math.MaxUint16)
+		}
+		mtu = cfg.MTU
+	}
 	fserde := &FrameSerde{
 		name:   name,
 		frames: make(FrameMap),
+		vars:   vars,
+		mtu:    mtu,
 	}
 	if cfg != nil && len(cfg.Defaults) > 0 {
 		if err := fserde.DefaultsToBinary(cfg.Defaults); err != nil {
@@ -0,0 +1,352 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The Geneve() protocol layer follows the outer UDP layer and is followed by the inner
+// frame, i.e., Ether()/IPv4()/UDP()/Geneve(vni=10, opts=[0x0102:0x80:0x01020304])/Ether()/...
+//
+// vni - is the 24 bit virtual network identifier.
+// [proto|protocol] - is the protocol type of the inner frame, defaults to the EtherType
+//                    of the following Ether, IPv4 or IPv6 layer.
+// oam - sets the OAM packet flag.
+// opts - is a list of TLV options [class:type:data, ...], the class is 16 bits, the type
+//        is 8 bits and the data is an optional hex string padded to a multiple of 4 bytes.
+//        The critical options present flag is set when an option type has the critical
+//        bit set.
+//
+// The outer UDP destination port defaults to 6081.
+
+const (
+	GeneveHeaderLen       = 8
+	GenevePort            = 6081 // IANA assigned GENEVE UDP port
+	GeneveMaxVNI          = 0xFFFFFF
+	GeneveOptionHdrLen    = 4
+	GeneveOptionLenMask   = 0x1F
+	GeneveOptionsLenMask  = 0x3F
+	GeneveMaxOptionData   = GeneveOptionLenMask * 4  // Maximum option data length in bytes
+	GeneveMaxOptionsLen   = GeneveOptionsLenMask * 4 // Maximum length of all options in bytes
+	GeneveOAMFlag         = 0x80                     // OAM packet flag
+	GeneveCriticalFlag    = 0x40                     // Critical options present flag
+	GeneveCriticalOptType = 0x80                     // Critical bit of the option type
+)
+
+type GeneveOption struct {
+	Class uint16 // Option class
+	Type  uint8  // Option type, the high bit is the critical bit
+	Data  []byte // Option data, a multiple of 4 bytes
+}
+
+type GeneveLayer struct {
+	hdr      *LayerHdr
+	vni      uint32         // 24 bits VNI value
+	protocol uint16         // Protocol type of the inner frame
+	protoSet bool           // Protocol type was given in the options
+	oam      bool           // OAM packet flag
+	options  []GeneveOption // TLV options
+}
+
+func (l *GeneveLayer) String() string {
+
+	s := fmt.Sprintf("%s(vni=0x%06x, proto=0x%04x", l.Name(), l.vni, l.protocol)
+	if l.oam {
+		s += ", oam=true"
+	}
+	if len(l.options) > 0 {
+		opts := make([]string, 0, len(l.options))
+		for _, o := range l.options {
+			opt := fmt.Sprintf("0x%04x:0x%02x", o.Class, o.Type)
+			if len(o.Data) > 0 {
+				opt += fmt.Sprintf(":0x%x", o.Data)
+			}
+			opts = append(opts, opt)
+		}
+		s += fmt.Sprintf(", opts=[%s]", strings.Join(opts, ", "))
+	}
+	return s + ")"
+}
+
+func GeneveNew(fr *Frame) *GeneveLayer {
+	return &GeneveLayer{
+		hdr: LayerConstructor(fr, LayerGeneve, LayerGeneveType),
+	}
+}
+
+func (l *GeneveLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// optionsLen returns the length in bytes of the TLV options.
+func (l *GeneveLayer) optionsLen() int {
+
+	length := 0
+	for _, o := range l.options {
+		length += GeneveOptionHdrLen + len(o.Data)
+	}
+	return length
+}
+
+// critical returns true if one of the options has the critical bit set.
+func (l *GeneveLayer) critical() bool {
+
+	for _, o := range l.options {
+		if o.Type&GeneveCriticalOptType != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGeneveOptions parses the option list [class:type:data, ...] into the TLV options.
+func parseGeneveOptions(val string) ([]GeneveOption, error) {
+
+	val = strings.TrimSpace(val)
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, "]") {
+		return nil, fmt.Errorf("geneve options must be a list: %s", val)
+	}
+
+	options := make([]GeneveOption, 0)
+	for _, str := range strings.Split(val[1:len(val)-1], ",") {
+		str = strings.TrimSpace(str)
+		if len(str) == 0 {
+			continue
+		}
+
+		fields := strings.Split(str, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("geneve option must be class:type[:data]: %s", str)
+		}
+
+		var opt GeneveOption
+		if v, err := strconv.ParseUint(strings.TrimSpace(fields[0]), 0, 16); err != nil {
+			return nil, fmt.Errorf("invalid geneve option class: %s", fields[0])
+		} else {
+			opt.Class = uint16(v)
+		}
+		if v, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 0, 8); err != nil {
+			return nil, fmt.Errorf("invalid geneve option type: %s", fields[1])
+		} else {
+			opt.Type = uint8(v)
+		}
+		if len(fields) == 3 {
+			data := strings.TrimPrefix(strings.Trim(strings.TrimSpace(fields[2]), "'\""), "0x")
+			if b, err := hex.DecodeString(data); err != nil {
+				return nil, fmt.Errorf("invalid geneve option data: %s", fields[2])
+			} else {
+				opt.Data = b
+			}
+			// The option length is in 4 byte units, pad the data with zeros
+			if pad := len(opt.Data) % 4; pad != 0 {
+				opt.Data = append(opt.Data, make([]byte, 4-pad)...)
+			}
+			if len(opt.Data) > GeneveMaxOptionData {
+				return nil, fmt.Errorf("geneve option data too long: %d > %d bytes", len(opt.Data), GeneveMaxOptionData)
+			}
+		}
+		options = append(options, opt)
+	}
+
+	return options, nil
+}
+
+func (l *GeneveLayer) Parse(opts string) error {
+
+	for _, opt := range splitOptions(opts) {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "vni":
+			if v, err := strconv.ParseUint(val, 0, 24); err != nil {
+				return fmt.Errorf("invalid geneve vni: %s", val)
+			} else {
+				l.vni = uint32(v)
+			}
+		case "proto", "protocol":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.protocol = uint16(v)
+				l.protoSet = true
+			}
+		case "oam":
+			switch val {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				l.oam = true
+			case "off", "no", "false", "disable", "disabled", "0":
+				l.oam = false
+			default:
+				return fmt.Errorf("oam invalid value: %s", val)
+			}
+		case "opts", "options":
+			if options, err := parseGeneveOptions(val); err != nil {
+				return err
+			} else {
+				l.options = options
+			}
+		default:
+			return fmt.Errorf("unknown geneve option: [%s]", opt)
+		}
+	}
+
+	if l.optionsLen() > GeneveMaxOptionsLen {
+		return fmt.Errorf("geneve options too long: %d > %d bytes", l.optionsLen(), GeneveMaxOptionsLen)
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(GeneveHeaderLen + l.optionsLen())
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *GeneveLayer) ApplyDefaults() error {
+
+	// The outer UDP destination port defaults to the GENEVE port after the UDP
+	// layer has applied the default frame values.
+	defer func() {
+		if udp, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerUDP).(*UDPLayer); ok && udp.udpHdr.DstPort == 0 {
+			udp.udpHdr.DstPort = GenevePort
+		}
+	}()
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerGeneve, l.hdr.index).(*GeneveLayer)
+	if !ok {
+		return nil
+	}
+
+	// The options are part of the header length and are not taken from the default frame.
+	if l.vni == 0 && dl.vni != 0 {
+		l.vni = dl.vni
+	}
+	if l.protocol == 0 && dl.protocol != 0 {
+		l.protocol = dl.protocol
+	}
+
+	return nil
+}
+
+func (l *GeneveLayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+
+	flags := uint8(0)
+	if l.oam {
+		flags |= GeneveOAMFlag
+	}
+	if l.critical() {
+		flags |= GeneveCriticalFlag
+	}
+
+	data.Append(uint8(l.optionsLen() / 4)) // version 0 and options length
+	data.Append(flags)
+	data.Append(l.protocol)
+	data.Append((l.vni & GeneveMaxVNI) << 8)
+
+	for _, o := range l.options {
+		data.Append(o.Class)
+		data.Append(o.Type)
+		data.Append(uint8(len(o.Data) / 4))
+		data.Append(o.Data)
+	}
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains a version 0 GENEVE header with
+// the reserved fields zero and options which fill the options length. The critical flag
+// must match the option types, as the flag is computed from the options when encoded.
+func (l *GeneveLayer) decodeValid(data []byte) bool {
+
+	if len(data) < GeneveHeaderLen {
+		return false
+	}
+	if data[0]>>6 != 0 || data[1]&^(GeneveOAMFlag|GeneveCriticalFlag) != 0 || data[7] != 0 {
+		return false
+	}
+
+	end := GeneveHeaderLen + int(data[0]&GeneveOptionsLenMask)*4
+	if len(data) < end {
+		return false
+	}
+
+	critical := false
+	for off := GeneveHeaderLen; off < end; {
+		if off+GeneveOptionHdrLen > end || data[off+3]&^GeneveOptionLenMask != 0 {
+			return false
+		}
+		if data[off+2]&GeneveCriticalOptType != 0 {
+			critical = true
+		}
+		off += GeneveOptionHdrLen + int(data[off+3])*4
+		if off > end {
+			return false
+		}
+	}
+
+	return critical == (data[1]&GeneveCriticalFlag != 0)
+}
+
+// Decode the GENEVE header and options from the binary frame data.
+func (l *GeneveLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < GeneveHeaderLen {
+		return 0, fmt.Errorf("geneve header too short: %d bytes", len(data))
+	}
+
+	length := GeneveHeaderLen + int(data[0]&GeneveOptionsLenMask)*4
+	if len(data) < length {
+		return 0, fmt.Errorf("geneve header too short: %d bytes", len(data))
+	}
+
+	l.oam = data[1]&GeneveOAMFlag != 0
+	l.protocol = binary.BigEndian.Uint16(data[2:])
+	l.vni = binary.BigEndian.Uint32(data[4:]) >> 8
+
+	l.options = nil
+	for off := GeneveHeaderLen; off+GeneveOptionHdrLen <= length; {
+		n := int(data[off+3]&GeneveOptionLenMask) * 4
+		if off+GeneveOptionHdrLen+n > length {
+			return 0, fmt.Errorf("geneve option exceeds header length")
+		}
+		l.options = append(l.options, GeneveOption{
+			Class: binary.BigEndian.Uint16(data[off:]),
+			Type:  data[off+2],
+			Data:  append([]byte{}, data[off+GeneveOptionHdrLen:off+GeneveOptionHdrLen+n]...),
+		})
+		off += GeneveOptionHdrLen + n
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(length)
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return length, nil
+}
@@ -0,0 +1,278 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The GRE() protocol layer follows the outer IPv4 or IPv6 layer and is followed by the
+// encapsulated layers, i.e., Ether()/IPv4()/GRE(key=10)/IPv4()/UDP()/...
+//
+// [proto|protocol] - is the protocol type of the encapsulated layer, defaults to the
+//                    EtherType of the following IPv4, IPv6 or Ether layer.
+// key - adds the 32 bit key field.
+// [seq|seqnum] - adds the 32 bit sequence number field.
+// checksum - adds the checksum field computed over the GRE header and payload.
+//
+// The IPv4 protocol or IPv6 next header in front of the GRE layer is set to 47.
+
+const (
+	GREHeaderLen      = 4 // GRE header length without the optional fields
+	GREOptionLen      = 4 // Length of each of the optional fields
+	GREChecksumOffset = 4
+)
+
+// Flags that may be set in the GRE header.
+const (
+	GRESeqFlag      = 0x1000
+	GREKeyFlag      = 0x2000
+	GREChecksumFlag = 0x8000
+)
+
+type GREHdr struct {
+	Flags    uint16 // Checksum, key and sequence number present flags
+	Protocol uint16 // Protocol type of the encapsulated layer
+	Checksum uint16 // Checksum, computed after the frame is written
+	Key      uint32 // Key value when the key flag is set
+	SeqNum   uint32 // Sequence number when the sequence flag is set
+}
+
+type GRELayer struct {
+	hdr      *LayerHdr
+	greHdr   GREHdr
+	protoSet bool // Protocol type was given in the options
+}
+
+func (l *GRELayer) String() string {
+	h := l.greHdr
+
+	s := fmt.Sprintf("%s(proto=0x%04x", l.Name(), h.Protocol)
+	if h.Flags&GREKeyFlag != 0 {
+		s += fmt.Sprintf(", key=%#x", h.Key)
+	}
+	if h.Flags&GRESeqFlag != 0 {
+		s += fmt.Sprintf(", seq=%d", h.SeqNum)
+	}
+	if h.Flags&GREChecksumFlag != 0 {
+		s += ", checksum=true"
+	}
+	return s + ")"
+}
+
+func GRENew(fr *Frame) *GRELayer {
+	return &GRELayer{
+		hdr: LayerConstructor(fr, LayerGRE, LayerGREType),
+	}
+}
+
+func (l *GRELayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// length returns the GRE header length including the optional fields.
+func (l *GRELayer) length() uint16 {
+
+	length := uint16(GREHeaderLen)
+	for _, flag := range []uint16{GREChecksumFlag, GREKeyFlag, GRESeqFlag} {
+		if l.greHdr.Flags&flag != 0 {
+			length += GREOptionLen
+		}
+	}
+	return length
+}
+
+func (l *GRELayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "proto", "protocol":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.greHdr.Protocol = uint16(v)
+				l.protoSet = true
+			}
+		case "key":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.greHdr.Key = uint32(v)
+				l.greHdr.Flags |= GREKeyFlag
+			}
+		case "seq", "seqnum":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.greHdr.SeqNum = uint32(v)
+				l.greHdr.Flags |= GRESeqFlag
+			}
+		case "checksum":
+			switch val {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				l.greHdr.Flags |= GREChecksumFlag
+			case "off", "no", "false", "disable", "disabled", "0":
+				l.greHdr.Flags &^= GREChecksumFlag
+			default:
+				return fmt.Errorf("checksum invalid value: %s", val)
+			}
+		default:
+			return fmt.Errorf("unknown gre option: [%s]", opt)
+		}
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = l.length()
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *GRELayer) ApplyDefaults() error {
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerGRE, l.hdr.index).(*GRELayer)
+	if !ok {
+		return nil
+	}
+
+	// The optional fields are part of the header length and are not taken from the
+	// default frame, only the values of the fields present in the frame.
+	if l.greHdr.Protocol == 0 && dl.greHdr.Protocol != 0 {
+		l.greHdr.Protocol = dl.greHdr.Protocol
+	}
+	if l.greHdr.Flags&GREKeyFlag != 0 && l.greHdr.Key == 0 {
+		l.greHdr.Key = dl.greHdr.Key
+	}
+	if l.greHdr.Flags&GRESeqFlag != 0 && l.greHdr.SeqNum == 0 {
+		l.greHdr.SeqNum = dl.greHdr.SeqNum
+	}
+
+	return nil
+}
+
+func (l *GRELayer) WriteLayer() error {
+
+	h := &l.greHdr
+	data := l.hdr.fr.frame
+
+	data.Append(h.Flags)
+	data.Append(h.Protocol)
+	if h.Flags&GREChecksumFlag != 0 {
+		data.Append(uint32(0)) // force checksum to zero, update later
+	}
+	if h.Flags&GREKeyFlag != 0 {
+		data.Append(h.Key)
+	}
+	if h.Flags&GRESeqFlag != 0 {
+		data.Append(h.SeqNum)
+	}
+
+	return nil
+}
+
+// updateChecksum computes the checksum over the GRE header and payload when the checksum
+// field is present.
+func (l *GRELayer) updateChecksum() error {
+
+	if l.greHdr.Flags&GREChecksumFlag == 0 {
+		return nil
+	}
+
+	fr := l.hdr.fr
+	off := fr.protoOffset(&l.hdr.proto)
+	d := fr.frame.Bytes()[off : off+fr.protoLength(&l.hdr.proto)]
+
+	l.greHdr.Checksum = ^reduceChecksum(dataChecksum(d, len(d)))
+
+	return fr.frame.WriteValueAt(int(off+GREChecksumOffset), l.greHdr.Checksum)
+}
+
+// decodeValid returns true if the data slice contains a version 0 GRE header without
+// routing information and a valid checksum when the checksum field is present.
+func (l *GRELayer) decodeValid(data []byte) bool {
+
+	if len(data) < GREHeaderLen {
+		return false
+	}
+
+	flags := binary.BigEndian.Uint16(data[0:])
+	if flags&^(GREChecksumFlag|GREKeyFlag|GRESeqFlag) != 0 {
+		return false
+	}
+
+	l.greHdr.Flags = flags
+	if len(data) < int(l.length()) {
+		return false
+	}
+	if flags&GREChecksumFlag != 0 {
+		if binary.BigEndian.Uint16(data[GREChecksumOffset+2:]) != 0 {
+			return false
+		}
+		return ^reduceChecksum(dataChecksum(data, len(data))) == 0
+	}
+	return true
+}
+
+// Decode the GRE header from the binary frame data.
+func (l *GRELayer) Decode(data []byte) (int, error) {
+
+	if len(data) < GREHeaderLen {
+		return 0, fmt.Errorf("gre header too short: %d bytes", len(data))
+	}
+
+	h := &l.greHdr
+	h.Flags = binary.BigEndian.Uint16(data[0:])
+	h.Protocol = binary.BigEndian.Uint16(data[2:])
+
+	length := int(l.length())
+	if len(data) < length {
+		return 0, fmt.Errorf("gre header too short: %d bytes", len(data))
+	}
+
+	off := GREHeaderLen
+	if h.Flags&GREChecksumFlag != 0 {
+		h.Checksum = binary.BigEndian.Uint16(data[off:])
+		off += GREOptionLen
+	}
+	if h.Flags&GREKeyFlag != 0 {
+		h.Key = binary.BigEndian.Uint32(data[off:])
+		off += GREOptionLen
+	}
+	if h.Flags&GRESeqFlag != 0 {
+		h.SeqNum = binary.BigEndian.Uint32(data[off:])
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(length)
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return length, nil
+}
@@ -5,14 +5,16 @@
 package fserde
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"strings"
 )
 
 const (
-	ICMPv4MinLen = 8
-	ICMPv6MinLen = 40
+	ICMPv4MinLen       = 8
+	ICMPv6MinLen       = 40
+	ICMPChecksumOffset = 2
 )
 
 // ICMPHdr L4 header.
@@ -30,7 +32,8 @@ type ICMPv4Layer struct {
 }
 
 func (l *ICMPv4Layer) String() string {
-	return fmt.Sprintf("%s()", l.hdr.layerName)
+	return fmt.Sprintf("%s(type=%d, code=%d, ident=%d, seq=%d)", l.hdr.layerName,
+		l.icmpHdr.Type, l.icmpHdr.Code, l.icmpHdr.Identifier, l.icmpHdr.SeqNum)
 }
 
 func ICMPv4New(fr *Frame) *ICMPv4Layer {
@@ -45,18 +48,20 @@ func (l *ICMPv4Layer) Name() LayerName {
 
 func (l *ICMPv4Layer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
 
 	for _, opt := range options {
 		opt = strings.TrimSpace(opt)
-
-		kvp := strings.Split(opt, "=")
-		if len(kvp) != 2 {
-			return fmt.Errorf("invalid option: %s", opt)
+		if len(opt) == 0 {
+			continue
 		}
 
-		key := strings.ToLower(strings.TrimSpace(kvp[0]))
-		val := strings.ToLower(strings.TrimSpace(kvp[1]))
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
 
 		switch key {
 		case "type":
@@ -88,7 +93,7 @@ func (l *ICMPv4Layer) Parse(opts string) error {
 
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
+	l.hdr.proto.length = ICMPv4MinLen
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
@@ -102,12 +107,23 @@ func (l *ICMPv4Layer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerICMPv4).(*ICMPv4Layer)
+	dl, ok := d.GetLayerIndex(LayerICMPv4, l.hdr.index).(*ICMPv4Layer)
 	if !ok {
 		return nil
 	}
 
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
+	if l.icmpHdr.Type == 0 && dl.icmpHdr.Type != 0 {
+		l.icmpHdr.Type = dl.icmpHdr.Type
+	}
+	if l.icmpHdr.Code == 0 && dl.icmpHdr.Code != 0 {
+		l.icmpHdr.Code = dl.icmpHdr.Code
+	}
+	if l.icmpHdr.Identifier == 0 && dl.icmpHdr.Identifier != 0 {
+		l.icmpHdr.Identifier = dl.icmpHdr.Identifier
+	}
+	if l.icmpHdr.SeqNum == 0 && dl.icmpHdr.SeqNum != 0 {
+		l.icmpHdr.SeqNum = dl.icmpHdr.SeqNum
+	}
 
 	return nil
 }
@@ -128,3 +144,50 @@ func (l *ICMPv4Layer) WriteLayer() error {
 
 	return nil
 }
+
+// updateChecksum computes the ICMPv4 checksum over the ICMPv4 message.
+func (l *ICMPv4Layer) updateChecksum() error {
+
+	fr := l.hdr.fr
+	ip, ok := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv4Layer)
+	if !ok {
+		return nil
+	}
+
+	off := fr.protoOffset(&l.hdr.proto)
+	d := fr.frame.Bytes()[off+ICMPv4MinLen : off+fr.protoLength(&l.hdr.proto)]
+	cksum := IPv4ICMPChecksum(&ip.ipHdr, &l.icmpHdr, d)
+
+	return fr.frame.WriteValueAt(int(off+ICMPChecksumOffset), cksum)
+}
+
+// decodeValid returns true if the data slice contains an ICMP message with a valid checksum.
+func (l *ICMPv4Layer) decodeValid(data []byte) bool {
+
+	if len(data) < ICMPv4MinLen {
+		return false
+	}
+	return ^reduceChecksum(dataChecksum(data, len(data))) == 0
+}
+
+// Decode the ICMP header from the binary frame data.
+func (l *ICMPv4Layer) Decode(data []byte) (int, error) {
+
+	if len(data) < ICMPv4MinLen {
+		return 0, fmt.Errorf("icmp header too short: %d bytes", len(data))
+	}
+
+	l.icmpHdr.Type = data[0]
+	l.icmpHdr.Code = data[1]
+	l.icmpHdr.Cksum = binary.BigEndian.Uint16(data[2:])
+	l.icmpHdr.Identifier = binary.BigEndian.Uint16(data[4:])
+	l.icmpHdr.SeqNum = binary.BigEndian.Uint16(data[6:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = ICMPv4MinLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return ICMPv4MinLen, nil
+}
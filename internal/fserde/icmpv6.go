@@ -5,15 +5,107 @@
 package fserde
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 )
 
+// The ICMPv6() protocol layer has a number of protocol-value options zero or more
+// options may be specified.
+//
+// type - is the message type number or one of the names echo-request, echo-reply,
+//        rs, ns or na. The type defaults to echo-request.
+// code - is the message code value.
+// [id|ident|identifier], [seq|seqnum] - are the echo request/reply identifier and sequence.
+// target - is the target address of the neighbor solicitation/advertisement messages.
+// [slla|srcll] - adds a source link-layer address option to the RS and NS messages.
+// [tlla|dstll] - adds a target link-layer address option to the NA message.
+// flags - are the NA flags as a value or [R | S | O] router, solicited and override, only
+//         valid for the NA message.
+//
+// The neighbor discovery messages set the IPv6 hop limit to 255 when the hop limit is
+// not given and the neighbor solicitation IPv6 and Ether destination addresses are set
+// to the solicited-node multicast address of the target when the addresses are not given.
+
+const (
+	ICMPv6HeaderLen    = 8 // ICMPv6 header length type, code, checksum and 4 bytes of data
+	ICMPv6NDOptLen     = 8 // Length of a link-layer address option
+	ICMPv6NDHopLimit   = 255
+	ICMPv6OptSrcLLAddr = 1 // Source link-layer address option type
+	ICMPv6OptDstLLAddr = 2 // Target link-layer address option type
+)
+
+// ICMPv6 message types supported by the ICMPv6 layer.
+const (
+	ICMPv6EchoRequest           = 128
+	ICMPv6EchoReply             = 129
+	ICMPv6RouterSolicitation    = 133
+	ICMPv6NeighborSolicitation  = 135
+	ICMPv6NeighborAdvertisement = 136
+)
+
+// Neighbor advertisement flags in the upper bits of the identifier field.
+const (
+	ICMPv6NAOverrideFlag  = 0x2000
+	ICMPv6NASolicitedFlag = 0x4000
+	ICMPv6NARouterFlag    = 0x8000
+)
+
+var icmpv6TypeNames = map[string]uint8{
+	"echo-request":           ICMPv6EchoRequest,
+	"echorequest":            ICMPv6EchoRequest,
+	"echo-reply":             ICMPv6EchoReply,
+	"echoreply":              ICMPv6EchoReply,
+	"rs":                     ICMPv6RouterSolicitation,
+	"router-solicitation":    ICMPv6RouterSolicitation,
+	"ns":                     ICMPv6NeighborSolicitation,
+	"neighbor-solicitation":  ICMPv6NeighborSolicitation,
+	"na":                     ICMPv6NeighborAdvertisement,
+	"neighbor-advertisement": ICMPv6NeighborAdvertisement,
+}
+
 type ICMPv6Layer struct {
-	hdr *LayerHdr
+	hdr     *LayerHdr
+	icmpHdr ICMPHeader
+	target  net.IP           // Target address of the NS and NA messages
+	srcLL   net.HardwareAddr // Source link-layer address option
+	dstLL   net.HardwareAddr // Target link-layer address option
 }
 
 func (l *ICMPv6Layer) String() string {
-	return fmt.Sprintf("%s()", l.hdr.layerName)
+	h := l.icmpHdr
+
+	switch h.Type {
+	case ICMPv6EchoRequest, ICMPv6EchoReply:
+		name := "echo-request"
+		if h.Type == ICMPv6EchoReply {
+			name = "echo-reply"
+		}
+		return fmt.Sprintf("%s(type=%s, code=%d, id=%d, seq=%d)", l.Name(), name, h.Code, h.Identifier, h.SeqNum)
+	case ICMPv6RouterSolicitation:
+		s := fmt.Sprintf("%s(type=rs", l.Name())
+		if len(l.srcLL) > 0 {
+			s += fmt.Sprintf(", slla=%v", l.srcLL)
+		}
+		return s + ")"
+	case ICMPv6NeighborSolicitation:
+		s := fmt.Sprintf("%s(type=ns, target=%v", l.Name(), l.target)
+		if len(l.srcLL) > 0 {
+			s += fmt.Sprintf(", slla=%v", l.srcLL)
+		}
+		return s + ")"
+	case ICMPv6NeighborAdvertisement:
+		s := fmt.Sprintf("%s(type=na, flags=%#x, target=%v", l.Name(), h.Identifier>>13, l.target)
+		if len(l.dstLL) > 0 {
+			s += fmt.Sprintf(", tlla=%v", l.dstLL)
+		}
+		return s + ")"
+	default:
+		return fmt.Sprintf("%s(type=%d, code=%d, id=%d, seq=%d)", l.Name(), h.Type, h.Code, h.Identifier, h.SeqNum)
+	}
 }
 
 func ICMPv6New(fr *Frame) *ICMPv6Layer {
@@ -26,35 +118,362 @@ func (l *ICMPv6Layer) Name() LayerName {
 	return l.hdr.layerName
 }
 
-func (el *ICMPv6Layer) Parse(opts string) error {
+// isNeighborDiscovery returns true if the message is a neighbor discovery message.
+func (l *ICMPv6Layer) isNeighborDiscovery() bool {
+	switch l.icmpHdr.Type {
+	case ICMPv6RouterSolicitation, ICMPv6NeighborSolicitation, ICMPv6NeighborAdvertisement:
+		return true
+	}
+	return false
+}
+
+// length returns the length of the ICMPv6 message including the neighbor discovery
+// target address and options.
+func (l *ICMPv6Layer) length() uint16 {
+
+	length := uint16(ICMPv6HeaderLen)
+
+	switch l.icmpHdr.Type {
+	case ICMPv6RouterSolicitation:
+		if len(l.srcLL) > 0 {
+			length += ICMPv6NDOptLen
+		}
+	case ICMPv6NeighborSolicitation:
+		length += net.IPv6len
+		if len(l.srcLL) > 0 {
+			length += ICMPv6NDOptLen
+		}
+	case ICMPv6NeighborAdvertisement:
+		length += net.IPv6len
+		if len(l.dstLL) > 0 {
+			length += ICMPv6NDOptLen
+		}
+	}
+	return length
+}
+
+func parseNAFlags(f string) (uint16, error) {
+
+	switch strings.TrimSpace(f) {
+	case "r", "router":
+		return ICMPv6NARouterFlag, nil
+	case "s", "solicited":
+		return ICMPv6NASolicitedFlag, nil
+	case "o", "override":
+		return ICMPv6NAOverrideFlag, nil
+	case "":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unknown neighbor advertisement flag: %s", f)
+	}
+}
+
+func (l *ICMPv6Layer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	typeSet, flagsSet := false, false
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "type":
+			if v, ok := icmpv6TypeNames[val]; ok {
+				l.icmpHdr.Type = v
+			} else if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return fmt.Errorf("invalid ICMPv6 type: %s", val)
+			} else {
+				l.icmpHdr.Type = uint8(v)
+			}
+			typeSet = true
+		case "code":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.icmpHdr.Code = uint8(v)
+			}
+		case "id", "ident", "identifier":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.icmpHdr.Identifier = uint16(v)
+			}
+		case "seq", "seqnum":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.icmpHdr.SeqNum = uint16(v)
+			}
+		case "flags":
+			if !strings.HasPrefix(val, "[") {
+				if v, err := strconv.ParseUint(val, 0, 3); err != nil {
+					return fmt.Errorf("invalid neighbor advertisement flags: %s", val)
+				} else {
+					l.icmpHdr.Identifier = uint16(v) << 13
+				}
+			} else {
+				str := strings.FieldsFunc(val, func(r rune) bool {
+					return r == '[' || r == ']'
+				})
+				if len(str) > 0 {
+					for _, f := range strings.Split(str[0], "|") {
+						if flag, err := parseNAFlags(f); err != nil {
+							return err
+						} else {
+							l.icmpHdr.Identifier |= flag
+						}
+					}
+				}
+			}
+			flagsSet = true
+		case "target":
+			if ip, err := parseIPv6(val); err != nil {
+				return err
+			} else {
+				l.target = ip
+			}
+		case "slla", "srcll":
+			if mac, err := ToHardwareAddr(val); err != nil {
+				return err
+			} else {
+				l.srcLL = mac
+			}
+		case "tlla", "dstll":
+			if mac, err := ToHardwareAddr(val); err != nil {
+				return err
+			} else {
+				l.dstLL = mac
+			}
+		default:
+			return fmt.Errorf("unknown icmpv6 option: [%s]", opt)
+		}
+	}
+
+	if !typeSet {
+		l.icmpHdr.Type = ICMPv6EchoRequest
+	}
+	if flagsSet && l.icmpHdr.Type != ICMPv6NeighborAdvertisement {
+		return fmt.Errorf("flags is only valid for the neighbor-advertisement type")
+	}
+	if l.target == nil {
+		l.target = net.IPv6zero
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = l.length()
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
 	return nil
 }
 
+// applyNDDefaults sets the IPv6 hop limit required by neighbor discovery and the
+// solicited-node multicast destination addresses for the neighbor solicitation.
+func (l *ICMPv6Layer) applyNDDefaults() {
+
+	if !l.isNeighborDiscovery() {
+		return
+	}
+
+	ip, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv6Layer)
+	if !ok {
+		return
+	}
+	if !ip.hlimSet {
+		ip.ip6Hdr.HopLimit = ICMPv6NDHopLimit
+	}
+
+	if l.icmpHdr.Type != ICMPv6NeighborSolicitation || isIPv6Zero(l.target) {
+		return
+	}
+
+	if isIPv6Zero(ip.ip6Hdr.Dst) {
+		// Solicited-node multicast address ff02::1:ffXX:XXXX
+		dst := net.ParseIP("ff02::1:ff00:0")
+		copy(dst[13:], l.target[13:])
+		ip.ip6Hdr.Dst = dst
+	}
+	if ether, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerEther).(*EtherLayer); ok && isZeroMac(ether.ether.DstMac) {
+		// IPv6 multicast MAC address 33:33:XX:XX:XX:XX
+		ether.ether.DstMac = net.HardwareAddr{0x33, 0x33, 0, 0, 0, 0}
+		copy(ether.ether.DstMac[2:], ip.ip6Hdr.Dst.To16()[12:])
+	}
+}
+
 func (l *ICMPv6Layer) ApplyDefaults() error {
 
+	defer l.applyNDDefaults()
+
 	d := l.hdr.fr.defaultsFrame
 	if d == nil {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerICMPv6).(*ICMPv6Layer)
-	if !ok {
+	dl, ok := d.GetLayerIndex(LayerICMPv6, l.hdr.index).(*ICMPv6Layer)
+	if !ok || dl.icmpHdr.Type != l.icmpHdr.Type {
 		return nil
 	}
 
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
+	if l.icmpHdr.Code == 0 && dl.icmpHdr.Code != 0 {
+		l.icmpHdr.Code = dl.icmpHdr.Code
+	}
+	if l.icmpHdr.Identifier == 0 && dl.icmpHdr.Identifier != 0 {
+		l.icmpHdr.Identifier = dl.icmpHdr.Identifier
+	}
+	if l.icmpHdr.SeqNum == 0 && dl.icmpHdr.SeqNum != 0 {
+		l.icmpHdr.SeqNum = dl.icmpHdr.SeqNum
+	}
+	if isIPv6Zero(l.target) && !isIPv6Zero(dl.target) {
+		l.target = dl.target
+	}
+	if len(l.srcLL) == 0 && len(dl.srcLL) > 0 {
+		l.srcLL = dl.srcLL
+	}
+	if len(l.dstLL) == 0 && len(dl.dstLL) > 0 {
+		l.dstLL = dl.dstLL
+	}
+	l.hdr.proto.length = l.length()
 
 	return nil
 }
 
 func (l *ICMPv6Layer) WriteLayer() error {
 
+	fr := l.hdr.fr
+	if fr == nil {
+		return nil
+	}
+
+	data := fr.frame
+	data.Append(l.icmpHdr.Type)
+	data.Append(l.icmpHdr.Code)
+	data.Append(uint16(0)) // force checksum to zero, update later
+	data.Append(l.icmpHdr.Identifier)
+	data.Append(l.icmpHdr.SeqNum)
+
+	switch l.icmpHdr.Type {
+	case ICMPv6NeighborSolicitation, ICMPv6NeighborAdvertisement:
+		data.Append([]byte(l.target.To16()))
+	}
+
+	if len(l.srcLL) > 0 && l.icmpHdr.Type != ICMPv6NeighborAdvertisement {
+		data.Append(uint8(ICMPv6OptSrcLLAddr))
+		data.Append(uint8(ICMPv6NDOptLen >> 3))
+		data.Append(l.srcLL)
+	}
+	if len(l.dstLL) > 0 && l.icmpHdr.Type == ICMPv6NeighborAdvertisement {
+		data.Append(uint8(ICMPv6OptDstLLAddr))
+		data.Append(uint8(ICMPv6NDOptLen >> 3))
+		data.Append(l.dstLL)
+	}
+
+	return nil
+}
+
+// updateChecksum computes the ICMPv6 checksum using the IPv6 layer carrying the message.
+func (l *ICMPv6Layer) updateChecksum() error {
+
+	fr := l.hdr.fr
+	ip, ok := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv6Layer)
+	if !ok {
+		return nil
+	}
+
+	off := fr.protoOffset(&l.hdr.proto)
+	d := fr.frame.Bytes()[off+ICMPv6HeaderLen : off+fr.protoLength(&l.hdr.proto)]
+	cksum := IPv6ICMPChecksum(&ip.ip6Hdr, &l.icmpHdr, d)
+
+	return fr.frame.WriteValueAt(int(off+ICMPChecksumOffset), cksum)
+}
+
+// decodeNDOptions decodes the neighbor discovery options, only a single link-layer
+// address option is supported by the layer.
+func (l *ICMPv6Layer) decodeNDOptions(data []byte) bool {
+
+	if len(data) == 0 {
+		return true
+	}
+	if len(data) != ICMPv6NDOptLen || data[1] != ICMPv6NDOptLen>>3 {
+		return false
+	}
+
+	mac := net.HardwareAddr(bytes.Clone(data[2:ICMPv6NDOptLen]))
+	switch {
+	case data[0] == ICMPv6OptSrcLLAddr && l.icmpHdr.Type != ICMPv6NeighborAdvertisement:
+		l.srcLL = mac
+	case data[0] == ICMPv6OptDstLLAddr && l.icmpHdr.Type == ICMPv6NeighborAdvertisement:
+		l.dstLL = mac
+	default:
+		return false
+	}
+	return true
+}
+
+// decodeValid returns true if the data slice contains an ICMPv6 message the layer can
+// represent with a valid checksum, the message fields are decoded into the layer.
+func (l *ICMPv6Layer) decodeValid(data []byte) bool {
+
+	ip, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv6Layer)
+	if !ok || len(data) < ICMPv6HeaderLen {
+		return false
+	}
+
+	h := &l.icmpHdr
+	h.Type = data[0]
+	h.Code = data[1]
+	h.Cksum = binary.BigEndian.Uint16(data[2:])
+	h.Identifier = binary.BigEndian.Uint16(data[4:])
+	h.SeqNum = binary.BigEndian.Uint16(data[6:])
+
+	if IPv6ICMPChecksum(&ip.ip6Hdr, h, data[ICMPv6HeaderLen:]) != h.Cksum {
+		return false
+	}
+
+	switch h.Type {
+	case ICMPv6RouterSolicitation:
+		return h.Code == 0 && h.Identifier == 0 && h.SeqNum == 0 &&
+			l.decodeNDOptions(data[ICMPv6HeaderLen:])
+	case ICMPv6NeighborSolicitation, ICMPv6NeighborAdvertisement:
+		if h.Code != 0 || h.Identifier&0x1FFF != 0 || h.SeqNum != 0 ||
+			len(data) < ICMPv6HeaderLen+net.IPv6len {
+			return false
+		}
+		if h.Type == ICMPv6NeighborSolicitation && h.Identifier != 0 {
+			return false
+		}
+		l.target = net.IP(bytes.Clone(data[ICMPv6HeaderLen : ICMPv6HeaderLen+net.IPv6len]))
+		return l.decodeNDOptions(data[ICMPv6HeaderLen+net.IPv6len:])
+	}
+	return true
+}
+
+// Decode the ICMPv6 message from the binary frame data, the neighbor discovery messages
+// include the target address and options. The decodeValid() must be called first.
+func (l *ICMPv6Layer) Decode(data []byte) (int, error) {
+
+	if len(data) < ICMPv6HeaderLen {
+		return 0, fmt.Errorf("icmpv6 header too short: %d bytes", len(data))
+	}
+	if l.target == nil {
+		l.target = net.IPv6zero
+	}
+
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
+	l.hdr.proto.length = l.length()
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
-	return nil
+	return int(l.length()), nil
 }
@@ -0,0 +1,154 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The IMIX() layer expands the frame into a weighted mix of frame sizes, the payload length
+// of each frame is set to give the frame size, i.e., Ether()/IPv4()/UDP()/IMIX(simple)
+//
+// simple - is the simple IMIX of 64, 594 and 1518 byte frames with the weights 7:4:1.
+// <size>:<weight> - is a list of frame sizes and weights, i.e., IMIX(64:7, 594:4, 1518:1).
+//
+// The frame sizes include the 4 byte FCS. The frames are returned by Frame.Variants() in a
+// smooth weighted order, which spreads the frames of a size between the other sizes, and
+// the Count() is set to the sum of the weights when the count is smaller.
+
+const (
+	IMIXMaxFrames = 65536 // Maximum sum of the IMIX weights
+)
+
+// IMIXEntry is a frame size and the weight of the frame size in the IMIX.
+type IMIXEntry struct {
+	Size   uint16 // Frame size including the FCS
+	Weight uint32 // Number of frames of the size
+}
+
+// imixSimple is the simple IMIX using 40, 576 and 1500 byte IPv4 packets.
+var imixSimple = []IMIXEntry{{Size: 64, Weight: 7}, {Size: 594, Weight: 4}, {Size: 1518, Weight: 1}}
+
+type IMIXLayer struct {
+	hdr      *LayerHdr
+	name     string      // Name of the IMIX preset or empty for a custom list
+	entries  []IMIXEntry // Frame sizes and weights
+	sequence []uint16    // Frame size of each frame in order
+}
+
+func (l *IMIXLayer) String() string {
+
+	if l.name != "" {
+		return fmt.Sprintf("%s(%s)", l.Name(), l.name)
+	}
+	list := make([]string, 0, len(l.entries))
+	for _, e := range l.entries {
+		list = append(list, fmt.Sprintf("%d:%d", e.Size, e.Weight))
+	}
+	return fmt.Sprintf("%s(%s)", l.Name(), strings.Join(list, ", "))
+}
+
+func IMIXNew(fr *Frame) *IMIXLayer {
+	return &IMIXLayer{
+		hdr: LayerConstructor(fr, LayerIMIX, LayerIMIXType),
+	}
+}
+
+func (l *IMIXLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func (l *IMIXLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	total := uint32(0)
+	for _, opt := range options {
+		opt = strings.ToLower(strings.TrimSpace(opt))
+		if len(opt) == 0 {
+			continue
+		}
+
+		if opt == "simple" {
+			if len(options) > 1 {
+				return fmt.Errorf("imix simple can not be used with other frame sizes")
+			}
+			l.name = opt
+			l.entries = append(l.entries, imixSimple...)
+			continue
+		}
+
+		size, weight, found := strings.Cut(opt, ":")
+		if !found {
+			return fmt.Errorf("imix option must be simple or <size>:<weight>: [%s]", opt)
+		}
+		s, err := strconv.ParseUint(strings.TrimSpace(size), 0, 16)
+		if err != nil || s == 0 {
+			return fmt.Errorf("invalid imix frame size: [%s]", opt)
+		}
+		w, err := strconv.ParseUint(strings.TrimSpace(weight), 0, 32)
+		if err != nil || w == 0 {
+			return fmt.Errorf("invalid imix weight: [%s]", opt)
+		}
+		if total += uint32(w); total > IMIXMaxFrames {
+			return fmt.Errorf("imix weights total more than %d frames", IMIXMaxFrames)
+		}
+		l.entries = append(l.entries, IMIXEntry{Size: uint16(s), Weight: uint32(w)})
+	}
+	if len(l.entries) == 0 {
+		return fmt.Errorf("imix needs simple or a list of <size>:<weight>")
+	}
+
+	l.sequence = imixSequence(l.entries)
+
+	return nil
+}
+
+// imixSequence returns the frame sizes in a smooth weighted round robin order, the number
+// of frames of each size is the weight of the size.
+func imixSequence(entries []IMIXEntry) []uint16 {
+
+	total := 0
+	for _, e := range entries {
+		total += int(e.Weight)
+	}
+
+	seq := make([]uint16, 0, total)
+	current := make([]int, len(entries))
+	for n := 0; n < total; n++ {
+		best := 0
+		for i, e := range entries {
+			current[i] += int(e.Weight)
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		seq = append(seq, entries[best].Size)
+	}
+	return seq
+}
+
+// frameSize returns the frame size of the given Count() frame.
+func (l *IMIXLayer) frameSize(variant int) int {
+	return int(l.sequence[variant%len(l.sequence)])
+}
+
+// Entries returns the frame sizes and weights of the IMIX.
+func (l *IMIXLayer) Entries() []IMIXEntry {
+	return l.entries
+}
+
+func (l *IMIXLayer) ApplyDefaults() error {
+
+	return nil
+}
+
+func (l *IMIXLayer) WriteLayer() error {
+
+	return nil
+}
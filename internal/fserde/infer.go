@@ -0,0 +1,97 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+)
+
+// The protocol fields giving the type of the following layer are inferred from the
+// layers of the frame after the default frame values are applied:
+//
+// Ether - the EtherType of the layer following the Ether layer and any VLAN tags.
+// Vlan - the TPID of the tag, which is the service tag TPID when followed by another tag.
+// IPv4, IPv6 - the protocol or next header of the following layer, i.e., UDP or IPv4.
+// GRE, Geneve - the protocol type of the inner layer, i.e., IPv4, IPv6 or Ether.
+//
+// A value given in the frame string is kept, when the value does not match the following
+// layer a warning is added to the frame and returned by Frame.Warnings(). A value from the
+// default frame is only used when the following layer does not give a value.
+
+// toBinaryInferProtocols sets the protocol fields of the layers from the following layers.
+func (fr *Frame) toBinaryInferProtocols() error {
+
+	for _, li := range fr.layerInfo {
+		switch l := li.Layer.(type) {
+		case *EtherLayer:
+			l.inferProtocol()
+		case *VlanLayer:
+			l.inferTPID()
+		case *IPv4Layer:
+			l.ipHdr.Protocol = fr.inferField(&l.hdr.proto, "protocol", l.ipHdr.Protocol,
+				fr.nextProtocolID(&l.hdr.proto), l.protoSet)
+		case *IPv6Layer:
+			l.ip6Hdr.NextHeader = fr.inferField(&l.hdr.proto, "nextheader", l.ip6Hdr.NextHeader,
+				fr.nextProtocolID(&l.hdr.proto), l.nhSet)
+		case *GRELayer:
+			l.greHdr.Protocol = uint16(fr.inferField(&l.hdr.proto, "proto", int(l.greHdr.Protocol),
+				int(fr.nextEtherType(&l.hdr.proto)), l.protoSet))
+		case *GeneveLayer:
+			l.protocol = uint16(fr.inferField(&l.hdr.proto, "proto", int(l.protocol),
+				int(fr.nextEtherType(&l.hdr.proto)), l.protoSet))
+		}
+	}
+
+	return nil
+}
+
+// inferField returns the value of the protocol field, which is the value of the following
+// layer unless the value was given in the frame string. A zero value of the following layer
+// means the following layer does not give a value.
+func (fr *Frame) inferField(proto *ProtoInfo, field string, val, next int, given bool) int {
+
+	if next == 0 {
+		return val
+	}
+	if !given {
+		return next
+	}
+	if val != next {
+		name := proto.name
+		if proto.index > 0 {
+			name = LayerName(fmt.Sprintf("%s[%d]", proto.name, proto.index))
+		}
+		fr.warnings = append(fr.warnings, fmt.Sprintf("%s %s=%#x does not match the next layer %s, expected %#x",
+			name, field, val, fr.nextProtocol(proto).name, next))
+	}
+	return val
+}
+
+// inferProtocol sets the EtherType from the layer following the Ether layer and the VLAN
+// tags, the MPLS EtherType is the multicast type when the destination MAC is multicast.
+func (l *EtherLayer) inferProtocol() {
+
+	fr := l.hdr.fr
+
+	next := fr.nextProtocol(&l.hdr.proto)
+	for next != nil && isVlanLayer(next.name) {
+		next = fr.nextProtocol(next)
+	}
+
+	etherType := uint16(0)
+	if next != nil && next.name != LayerEther {
+		etherType = layerEtherTypes[next.name]
+	}
+	if next != nil && next.name == LayerMPLS {
+		switch {
+		case l.protoSet && (l.ether.EtherType == EtherTypeMPLS || l.ether.EtherType == EtherTypeMPLSMc):
+			etherType = l.ether.EtherType
+		case len(l.ether.DstMac) > 0 && l.ether.DstMac[0]&0x01 != 0:
+			etherType = EtherTypeMPLSMc
+		}
+	}
+
+	l.ether.EtherType = uint16(fr.inferField(&l.hdr.proto, "proto", int(l.ether.EtherType), int(etherType), l.protoSet))
+}
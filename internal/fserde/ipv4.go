@@ -5,6 +5,8 @@
 package fserde
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"net"
 	"strconv"
@@ -20,17 +22,29 @@ const (
 )
 
 type IPv4Layer struct {
-	hdr   *LayerHdr
-	ipHdr ipv4.Header
+	hdr         *LayerHdr
+	ipHdr       ipv4.Header
+	protoSet    bool             // Protocol was given in the options
+	totalLenSet bool             // Total length was given in the options and is not computed
+	cksum       checksumOverride // Header checksum given in the options
+	options     []ipv4Option     // Header options given in the opts option
 }
 
 func (ip *IPv4Layer) String() string {
-	return fmt.Sprintf("%s(version=%d, len=%d, tos=%d, totallen=%d, id=%d, "+
-		"flags=%#x, frag=%#x, ttl=%d, protocol=%d, checksum=%#x, src=%v, dst=%v)",
+	s := fmt.Sprintf("%s(ver=%d, len=%d, tos=%#x, id=%d, flags=%#x, fragoff=%d, ttl=%d, protocol=%d, src=%v, dst=%v",
 		ip.Name(),
-		ip.ipHdr.Version, ip.ipHdr.Len, ip.ipHdr.TOS, ip.ipHdr.TotalLen, ip.ipHdr.ID,
-		ip.ipHdr.Flags, ip.ipHdr.FragOff, ip.ipHdr.TTL, ip.ipHdr.Protocol, ip.ipHdr.Checksum,
-		ip.ipHdr.Src, ip.ipHdr.Dst)
+		ip.ipHdr.Version, ip.ipHdr.Len, ip.ipHdr.TOS, ip.ipHdr.ID, int(ip.ipHdr.Flags), ip.ipHdr.FragOff,
+		ip.ipHdr.TTL, ip.ipHdr.Protocol, ip.ipHdr.Src, ip.ipHdr.Dst)
+	if len(ip.options) > 0 {
+		s += fmt.Sprintf(", opts=%s", formatIPv4Options(ip.options))
+	}
+	if ip.totalLenSet {
+		s += fmt.Sprintf(", totallen=%d", ip.ipHdr.TotalLen)
+	}
+	if ip.cksum.set {
+		s += fmt.Sprintf(", checksum=%v", ip.cksum)
+	}
+	return s + ")"
 }
 
 func (e *IPv4Layer) Name() LayerName {
@@ -49,17 +63,22 @@ func isIPZero(ip net.IP) bool {
 
 func (ip *IPv4Layer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
 
+	idSet, ttlSet := false, false
+	hdrLen := 0
 	for _, opt := range options {
 		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
 
-		kvp := strings.Split(opt, "=")
-		if len(kvp) != 2 {
-			return fmt.Errorf("invalid options string")
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
 		}
-		key := strings.ToLower(strings.TrimSpace(kvp[0]))
-		val := strings.ToLower(strings.TrimSpace(kvp[1]))
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
 
 		switch key {
 		case "ver":
@@ -71,20 +90,31 @@ func (ip *IPv4Layer) Parse(opts string) error {
 				ip.ipHdr.Version = int(ver)
 			}
 
+		case "len", "hdrlen":
+			if hl, err := strconv.ParseInt(val, 0, 0); err != nil {
+				return err
+			} else {
+				hdrLen = int(hl)
+			}
+
+		case "opts", "options":
+			if ip.options, err = parseIPv4Options(val); err != nil {
+				return err
+			}
+
 		case "tos":
-			if tos, err := strconv.ParseInt(val, 0, 0); err != nil {
+			if tos, err := strconv.ParseUint(val, 0, 8); err != nil {
 				return err
-			} else if tos == 0 {
-				return fmt.Errorf("invalid TOS")
 			} else {
 				ip.ipHdr.TOS = int(tos)
 			}
 
 		case "id":
-			if id, err := strconv.ParseInt(val, 0, 0); err != nil {
+			if id, err := strconv.ParseUint(val, 0, 16); err != nil {
 				return err
 			} else {
 				ip.ipHdr.ID = int(id)
+				idSet = true
 			}
 
 		case "flags":
@@ -94,7 +124,7 @@ func (ip *IPv4Layer) Parse(opts string) error {
 				ip.ipHdr.Flags = ipv4.HeaderFlags(flags)
 			}
 
-		case "fragOffset":
+		case "fragoff", "fragoffset", "frag":
 			if fragOffset, err := strconv.ParseInt(val, 0, 0); err != nil {
 				return err
 			} else {
@@ -106,6 +136,7 @@ func (ip *IPv4Layer) Parse(opts string) error {
 				return err
 			} else {
 				ip.ipHdr.TTL = int(ttl)
+				ttlSet = true
 			}
 
 		case "protocol":
@@ -113,6 +144,20 @@ func (ip *IPv4Layer) Parse(opts string) error {
 				return err
 			} else {
 				ip.ipHdr.Protocol = int(protocol)
+				ip.protoSet = true
+			}
+
+		case "totallen", "totallength":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				ip.ipHdr.TotalLen = int(v)
+				ip.totalLenSet = true
+			}
+
+		case "checksum", "cksum":
+			if ip.cksum, err = parseChecksumOverride(val); err != nil {
+				return err
 			}
 
 		case "src":
@@ -122,14 +167,17 @@ func (ip *IPv4Layer) Parse(opts string) error {
 			ip.ipHdr.Dst = net.ParseIP(val)
 
 		default:
-			return fmt.Errorf("unknown ether option: [%s]", opt)
+			return fmt.Errorf("unknown ipv4 option: [%s]", opt)
 		}
 	}
 
-	if ip.ipHdr.ID == 0 {
+	if ip.ipHdr.Version == 0 {
+		ip.ipHdr.Version = ipv4.Version
+	}
+	if ip.ipHdr.ID == 0 && !idSet {
 		ip.ipHdr.ID = IPv4DefaultID
 	}
-	if ip.ipHdr.TTL == 0 {
+	if ip.ipHdr.TTL == 0 && !ttlSet {
 		ip.ipHdr.TTL = DefaultTTL
 	}
 	if isIPZero(ip.ipHdr.Dst) {
@@ -142,8 +190,14 @@ func (ip *IPv4Layer) Parse(opts string) error {
 	// Set the protocol header length include option bytes and the TotalLen
 	// with the header length. The TotalLen will be updated after the other
 	// layers are parsed.
-	ip.ipHdr.Len = IPv4MinLen
-	ip.ipHdr.TotalLen = ip.ipHdr.Len
+	ip.ipHdr.Options = encodeIPv4Options(ip.options)
+	ip.ipHdr.Len = IPv4MinLen + len(ip.ipHdr.Options)
+	if hdrLen != 0 && hdrLen != ip.ipHdr.Len {
+		return fmt.Errorf("invalid header length: %v, the header with options is %d bytes", hdrLen, ip.ipHdr.Len)
+	}
+	if !ip.totalLenSet {
+		ip.ipHdr.TotalLen = ip.ipHdr.Len
+	}
 
 	ip.hdr.proto.name = ip.Name()
 	ip.hdr.proto.offset = ip.hdr.fr.GetOffset(ip.Name())
@@ -161,7 +215,7 @@ func (l *IPv4Layer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerIPv4).(*IPv4Layer)
+	dl, ok := d.GetLayerIndex(LayerIPv4, l.hdr.index).(*IPv4Layer)
 	if !ok {
 		return nil
 	}
@@ -192,39 +246,109 @@ func (l *IPv4Layer) ApplyDefaults() error {
 	if isIPZero(l.ipHdr.Src) && !isIPZero(dl.ipHdr.Src) {
 		l.ipHdr.Src = dl.ipHdr.Src
 	}
+	if !l.cksum.set && dl.cksum.set {
+		l.cksum = dl.cksum
+	}
+	if len(l.options) == 0 && len(dl.options) > 0 {
+		l.options = dl.options
+		l.ipHdr.Options = encodeIPv4Options(l.options)
+	}
 
 	// Set the protocol header length include option bytes and the TotalLen
 	// with the header length. The TotalLen will be updated after the other
 	// layers are parsed.
-	l.ipHdr.Len = IPv4MinLen
-	l.ipHdr.TotalLen = l.ipHdr.Len
+	l.ipHdr.Len = IPv4MinLen + len(l.ipHdr.Options)
+	l.hdr.proto.length = uint16(l.ipHdr.Len)
+	if !l.totalLenSet {
+		l.ipHdr.TotalLen = l.ipHdr.Len
+	}
 
 	return nil
 }
 
 func (l *IPv4Layer) WriteLayer() error {
 
-	ip := &l.ipHdr
-	fr := l.hdr.fr
-	frame := fr.frame
+	l.hdr.fr.frame.Append(l.marshal(&l.ipHdr))
+
+	return nil
+}
+
+// marshal returns the bytes of the IPv4 header with the header checksum, the header is the
+// layer header or the header of a fragment.
+func (l *IPv4Layer) marshal(ip *ipv4.Header) []byte {
+
+	frame := &MyBuffer{}
 
 	frame.WriteByte(uint8(ip.Version<<4) | (uint8(ip.Len&0xFF) >> 2))
 	frame.Append(uint8(ip.TOS))
 
 	frame.Append(uint16(ip.TotalLen))
 	frame.Append(uint16(ip.ID))
-	frame.Append(uint16(ip.Flags<<12) | (uint16(ip.FragOff)))
+	frame.Append(uint16(ip.Flags<<13) | (uint16(ip.FragOff)))
 	frame.Append(uint8(ip.TTL))
 
-	ip.Protocol = fr.GetProtocolID()
 	frame.Append(uint8(ip.Protocol))
 
-	cksum := IPv4HeaderChecksum(ip)
+	cksum := l.cksum.checksum(IPv4HeaderChecksum(ip))
 	frame.Append(cksum)
 
 	frame.Append(ip.Src)
 	frame.Append(ip.Dst)
 	frame.Append(ip.Options)
 
-	return nil
+	return frame.Bytes()
+}
+
+// decodeValid returns true if the data slice contains an IPv4 header the IPv4 layer can
+// represent, which is a header with known options and a valid header checksum.
+func (l *IPv4Layer) decodeValid(data []byte) bool {
+
+	if len(data) < IPv4MinLen || data[0]>>4 != ipv4.Version {
+		return false
+	}
+	hl := int(data[0]&0x0F) << 2
+	totalLen := int(binary.BigEndian.Uint16(data[2:]))
+	if hl < IPv4MinLen || totalLen < hl || totalLen > len(data) {
+		return false
+	}
+	if _, ok := decodeIPv4Options(data[IPv4MinLen:hl]); !ok {
+		return false
+	}
+
+	return ^reduceChecksum(dataChecksum(data[:hl], hl)) == 0
+}
+
+// Decode the IPv4 header from the binary frame data.
+func (l *IPv4Layer) Decode(data []byte) (int, error) {
+
+	if len(data) < IPv4MinLen {
+		return 0, fmt.Errorf("ipv4 header too short: %d bytes", len(data))
+	}
+
+	ip := &l.ipHdr
+	ip.Version = int(data[0] >> 4)
+	ip.Len = int(data[0]&0x0F) << 2
+	ip.TOS = int(data[1])
+	ip.TotalLen = int(binary.BigEndian.Uint16(data[2:]))
+	ip.ID = int(binary.BigEndian.Uint16(data[4:]))
+	ip.Flags = ipv4.HeaderFlags(data[6] >> 5)
+	ip.FragOff = int(binary.BigEndian.Uint16(data[6:]) & 0x1FFF)
+	ip.TTL = int(data[8])
+	ip.Protocol = int(data[9])
+	ip.Checksum = int(binary.BigEndian.Uint16(data[10:]))
+	ip.Src = net.IP(bytes.Clone(data[12:16]))
+	ip.Dst = net.IP(bytes.Clone(data[16:20]))
+
+	if ip.Len > IPv4MinLen && ip.Len <= len(data) {
+		ip.Options = bytes.Clone(data[IPv4MinLen:ip.Len])
+		l.options, _ = decodeIPv4Options(ip.Options)
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(ip.Len & 0xFF)
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return ip.Len, nil
 }
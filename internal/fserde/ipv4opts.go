@@ -0,0 +1,262 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The IPv4(opts=[...]) option is a list of IPv4 header options, the options are padded
+// with zero bytes to a multiple of 4 bytes and the header length is set to include the
+// options, i.e., IPv4(opts=[ra, rr{slots=4}]).
+//
+// eol, nop - are the end of option list and no operation options.
+// ra{value=N} - is the router alert option, value defaults to zero.
+// rr{slots=N} - is the record route option with N empty address slots, defaults to 9.
+// ts{slots=N, flag=F} - is the timestamp option with N empty slots, defaults to 4, the
+//                       flag is 0 for timestamps only, 1 or 3 for address and timestamp.
+
+const (
+	IPv4OptEOL    = 0   // End of option list
+	IPv4OptNOP    = 1   // No operation
+	IPv4OptRR     = 7   // Record route
+	IPv4OptTS     = 68  // Timestamp
+	IPv4OptRA     = 148 // Router alert
+	IPv4MaxOptLen = 40  // Maximum length of the IPv4 options
+
+	ipv4OptCopied     = 0x80 // Option is copied into all fragments
+	ipv4OptRRSlots    = 9    // Default number of record route slots
+	ipv4OptTSSlots    = 4    // Default number of timestamp slots
+	ipv4OptTSOnly     = 0    // Timestamp flag for timestamps only
+	ipv4OptTSAddr     = 1    // Timestamp flag for address and timestamp pairs
+	ipv4OptTSPrespec  = 3    // Timestamp flag for prespecified addresses
+	ipv4OptRRPointer  = 4    // Pointer of an empty record route option
+	ipv4OptTSPointer  = 5    // Pointer of an empty timestamp option
+	ipv4OptRALen      = 4    // Length of the router alert option
+	ipv4OptRRHdrLen   = 3    // Length of the record route option without the slots
+	ipv4OptTSHdrLen   = 4    // Length of the timestamp option without the slots
+	ipv4OptAddrLen    = 4    // Length of an address slot
+	ipv4OptAddrTSLen  = 8    // Length of an address and timestamp slot
+	ipv4OptPaddingMul = 4    // Options are padded to a multiple of 4 bytes
+)
+
+// ipv4Option is an IPv4 header option given in the opts=[...] option.
+type ipv4Option struct {
+	kind  uint8  // Option type
+	slots int    // Number of record route or timestamp slots
+	flag  uint8  // Timestamp flag
+	value uint16 // Router alert value
+}
+
+var ipv4OptNames = map[uint8]string{
+	IPv4OptEOL: "eol",
+	IPv4OptNOP: "nop",
+	IPv4OptRR:  "rr",
+	IPv4OptTS:  "ts",
+	IPv4OptRA:  "ra",
+}
+
+func (o ipv4Option) String() string {
+
+	switch o.kind {
+	case IPv4OptRR:
+		return fmt.Sprintf("rr{slots=%d}", o.slots)
+	case IPv4OptTS:
+		return fmt.Sprintf("ts{slots=%d, flag=%d}", o.slots, o.flag)
+	case IPv4OptRA:
+		if o.value != 0 {
+			return fmt.Sprintf("ra{value=%d}", o.value)
+		}
+	}
+	return ipv4OptNames[o.kind]
+}
+
+// formatIPv4Options returns the options in the opts=[...] format.
+func formatIPv4Options(opts []ipv4Option) string {
+
+	list := make([]string, 0, len(opts))
+	for _, o := range opts {
+		list = append(list, o.String())
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// parseIPv4Options parses the opts=[...] option value into the list of options.
+func parseIPv4Options(val string) ([]ipv4Option, error) {
+
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, "]") {
+		return nil, fmt.Errorf("ipv4 opts must be a list [...]: %s", val)
+	}
+
+	opts := make([]ipv4Option, 0)
+	for _, item := range splitOptions(val[1 : len(val)-1]) {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		name, args, found := strings.Cut(item, "{")
+		if found {
+			if !strings.HasSuffix(args, "}") {
+				return nil, fmt.Errorf("ipv4 option missing '}': %s", item)
+			}
+			args = args[:len(args)-1]
+		}
+
+		var o ipv4Option
+		switch strings.TrimSpace(name) {
+		case "eol":
+			o.kind = IPv4OptEOL
+		case "nop":
+			o.kind = IPv4OptNOP
+		case "rr":
+			o = ipv4Option{kind: IPv4OptRR, slots: ipv4OptRRSlots}
+		case "ts":
+			o = ipv4Option{kind: IPv4OptTS, slots: ipv4OptTSSlots}
+		case "ra":
+			o.kind = IPv4OptRA
+		default:
+			return nil, fmt.Errorf("unknown ipv4 header option: %s", item)
+		}
+
+		for _, arg := range splitOptions(args) {
+			if len(strings.TrimSpace(arg)) == 0 {
+				continue
+			}
+			key, v, err := splitKeyValue(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case key == "slots" && (o.kind == IPv4OptRR || o.kind == IPv4OptTS):
+				if n, err := strconv.ParseUint(v, 0, 8); err != nil || n == 0 {
+					return nil, fmt.Errorf("invalid ipv4 option slots: %s", item)
+				} else {
+					o.slots = int(n)
+				}
+			case key == "flag" && o.kind == IPv4OptTS:
+				if n, err := strconv.ParseUint(v, 0, 4); err != nil ||
+					(n != ipv4OptTSOnly && n != ipv4OptTSAddr && n != ipv4OptTSPrespec) {
+					return nil, fmt.Errorf("invalid ipv4 timestamp flag: %s", item)
+				} else {
+					o.flag = uint8(n)
+				}
+			case key == "value" && o.kind == IPv4OptRA:
+				if n, err := strconv.ParseUint(v, 0, 16); err != nil {
+					return nil, err
+				} else {
+					o.value = uint16(n)
+				}
+			default:
+				return nil, fmt.Errorf("unknown ipv4 header option argument: %s", item)
+			}
+		}
+		opts = append(opts, o)
+	}
+
+	if n := len(encodeIPv4Options(opts)); n > IPv4MaxOptLen {
+		return nil, fmt.Errorf("ipv4 options are %d bytes, more than %d bytes", n, IPv4MaxOptLen)
+	}
+	return opts, nil
+}
+
+// encodeIPv4Options returns the option bytes padded with zeros to a multiple of 4 bytes.
+func encodeIPv4Options(opts []ipv4Option) []byte {
+
+	b := make([]byte, 0, IPv4MaxOptLen)
+	for _, o := range opts {
+		switch o.kind {
+		case IPv4OptEOL, IPv4OptNOP:
+			b = append(b, o.kind)
+		case IPv4OptRA:
+			b = append(b, o.kind, ipv4OptRALen, uint8(o.value>>8), uint8(o.value))
+		case IPv4OptRR:
+			n := ipv4OptRRHdrLen + o.slots*ipv4OptAddrLen
+			b = append(b, o.kind, uint8(n), ipv4OptRRPointer)
+			b = append(b, make([]byte, n-ipv4OptRRHdrLen)...)
+		case IPv4OptTS:
+			slotLen := ipv4OptAddrTSLen
+			if o.flag == ipv4OptTSOnly {
+				slotLen = ipv4OptAddrLen
+			}
+			n := ipv4OptTSHdrLen + o.slots*slotLen
+			b = append(b, o.kind, uint8(n), ipv4OptTSPointer, o.flag)
+			b = append(b, make([]byte, n-ipv4OptTSHdrLen)...)
+		}
+	}
+	if n := len(b) % ipv4OptPaddingMul; n != 0 {
+		b = append(b, make([]byte, ipv4OptPaddingMul-n)...)
+	}
+	return b
+}
+
+// copiedIPv4Options returns the options with the copied flag set, which are the options
+// of the fragments following the first fragment.
+func copiedIPv4Options(opts []ipv4Option) []ipv4Option {
+
+	copied := make([]ipv4Option, 0)
+	for _, o := range opts {
+		if o.kind&ipv4OptCopied != 0 {
+			copied = append(copied, o)
+		}
+	}
+	return copied
+}
+
+// decodeIPv4Options decodes the option bytes of an IPv4 header, false is returned when the
+// options can not be represented by the opts=[...] option.
+func decodeIPv4Options(data []byte) ([]ipv4Option, bool) {
+
+	opts := make([]ipv4Option, 0)
+	for i := 0; i < len(data); {
+		o := ipv4Option{kind: data[i]}
+
+		switch o.kind {
+		case IPv4OptEOL:
+			// The end of the list is the padding when the rest of the bytes are zero
+			if bytes.Count(data[i:], []byte{0}) == len(data[i:]) && len(data[i:]) < ipv4OptPaddingMul {
+				return opts, bytes.Equal(encodeIPv4Options(opts), data)
+			}
+			i++
+		case IPv4OptNOP:
+			i++
+		case IPv4OptRA, IPv4OptRR, IPv4OptTS:
+			if i+1 >= len(data) || int(data[i+1]) < 2 || i+int(data[i+1]) > len(data) {
+				return nil, false
+			}
+			n := int(data[i+1])
+			switch o.kind {
+			case IPv4OptRA:
+				if n != ipv4OptRALen {
+					return nil, false
+				}
+				o.value = uint16(data[i+2])<<8 | uint16(data[i+3])
+			case IPv4OptRR:
+				o.slots = (n - ipv4OptRRHdrLen) / ipv4OptAddrLen
+			case IPv4OptTS:
+				if n < ipv4OptTSHdrLen {
+					return nil, false
+				}
+				o.flag = data[i+3] & 0x0F
+				if o.flag == ipv4OptTSOnly {
+					o.slots = (n - ipv4OptTSHdrLen) / ipv4OptAddrLen
+				} else {
+					o.slots = (n - ipv4OptTSHdrLen) / ipv4OptAddrTSLen
+				}
+			}
+			i += n
+		default:
+			return nil, false
+		}
+		opts = append(opts, o)
+	}
+
+	// The options must encode to the same bytes, i.e., the route and timestamp slots are empty
+	return opts, bytes.Equal(encodeIPv4Options(opts), data)
+}
@@ -5,18 +5,42 @@
 package fserde
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 
 	"golang.org/x/net/ipv6"
 )
 
+// The IPv6() protocol layer has a number of protocol-value options zero or more
+// options may be specified.
+//
+// dst, src - are the destination and source IPv6 addresses.
+// [tc|trafficclass] - is the traffic class value.
+// [flowlabel|fl] - is the 20 bit flow label value.
+// [hlim|hoplimit|ttl] - is the hop limit value, defaults to 64.
+// [nh|nextheader|protocol] - is the next header value, defaults to the protocol of the
+//                            following layer.
+
+const (
+	IPv6DefaultHopLimit = 64
+)
+
 type IPv6Layer struct {
-	hdr    *LayerHdr
-	ip6Hdr ipv6.Header
+	hdr     *LayerHdr
+	ip6Hdr  ipv6.Header
+	hlimSet bool // Hop limit was given in the options
+	nhSet   bool // Next header was given in the options
 }
 
 func (l *IPv6Layer) String() string {
-	return fmt.Sprintf("%s()", l.hdr.layerName)
+	ip := l.ip6Hdr
+
+	return fmt.Sprintf("%s(tc=%#x, flowlabel=%#x, hlim=%d, nh=%d, src=%v, dst=%v)", l.Name(),
+		ip.TrafficClass, ip.FlowLabel, ip.HopLimit, ip.NextHeader, ip.Src, ip.Dst)
 }
 
 func IPv6New(fr *Frame) *IPv6Layer {
@@ -29,35 +53,204 @@ func (l *IPv6Layer) Name() LayerName {
 	return l.hdr.layerName
 }
 
+func isIPv6Zero(ip net.IP) bool {
+	return len(ip) == 0 || net.IP.Equal(net.IPv6zero, ip)
+}
+
+func parseIPv6(val string) (net.IP, error) {
+
+	ip := net.ParseIP(val)
+	if ip == nil || ip.To16() == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 address: %s", val)
+	}
+	return ip.To16(), nil
+}
+
 func (l *IPv6Layer) Parse(opts string) error {
 
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "ver":
+			if ver, err := strconv.ParseInt(val, 0, 0); err != nil {
+				return err
+			} else if ver != ipv6.Version {
+				return fmt.Errorf("invalid version: %v", ver)
+			}
+
+		case "tc", "trafficclass":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.TrafficClass = int(v)
+			}
+
+		case "flowlabel", "fl":
+			if v, err := strconv.ParseUint(val, 0, 20); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.FlowLabel = int(v)
+			}
+
+		case "hlim", "hoplimit", "ttl":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.HopLimit = int(v)
+				l.hlimSet = true
+			}
+
+		case "nh", "nextheader", "protocol":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.NextHeader = int(v)
+				l.nhSet = true
+			}
+
+		case "src":
+			if ip, err := parseIPv6(val); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.Src = ip
+			}
+
+		case "dst":
+			if ip, err := parseIPv6(val); err != nil {
+				return err
+			} else {
+				l.ip6Hdr.Dst = ip
+			}
+
+		default:
+			return fmt.Errorf("unknown ipv6 option: [%s]", opt)
+		}
+	}
+
+	l.ip6Hdr.Version = ipv6.Version
+	if isIPv6Zero(l.ip6Hdr.Src) {
+		l.ip6Hdr.Src = net.IPv6zero
+	}
+	if isIPv6Zero(l.ip6Hdr.Dst) {
+		l.ip6Hdr.Dst = net.IPv6zero
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = ipv6.HeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
 	return nil
 }
 
 func (l *IPv6Layer) ApplyDefaults() error {
 
+	// The hop limit of the defaults frame is used when given, else the default hop limit
+	hlimSet := l.hlimSet
+	defer func() {
+		if !hlimSet {
+			l.ip6Hdr.HopLimit = IPv6DefaultHopLimit
+		}
+	}()
+
 	d := l.hdr.fr.defaultsFrame
 	if d == nil {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerIPv6).(*IPv6Layer)
+	dl, ok := d.GetLayerIndex(LayerIPv6, l.hdr.index).(*IPv6Layer)
 	if !ok {
 		return nil
 	}
 
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
+	if l.ip6Hdr.TrafficClass == 0 && dl.ip6Hdr.TrafficClass != 0 {
+		l.ip6Hdr.TrafficClass = dl.ip6Hdr.TrafficClass
+	}
+	if l.ip6Hdr.FlowLabel == 0 && dl.ip6Hdr.FlowLabel != 0 {
+		l.ip6Hdr.FlowLabel = dl.ip6Hdr.FlowLabel
+	}
+	if !hlimSet && dl.hlimSet {
+		l.ip6Hdr.HopLimit = dl.ip6Hdr.HopLimit
+		hlimSet = true
+	}
+	if l.ip6Hdr.NextHeader == 0 && dl.ip6Hdr.NextHeader != 0 {
+		l.ip6Hdr.NextHeader = dl.ip6Hdr.NextHeader
+	}
+	if isIPv6Zero(l.ip6Hdr.Src) && !isIPv6Zero(dl.ip6Hdr.Src) {
+		l.ip6Hdr.Src = dl.ip6Hdr.Src
+	}
+	if isIPv6Zero(l.ip6Hdr.Dst) && !isIPv6Zero(dl.ip6Hdr.Dst) {
+		l.ip6Hdr.Dst = dl.ip6Hdr.Dst
+	}
 
 	return nil
 }
 
 func (l *IPv6Layer) WriteLayer() error {
 
+	ip := &l.ip6Hdr
+	fr := l.hdr.fr
+	frame := fr.frame
+
+	frame.Append(uint32(ip.Version)<<28 | uint32(ip.TrafficClass&0xFF)<<20 | uint32(ip.FlowLabel&0xFFFFF))
+	frame.Append(uint16(ip.PayloadLen))
+
+	frame.Append(uint8(ip.NextHeader))
+	frame.Append(uint8(ip.HopLimit))
+
+	frame.Append([]byte(ip.Src.To16()))
+	frame.Append([]byte(ip.Dst.To16()))
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains an IPv6 header with a payload
+// length within the data slice.
+func (l *IPv6Layer) decodeValid(data []byte) bool {
+
+	if len(data) < ipv6.HeaderLen || data[0]>>4 != ipv6.Version {
+		return false
+	}
+	return ipv6.HeaderLen+int(binary.BigEndian.Uint16(data[4:])) <= len(data)
+}
+
+// Decode the IPv6 header from the binary frame data.
+func (l *IPv6Layer) Decode(data []byte) (int, error) {
+
+	if len(data) < ipv6.HeaderLen {
+		return 0, fmt.Errorf("ipv6 header too short: %d bytes", len(data))
+	}
+
+	ip := &l.ip6Hdr
+	v := binary.BigEndian.Uint32(data[0:])
+	ip.Version = int(v >> 28)
+	ip.TrafficClass = int(v>>20) & 0xFF
+	ip.FlowLabel = int(v & 0xFFFFF)
+	ip.PayloadLen = int(binary.BigEndian.Uint16(data[4:]))
+	ip.NextHeader = int(data[6])
+	ip.HopLimit = int(data[7])
+	ip.Src = net.IP(bytes.Clone(data[8:24]))
+	ip.Dst = net.IP(bytes.Clone(data[24:40]))
+
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
+	l.hdr.proto.length = ipv6.HeaderLen
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
-	return nil
+	return ipv6.HeaderLen, nil
 }
@@ -0,0 +1,544 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The frame string is split into tokens by the lexer and checked by the parser before the
+// layer options are given to the layers. The grammar of a frame string is:
+//
+//	frame  := <name> ':=' layer { '/' layer }
+//	layer  := <layer-name> [ '(' [ option { ',' option } ] ')' ]
+//	option := value | <key> '=' value
+//	value  := { <text> | <quoted-string> | '(' ... ')' | '{' ... '}' | '[' ... ']' | '|' }
+//
+// A quoted string starts and ends with a ' or " character and may contain the delimiter
+// characters, the escapes \\, \', \", \n, \r, \t and \xHH are allowed in a quoted string.
+// The frame string may span more than one line and the errors give the frame name plus the
+// line and column of the error, i.e., frame Port0: line 2, column 12: unknown ipv4 option.
+
+// ParseError is the error returned when a frame string can not be parsed or encoded, the
+// line and column start at one and are zero when the position is not known.
+type ParseError struct {
+	Frame  string // Name of the frame or empty if the name is not known
+	Line   int    // Line number of the error
+	Column int    // Column number of the error
+	Err    error  // Error found at the position
+}
+
+func (e *ParseError) Error() string {
+
+	s := ""
+	if e.Frame != "" {
+		s = fmt.Sprintf("frame %s: ", e.Frame)
+	}
+	if e.Line > 0 {
+		s += fmt.Sprintf("line %d, column %d: ", e.Line, e.Column)
+	}
+	return s + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// position is the line and column of a token in the frame string.
+type position struct {
+	line int
+	col  int
+}
+
+// parseError returns a ParseError for the frame at the position.
+func parseError(frame string, pos position, format string, args ...interface{}) error {
+	return &ParseError{Frame: frame, Line: pos.line, Column: pos.col, Err: fmt.Errorf(format, args...)}
+}
+
+type tokenType int
+
+const (
+	tokEOF      tokenType = iota
+	tokText               // Run of characters not containing a delimiter or white space
+	tokString             // Quoted string including the quotes
+	tokDefine             // :=
+	tokSlash              // /
+	tokLParen             // (
+	tokRParen             // )
+	tokLBrace             // {
+	tokRBrace             // }
+	tokLBracket           // [
+	tokRBracket           // ]
+	tokComma              // ,
+	tokEqual              // =
+	tokBar                // |
+)
+
+var tokenDelims = map[rune]tokenType{
+	'/': tokSlash,
+	'(': tokLParen,
+	')': tokRParen,
+	'{': tokLBrace,
+	'}': tokRBrace,
+	'[': tokLBracket,
+	']': tokRBracket,
+	',': tokComma,
+	'=': tokEqual,
+	'|': tokBar,
+}
+
+// closeTokens maps the opening bracket tokens to the closing bracket tokens.
+var closeTokens = map[tokenType]tokenType{
+	tokLParen:   tokRParen,
+	tokLBrace:   tokRBrace,
+	tokLBracket: tokRBracket,
+}
+
+type token struct {
+	typ tokenType
+	val string   // Text of the token from the frame string
+	off int      // Byte offset of the token in the frame string
+	pos position // Position of the token in the frame string
+}
+
+func (t token) String() string {
+	if t.typ == tokEOF {
+		return "end of frame string"
+	}
+	return fmt.Sprintf("'%s'", t.val)
+}
+
+type lexer struct {
+	src string
+	off int
+	pos position
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, pos: position{line: 1, col: 1}}
+}
+
+// peek returns the rune at the current offset or utf8.RuneError at the end of the string.
+func (lx *lexer) peek() rune {
+	if lx.off >= len(lx.src) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(lx.src[lx.off:])
+	return r
+}
+
+// advance moves over the rune at the current offset and updates the position.
+func (lx *lexer) advance() rune {
+	r, n := utf8.DecodeRuneInString(lx.src[lx.off:])
+	lx.off += n
+	if r == '\n' {
+		lx.pos.line++
+		lx.pos.col = 1
+	} else {
+		lx.pos.col++
+	}
+	return r
+}
+
+// isDefine returns true if the ':=' delimiter is at the current offset.
+func (lx *lexer) isDefine() bool {
+	return strings.HasPrefix(lx.src[lx.off:], ":=")
+}
+
+// next returns the next token in the frame string, tokEOF at the end of the string.
+func (lx *lexer) next() (token, error) {
+
+	for lx.off < len(lx.src) && unicode.IsSpace(lx.peek()) {
+		lx.advance()
+	}
+
+	tok := token{off: lx.off, pos: lx.pos}
+	if lx.off >= len(lx.src) {
+		return tok, nil
+	}
+
+	r := lx.peek()
+	switch {
+	case lx.isDefine():
+		lx.advance()
+		lx.advance()
+		tok.typ = tokDefine
+	case r == '\'' || r == '"':
+		lx.advance()
+		for {
+			if lx.off >= len(lx.src) {
+				return tok, fmt.Errorf("unterminated quoted string")
+			}
+			c := lx.advance()
+			if c == r {
+				break
+			}
+			if c == '\\' {
+				if lx.off >= len(lx.src) {
+					return tok, fmt.Errorf("unterminated quoted string")
+				}
+				lx.advance()
+			}
+		}
+		tok.typ = tokString
+		if _, err := unquote(lx.src[tok.off:lx.off]); err != nil {
+			return tok, err
+		}
+	default:
+		if typ, ok := tokenDelims[r]; ok {
+			lx.advance()
+			tok.typ = typ
+			break
+		}
+		for lx.off < len(lx.src) {
+			r = lx.peek()
+			if _, ok := tokenDelims[r]; ok || unicode.IsSpace(r) || r == '\'' || r == '"' || lx.isDefine() {
+				break
+			}
+			lx.advance()
+		}
+		tok.typ = tokText
+	}
+	tok.val = lx.src[tok.off:lx.off]
+
+	return tok, nil
+}
+
+// isQuoted returns true if the value is a quoted string.
+func isQuoted(val string) bool {
+	return len(val) >= 2 && (val[0] == '\'' || val[0] == '"') && val[len(val)-1] == val[0]
+}
+
+// unquote removes the quotes and replaces the escapes of a quoted string value, a value
+// without quotes is returned unchanged.
+func unquote(val string) (string, error) {
+
+	if !isQuoted(val) {
+		return val, nil
+	}
+	quote := val[0]
+	val = val[1 : len(val)-1]
+
+	var sb strings.Builder
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		if c == quote {
+			return "", fmt.Errorf("unescaped quote in string: %s", val)
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(val) {
+			return "", fmt.Errorf("invalid escape at end of string: %s", val)
+		}
+		switch val[i] {
+		case '\\', '\'', '"':
+			sb.WriteByte(val[i])
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'x':
+			if i+3 > len(val) {
+				return "", fmt.Errorf("invalid \\x escape in string: %s", val)
+			}
+			v, err := strconv.ParseUint(val[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid \\x escape in string: %s", val)
+			}
+			sb.WriteByte(byte(v))
+			i += 2
+		default:
+			return "", fmt.Errorf("invalid escape '\\%c' in string: %s", val[i], val)
+		}
+	}
+	return sb.String(), nil
+}
+
+// quoteString returns the string as a single quoted string value with the escapes needed
+// to parse the value again.
+func quoteString(s string) string {
+
+	var sb strings.Builder
+
+	sb.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' || c == '\'':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == '\n':
+			sb.WriteString("\\n")
+		case c == '\r':
+			sb.WriteString("\\r")
+		case c == '\t':
+			sb.WriteString("\\t")
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&sb, "\\x%02x", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('\'')
+
+	return sb.String()
+}
+
+// layerNode is a layer of the frame string found by the parser.
+type layerNode struct {
+	name string   // Name of the layer
+	opts string   // Options of the layer between the ( and ) characters
+	pos  position // Position of the layer name
+}
+
+// frameNode is the frame string found by the parser.
+type frameNode struct {
+	name   string // Name of the frame
+	layers []layerNode
+}
+
+type parser struct {
+	lx    *lexer
+	frame string // Name of the frame for the errors
+	tok   token  // Current token
+}
+
+// advance moves to the next token in the frame string.
+func (p *parser) advance() error {
+
+	tok, err := p.lx.next()
+	if err != nil {
+		return parseError(p.frame, tok.pos, "%v", err)
+	}
+	p.tok = tok
+
+	return nil
+}
+
+// parseFrameString parses the frame string into the frame name and the layers, the layer
+// options are checked for balanced brackets, quotes and key/value pairs.
+func parseFrameString(frameString string) (*frameNode, error) {
+
+	p := &parser{lx: newLexer(frameString)}
+
+	// The frame name is the text in front of the ':=' delimiter
+	for {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.typ == tokDefine {
+			break
+		}
+		if p.tok.typ == tokEOF {
+			return nil, parseError("", p.tok.pos, "missing ':=' <FrameName>:=<Layers>: %v", frameString)
+		}
+	}
+	fn := &frameNode{name: strings.TrimSpace(frameString[:p.tok.off])}
+	if fn.name == "" {
+		return nil, parseError("", p.tok.pos, "missing frame name in front of ':='")
+	}
+	p.frame = fn.name
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.typ == tokEOF {
+		return nil, parseError(p.frame, p.tok.pos, "empty frame string")
+	}
+
+	for p.tok.typ != tokEOF {
+		ln, err := p.parseLayer()
+		if err != nil {
+			return nil, err
+		}
+		fn.layers = append(fn.layers, ln)
+
+		switch p.tok.typ {
+		case tokEOF:
+		case tokSlash:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, parseError(p.frame, p.tok.pos, "expected '/' after layer %s, found %v", ln.name, p.tok)
+		}
+	}
+
+	return fn, nil
+}
+
+// parseLayer parses a layer name and the options of the layer.
+func (p *parser) parseLayer() (layerNode, error) {
+
+	if p.tok.typ != tokText {
+		return layerNode{}, parseError(p.frame, p.tok.pos, "expected layer name, found %v", p.tok)
+	}
+	ln := layerNode{name: p.tok.val, pos: p.tok.pos}
+
+	if err := p.advance(); err != nil {
+		return ln, err
+	}
+	if p.tok.typ != tokLParen {
+		return ln, nil
+	}
+	start := p.tok
+
+	end, err := p.parseOptions(ln.name)
+	if err != nil {
+		return ln, err
+	}
+	ln.opts = strings.TrimSpace(p.lx.src[start.off+1 : end.off])
+
+	return ln, p.advance()
+}
+
+// option holds the tokens of a layer option at the top level of the layer options.
+type option struct {
+	start  token   // First token of the option
+	key    []token // Tokens in front of the '=' delimiter
+	equal  *token  // The '=' delimiter or nil when the option is a value
+	values int     // Number of tokens following the '=' delimiter
+}
+
+// check returns an error if the option is not a value or a key/value pair.
+func (o *option) check(p *parser, layer string) error {
+
+	if o.equal == nil {
+		return nil
+	}
+	if len(o.key) != 1 || o.key[0].typ != tokText {
+		return parseError(p.frame, o.start.pos, "%s option needs a key in front of '='", layer)
+	}
+	if o.values == 0 {
+		return parseError(p.frame, o.equal.pos, "%s option %s needs a value after '='", layer, o.key[0].val)
+	}
+	return nil
+}
+
+// parseOptions parses the layer options up to the closing ')' of the layer, which is
+// returned. The brackets of the option values must be balanced.
+func (p *parser) parseOptions(layer string) (token, error) {
+
+	stack := []token{p.tok}
+	opt := &option{}
+
+	for {
+		if err := p.advance(); err != nil {
+			return p.tok, err
+		}
+		tok := p.tok
+
+		if len(stack) == 1 {
+			switch tok.typ {
+			case tokComma, tokRParen:
+				if err := opt.check(p, layer); err != nil {
+					return tok, err
+				}
+				if tok.typ == tokRParen {
+					return tok, nil
+				}
+				opt = &option{}
+				continue
+			case tokEqual:
+				if opt.equal != nil {
+					return tok, parseError(p.frame, tok.pos, "%s option has more than one '=', quote the value", layer)
+				}
+				if opt.start.typ == tokEOF {
+					opt.start = tok
+				}
+				opt.equal = &tok
+				continue
+			}
+			if opt.start.typ == tokEOF {
+				opt.start = tok
+			}
+			if opt.equal == nil {
+				opt.key = append(opt.key, tok)
+			} else {
+				opt.values++
+			}
+		}
+
+		switch tok.typ {
+		case tokEOF:
+			open := stack[len(stack)-1]
+			return tok, parseError(p.frame, open.pos, "missing closing bracket for %v in layer %s", open, layer)
+		case tokLParen, tokLBrace, tokLBracket:
+			stack = append(stack, tok)
+		case tokRParen, tokRBrace, tokRBracket:
+			open := stack[len(stack)-1]
+			if closeTokens[open.typ] != tok.typ {
+				return tok, parseError(p.frame, tok.pos, "unexpected %v in layer %s, %v at line %d, column %d is not closed",
+					tok, layer, open, open.pos.line, open.pos.col)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// topLevelIndex returns the byte offsets of the delimiter in the string, which are not
+// inside of a (), {} or [] pair or a quoted string.
+func topLevelIndex(s string, delim byte) []int {
+
+	idx := make([]int, 0)
+
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '{' || c == '[':
+			depth++
+		case c == ')' || c == '}' || c == ']':
+			depth--
+		case c == delim && depth == 0:
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// splitKeyValue splits a layer option into the key and value at the '=' delimiter, the
+// key and value have the white space removed.
+func splitKeyValue(opt string) (string, string, error) {
+
+	idx := topLevelIndex(opt, '=')
+	if len(idx) != 1 {
+		return "", "", fmt.Errorf("option needs a key/value pair: [%s]", strings.TrimSpace(opt))
+	}
+	key := strings.TrimSpace(opt[:idx[0]])
+	val := strings.TrimSpace(opt[idx[0]+1:])
+	if key == "" || val == "" {
+		return "", "", fmt.Errorf("option needs a key/value pair: [%s]", strings.TrimSpace(opt))
+	}
+	return key, val, nil
+}
+
+// layerError returns the error of the layer as a ParseError with the frame name and the
+// position of the layer in the frame string.
+func (fr *Frame) layerError(li *LayerInfo, err error) error {
+
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &ParseError{Frame: fr.name, Line: li.pos.line, Column: li.pos.col, Err: fmt.Errorf("%s: %w", li.Name, err)}
+}
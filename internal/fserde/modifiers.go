@@ -0,0 +1,298 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"math/rand"
+	"net"
+	"strings"
+)
+
+// Field modifiers change the value of a layer option for each of the frames produced by
+// the Count(N) layer. The modifiers are:
+//
+// <first>..<last> [step <n>] - the values from first to last incremented by n, default 1,
+//                              the values start over at first after last.
+// inc(<base>, <n>[, <step>]) - the n values starting at base incremented by step, default 1.
+// rand(<min>, <max>) - a random value between min and max inclusive, the random values are
+//                      the same each time the frame string is encoded.
+//
+// The values are integers, MAC addresses, IPv4 or IPv6 addresses, i.e.,
+//   Ether(dst=inc(00:11:22:33:44:00, 16))/IPv4(src=10.0.0.1..10.0.0.254 step 1)/
+//   UDP(sport=rand(1024,65535))/Count(1000)
+//
+// Each frame is encoded from the layer options with the modifier values, which means the
+// lengths and checksums are computed for each frame.
+
+type modifierType int
+
+const (
+	modifierRange modifierType = iota // first..last step n
+	modifierInc                       // inc(base, n, step)
+	modifierRand                      // rand(min, max)
+)
+
+type fieldType int
+
+const (
+	fieldInt fieldType = iota
+	fieldMAC
+	fieldIPv4
+	fieldIPv6
+)
+
+// fieldBits is the number of bits of the field types, the address values wrap around.
+var fieldBits = map[fieldType]uint{
+	fieldMAC:  48,
+	fieldIPv4: 32,
+	fieldIPv6: 128,
+}
+
+type fieldModifier struct {
+	mtype modifierType
+	ftype fieldType
+	first *big.Int // First value, base value or minimum value
+	last  *big.Int // Maximum value of a random value
+	step  *big.Int // Increment between values
+	count *big.Int // Number of values of a range or increment
+}
+
+// parseFieldValue parses an integer, MAC address, IPv4 or IPv6 address value.
+func parseFieldValue(val string) (*big.Int, fieldType, error) {
+
+	val = strings.TrimSpace(val)
+
+	if strings.Contains(val, ":") || strings.Count(val, ".") == 2 {
+		if mac, err := ToHardwareAddr(val); err == nil && len(mac) == HardwareAddrLen {
+			return new(big.Int).SetBytes(mac), fieldMAC, nil
+		}
+	}
+	if ip := net.ParseIP(val); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil && !strings.Contains(val, ":") {
+			return new(big.Int).SetBytes(ip4), fieldIPv4, nil
+		}
+		return new(big.Int).SetBytes(ip.To16()), fieldIPv6, nil
+	}
+	if v, ok := new(big.Int).SetString(val, 0); ok && v.Sign() >= 0 {
+		return v, fieldInt, nil
+	}
+	return nil, fieldInt, fmt.Errorf("invalid field value: %s", val)
+}
+
+// formatFieldValue returns the option string of the value.
+func formatFieldValue(v *big.Int, ftype fieldType) string {
+
+	if bits, ok := fieldBits[ftype]; ok {
+		b := v.FillBytes(make([]byte, bits/8))
+		switch ftype {
+		case fieldMAC:
+			return net.HardwareAddr(b).String()
+		default:
+			return net.IP(b).String()
+		}
+	}
+	return v.String()
+}
+
+// parseModifierArgs parses the comma separated values of inc() or rand().
+func parseModifierArgs(args string, min, max int) ([]*big.Int, []fieldType, error) {
+
+	list := strings.Split(args, ",")
+	if len(list) < min || len(list) > max {
+		return nil, nil, fmt.Errorf("invalid number of modifier values: %s", args)
+	}
+
+	values := make([]*big.Int, 0, len(list))
+	types := make([]fieldType, 0, len(list))
+	for _, arg := range list {
+		v, t, err := parseFieldValue(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		values = append(values, v)
+		types = append(types, t)
+	}
+	return values, types, nil
+}
+
+// parseFieldModifier parses the option value into a field modifier, nil is returned when
+// the value does not contain a modifier.
+func parseFieldModifier(val string) (*fieldModifier, error) {
+
+	val = strings.TrimSpace(val)
+	lower := strings.ToLower(val)
+	one := big.NewInt(1)
+
+	switch {
+	case strings.HasPrefix(val, "'") || strings.HasPrefix(val, "\""):
+		return nil, nil
+
+	case strings.HasPrefix(lower, "inc(") && strings.HasSuffix(lower, ")"):
+		values, types, err := parseModifierArgs(val[4:len(val)-1], 2, 3)
+		if err != nil {
+			return nil, err
+		}
+		m := &fieldModifier{mtype: modifierInc, ftype: types[0], first: values[0], count: values[1], step: one}
+		if len(values) == 3 {
+			m.step = values[2]
+		}
+		if m.count.Sign() == 0 || m.step.Sign() == 0 {
+			return nil, fmt.Errorf("inc count and step must be greater than zero: %s", val)
+		}
+		return m, nil
+
+	case strings.HasPrefix(lower, "rand(") && strings.HasSuffix(lower, ")"):
+		values, types, err := parseModifierArgs(val[5:len(val)-1], 2, 2)
+		if err != nil {
+			return nil, err
+		}
+		if types[0] != types[1] {
+			return nil, fmt.Errorf("rand values must be the same type: %s", val)
+		}
+		if values[0].Cmp(values[1]) > 0 {
+			return nil, fmt.Errorf("rand minimum is greater than maximum: %s", val)
+		}
+		return &fieldModifier{mtype: modifierRand, ftype: types[0], first: values[0], last: values[1]}, nil
+
+	case strings.Contains(val, ".."):
+		// The value is a range only when both ends are numbers or addresses, i.e., not a
+		// file path like ../x
+		fields := strings.Fields(strings.Replace(val, "..", " .. ", 1))
+		if len(fields) < 3 || fields[1] != ".." {
+			return nil, nil
+		}
+		first, ftype, err := parseFieldValue(fields[0])
+		if err != nil {
+			return nil, nil
+		}
+		last, ltype, err := parseFieldValue(fields[2])
+		if err != nil {
+			return nil, nil
+		}
+		if len(fields) != 3 && len(fields) != 5 {
+			return nil, fmt.Errorf("range must be <first>..<last> [step <n>]: %s", val)
+		}
+		if ftype != ltype {
+			return nil, fmt.Errorf("range values must be the same type: %s", val)
+		}
+		if first.Cmp(last) > 0 {
+			return nil, fmt.Errorf("range first value is greater than last value: %s", val)
+		}
+		step := one
+		if len(fields) == 5 {
+			if strings.ToLower(fields[3]) != "step" {
+				return nil, fmt.Errorf("range must be <first>..<last> [step <n>]: %s", val)
+			}
+			if step, _, err = parseFieldValue(fields[4]); err != nil {
+				return nil, err
+			}
+			if step.Sign() == 0 {
+				return nil, fmt.Errorf("range step must be greater than zero: %s", val)
+			}
+		}
+		count := new(big.Int).Sub(last, first)
+		count.Div(count, step).Add(count, one)
+
+		return &fieldModifier{mtype: modifierRange, ftype: ftype, first: first, step: step, count: count}, nil
+	}
+
+	return nil, nil
+}
+
+// value returns the option value of the modifier for the given frame variant.
+func (m *fieldModifier) value(variant int, rng *rand.Rand) string {
+
+	v := new(big.Int)
+
+	switch m.mtype {
+	case modifierRange, modifierInc:
+		v.Mod(big.NewInt(int64(variant)), m.count)
+		v.Mul(v, m.step).Add(v, m.first)
+	case modifierRand:
+		n := new(big.Int).Sub(m.last, m.first)
+		v.Rand(rng, n.Add(n, big.NewInt(1))).Add(v, m.first)
+	}
+
+	// Addresses wrap around at the size of the address
+	if bits, ok := fieldBits[m.ftype]; ok {
+		v.Mod(v, new(big.Int).Lsh(big.NewInt(1), bits))
+	}
+
+	return formatFieldValue(v, m.ftype)
+}
+
+// optionModifier is a field modifier of a layer option.
+type optionModifier struct {
+	layer int            // Index of the layer in the frame string
+	opt   int            // Index of the option in the layer options
+	key   string         // Option key
+	mod   *fieldModifier // Field modifier of the option value
+}
+
+// frameModifiers holds the layer options of a frame string and the field modifiers of
+// the options, which are used to build the layer options of each frame variant.
+type frameModifiers struct {
+	options   [][]string // Options of each layer split at the commas
+	modifiers []optionModifier
+	rng       *rand.Rand // Random values are repeatable for the frame name
+}
+
+// newFrameModifiers finds the field modifiers in the options of each layer.
+func newFrameModifiers(name string, layerOpts []string) (*frameModifiers, error) {
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	fm := &frameModifiers{
+		options: make([][]string, len(layerOpts)),
+		rng:     rand.New(rand.NewSource(int64(h.Sum64()))),
+	}
+
+	for i, opts := range layerOpts {
+		fm.options[i] = splitOptions(opts)
+
+		for j, opt := range fm.options[i] {
+			key, val, found := strings.Cut(opt, "=")
+			if !found {
+				continue
+			}
+			mod, err := parseFieldModifier(val)
+			if err != nil {
+				return nil, fmt.Errorf("option %s: %w", strings.TrimSpace(key), err)
+			}
+			if mod != nil {
+				fm.modifiers = append(fm.modifiers, optionModifier{layer: i, opt: j, key: strings.TrimSpace(key), mod: mod})
+			}
+		}
+	}
+
+	return fm, nil
+}
+
+// enabled returns true if the frame string has field modifiers.
+func (fm *frameModifiers) enabled() bool {
+	return len(fm.modifiers) > 0
+}
+
+// layerOptions returns the options string of each layer for the given frame variant.
+func (fm *frameModifiers) layerOptions(variant int) []string {
+
+	opts := make([][]string, len(fm.options))
+	for i := range fm.options {
+		opts[i] = append([]string{}, fm.options[i]...)
+	}
+	for _, m := range fm.modifiers {
+		opts[m.layer][m.opt] = m.key + "=" + m.mod.value(variant, fm.rng)
+	}
+
+	layerOpts := make([]string, len(opts))
+	for i := range opts {
+		layerOpts[i] = strings.Join(opts[i], ",")
+	}
+	return layerOpts
+}
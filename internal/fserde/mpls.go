@@ -0,0 +1,187 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The MPLS() protocol layer is a single label stack entry, the layer can be repeated to
+// build a label stack, i.e., Ether()/MPLS(label=100)/MPLS(label=200)/IPv4()/...
+//
+// label - is the 20 bit label value.
+// tc - is the 3 bit traffic class.
+// ttl - is the 8 bit time to live, defaults to 64.
+// [bos|s] - is the bottom of stack bit, defaults to set on the last label of the stack.
+//
+// When the Ether() layer in front of the label stack has no EtherType, the EtherType is
+// set to 0x8847 or to 0x8848 when the destination MAC address is a multicast address.
+
+const (
+	MPLSHeaderLen = 4
+	MPLSMaxLabel  = 0xFFFFF
+	MPLSMaxTC     = 0x7
+	MPLSBoSFlag   = 0x100 // Bottom of stack bit in the label stack entry
+)
+
+type MPLSLayer struct {
+	hdr    *LayerHdr
+	label  uint32 // 20 bit label value
+	tc     uint8  // 3 bit traffic class
+	ttl    uint8  // 8 bit time to live
+	bos    bool   // Bottom of stack bit
+	bosSet bool   // Bottom of stack bit was given in the options
+}
+
+func (l *MPLSLayer) String() string {
+
+	s := fmt.Sprintf("%s(label=%d, tc=%d, ttl=%d", l.Name(), l.label, l.tc, l.ttl)
+	if l.bosSet {
+		s += fmt.Sprintf(", bos=%t", l.bos)
+	}
+	return s + ")"
+}
+
+func MPLSNew(fr *Frame) *MPLSLayer {
+	return &MPLSLayer{
+		hdr: LayerConstructor(fr, LayerMPLS, LayerMPLSType),
+	}
+}
+
+func (l *MPLSLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func (l *MPLSLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	ttlSet := false
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "label":
+			if v, err := strconv.ParseUint(val, 0, 20); err != nil {
+				return fmt.Errorf("invalid mpls label: %s", val)
+			} else {
+				l.label = uint32(v)
+			}
+		case "tc":
+			if v, err := strconv.ParseUint(val, 0, 3); err != nil {
+				return fmt.Errorf("invalid mpls tc: %s", val)
+			} else {
+				l.tc = uint8(v)
+			}
+		case "ttl":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.ttl = uint8(v)
+				ttlSet = true
+			}
+		case "bos", "s":
+			switch val {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				l.bos = true
+			case "off", "no", "false", "disable", "disabled", "0":
+				l.bos = false
+			default:
+				return fmt.Errorf("bos invalid value: %s", val)
+			}
+			l.bosSet = true
+		default:
+			return fmt.Errorf("unknown mpls option: [%s]", opt)
+		}
+	}
+
+	if l.ttl == 0 && !ttlSet {
+		l.ttl = DefaultTTL
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = MPLSHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *MPLSLayer) ApplyDefaults() error {
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerMPLS, l.hdr.index).(*MPLSLayer)
+	if !ok {
+		return nil
+	}
+
+	if l.label == 0 && dl.label != 0 {
+		l.label = dl.label
+	}
+	if l.tc == 0 && dl.tc != 0 {
+		l.tc = dl.tc
+	}
+
+	return nil
+}
+
+func (l *MPLSLayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+
+	// The last label of the stack is the label not followed by another label
+	if !l.bosSet {
+		next := l.hdr.fr.nextProtocol(&l.hdr.proto)
+		l.bos = next == nil || next.name != LayerMPLS
+	}
+
+	entry := (l.label&MPLSMaxLabel)<<12 | uint32(l.tc&MPLSMaxTC)<<9 | uint32(l.ttl)
+	if l.bos {
+		entry |= MPLSBoSFlag
+	}
+	data.Append(entry)
+
+	return nil
+}
+
+// Decode the MPLS label stack entry from the binary frame data.
+func (l *MPLSLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < MPLSHeaderLen {
+		return 0, fmt.Errorf("mpls header too short: %d bytes", len(data))
+	}
+
+	entry := binary.BigEndian.Uint32(data)
+	l.label = entry >> 12
+	l.tc = uint8(entry>>9) & MPLSMaxTC
+	l.bos = entry&MPLSBoSFlag != 0
+	l.ttl = uint8(entry)
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = MPLSHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return MPLSHeaderLen, nil
+}
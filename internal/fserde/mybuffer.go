@@ -97,17 +97,17 @@ func (b *MyBuffer) WriteValueAt(index int, val interface{}) error {
 	case uint8:
 		buf[0] = byte(v)
 	case int16:
-		buf = binary.BigEndian.AppendUint16(buf, uint16(v))
+		binary.BigEndian.PutUint16(buf, uint16(v))
 	case uint16:
-		buf = binary.BigEndian.AppendUint16(buf, v)
+		binary.BigEndian.PutUint16(buf, v)
 	case int32:
-		buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+		binary.BigEndian.PutUint32(buf, uint32(v))
 	case uint32:
-		buf = binary.BigEndian.AppendUint32(buf, v)
+		binary.BigEndian.PutUint32(buf, v)
 	case int64:
-		buf = binary.BigEndian.AppendUint64(buf, uint64(v))
+		binary.BigEndian.PutUint64(buf, uint64(v))
 	case uint64:
-		buf = binary.BigEndian.AppendUint64(buf, v)
+		binary.BigEndian.PutUint64(buf, v)
 	case []byte:
 		buf = v
 	case string:
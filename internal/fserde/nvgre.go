@@ -0,0 +1,154 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The NVGRE() protocol layer is a GRE header with the key field holding the virtual subnet
+// ID and flow ID, followed by the inner frame starting with an Ether() layer,
+// i.e., Ether()/IPv4()/NVGRE(vsid=100)/Ether()/IPv4()/...
+//
+// vsid - is the 24 bit virtual subnet ID.
+// flowid - is the 8 bit flow ID.
+//
+// The IPv4 protocol or IPv6 next header in front of the NVGRE layer is set to 47.
+
+const (
+	NVGREHeaderLen = 8
+	NVGREMaxVSID   = 0xFFFFFF
+)
+
+type NVGRELayer struct {
+	hdr    *LayerHdr
+	vsid   uint32 // 24 bit virtual subnet ID
+	flowID uint8  // 8 bit flow ID
+}
+
+func (l *NVGRELayer) String() string {
+	return fmt.Sprintf("%s(vsid=%#x, flowid=%d)", l.Name(), l.vsid, l.flowID)
+}
+
+func NVGRENew(fr *Frame) *NVGRELayer {
+	return &NVGRELayer{
+		hdr: LayerConstructor(fr, LayerNVGRE, LayerNVGREType),
+	}
+}
+
+func (l *NVGRELayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func (l *NVGRELayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "vsid":
+			if v, err := strconv.ParseUint(val, 0, 24); err != nil {
+				return fmt.Errorf("invalid nvgre vsid: %s", val)
+			} else {
+				l.vsid = uint32(v)
+			}
+		case "flowid":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.flowID = uint8(v)
+			}
+		default:
+			return fmt.Errorf("unknown nvgre option: [%s]", opt)
+		}
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = NVGREHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *NVGRELayer) ApplyDefaults() error {
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerNVGRE, l.hdr.index).(*NVGRELayer)
+	if !ok {
+		return nil
+	}
+
+	if l.vsid == 0 && dl.vsid != 0 {
+		l.vsid = dl.vsid
+	}
+	if l.flowID == 0 && dl.flowID != 0 {
+		l.flowID = dl.flowID
+	}
+
+	return nil
+}
+
+func (l *NVGRELayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+
+	data.Append(uint16(GREKeyFlag))
+	data.Append(uint16(EtherTypeTEB))
+	data.Append((l.vsid&NVGREMaxVSID)<<8 | uint32(l.flowID))
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains a GRE header with only the key
+// field present and the transparent Ethernet bridging protocol type.
+func (l *NVGRELayer) decodeValid(data []byte) bool {
+
+	if len(data) < NVGREHeaderLen+EtherHeaderLen {
+		return false
+	}
+	return binary.BigEndian.Uint16(data[0:]) == GREKeyFlag &&
+		binary.BigEndian.Uint16(data[2:]) == EtherTypeTEB
+}
+
+// Decode the NVGRE header from the binary frame data.
+func (l *NVGRELayer) Decode(data []byte) (int, error) {
+
+	if len(data) < NVGREHeaderLen {
+		return 0, fmt.Errorf("nvgre header too short: %d bytes", len(data))
+	}
+
+	key := binary.BigEndian.Uint32(data[4:])
+	l.vsid = key >> 8
+	l.flowID = uint8(key)
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = NVGREHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return NVGREHeaderLen, nil
+}
@@ -0,0 +1,222 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Patch() is a layer to write the given bytes over the frame data at an offset after the
+// lengths and checksums are written, the patches are written in order before the frame is
+// fragmented and the FCS is appended.
+//    e.g., Ether()/IPv4()/UDP()/Payload(size=32)/Patch(offset=42, hex='c0ffee')
+//
+// offset - is the offset of the bytes from the start of the frame.
+// hex - is the bytes as hex digits, which may be separated by white space or ':' characters.
+// recompute - recomputes the IPv4 header, UDP, TCP, ICMP, GRE and SCTP checksums from the patched
+//             frame data, defaults to false which keeps the checksums of the frame.
+
+const (
+	ipv4ChecksumOffset = 10 // Offset of the IPv4 header checksum
+	ipv4AddrOffset     = 12 // Offset of the IPv4 source and destination addresses
+	ipv4AddrsLen       = 8  // Length of the IPv4 source and destination addresses
+	ipv6AddrOffset     = 8  // Offset of the IPv6 source and destination addresses
+	ipv6AddrsLen       = 32 // Length of the IPv6 source and destination addresses
+)
+
+// PatchLayer the structure holding the information on each layer.
+type PatchLayer struct {
+	hdr       *LayerHdr
+	offset    uint16
+	data      []byte
+	recompute bool
+}
+
+func (l *PatchLayer) String() string {
+	s := fmt.Sprintf("%s(offset=%d, hex=%x", l.hdr.layerName, l.offset, l.data)
+	if l.recompute {
+		s += ", recompute=true"
+	}
+	return s + ")"
+}
+
+// PatchNew creates a new PatchLayer and is registered as the Patch layer create function.
+func PatchNew(fr *Frame) *PatchLayer {
+	return &PatchLayer{
+		hdr: LayerConstructor(fr, LayerPatch, LayerPatchType),
+	}
+}
+
+// Name returns the name of the layer.
+func (l *PatchLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// Parse parses the layer options string.
+func (l *PatchLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	offsetSet := false
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+
+		switch key {
+		case "offset", "off":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.offset = uint16(v)
+				offsetSet = true
+			}
+		case "hex":
+			if l.data, err = parseHexData(val); err != nil {
+				return err
+			}
+		case "recompute":
+			switch strings.ToLower(val) {
+			case "on", "yes", "true", "enable", "enabled", "1":
+				l.recompute = true
+			case "off", "no", "false", "disable", "disabled", "0":
+				l.recompute = false
+			default:
+				return fmt.Errorf("recompute invalid value: %s", val)
+			}
+		default:
+			return fmt.Errorf("unknown patch option: [%s]", opt)
+		}
+	}
+	if !offsetSet || len(l.data) == 0 {
+		return fmt.Errorf("patch needs the offset and hex options")
+	}
+
+	return nil
+}
+
+// ApplyDefaults applies the default values for the layer.
+func (l *PatchLayer) ApplyDefaults() error {
+
+	return nil
+}
+
+// WriteLayer writes the layer to hdr.frame []byte.
+func (l *PatchLayer) WriteLayer() error {
+
+	return nil
+}
+
+// patch writes the bytes over the frame data and recomputes the checksums when requested.
+func (l *PatchLayer) patch() error {
+
+	b := l.hdr.fr.frame.Bytes()
+	if end := int(l.offset) + len(l.data); end > len(b) {
+		return fmt.Errorf("patch offset %d plus %d bytes is past the end of the %d byte frame",
+			l.offset, len(l.data), len(b))
+	}
+	copy(b[l.offset:], l.data)
+
+	if l.recompute {
+		l.recomputeChecksums(b)
+	}
+
+	return nil
+}
+
+// patchChecksum returns the internet checksum of the data plus the pseudo header sum, the
+// checksum field at the offset is treated as zero.
+func patchChecksum(data []byte, cksumOff int, sum uint32) uint16 {
+
+	rest := data[cksumOff+2:]
+	sum += dataChecksum(data[:cksumOff], cksumOff) + dataChecksum(rest, len(rest))
+
+	return ^reduceChecksum(sum)
+}
+
+// pseudoHdrSum returns the sum of the pseudo header of the L4 protocol taken from the
+// frame data of the outer IPv4 or IPv6 header, false is returned without an IP header.
+func (l *PatchLayer) pseudoHdrSum(b []byte, proto *ProtoInfo, protocol int) (uint32, bool) {
+
+	fr := l.hdr.fr
+	sum := uint32(protocol) + uint32(fr.protoLength(proto))
+
+	switch ip := fr.outerLayer(proto, LayerIPv4, LayerIPv6).(type) {
+	case *IPv4Layer:
+		off := int(fr.protoOffset(&ip.hdr.proto)) + ipv4AddrOffset
+		return sum + dataChecksum(b[off:off+ipv4AddrsLen], ipv4AddrsLen), true
+	case *IPv6Layer:
+		off := int(fr.protoOffset(&ip.hdr.proto)) + ipv6AddrOffset
+		return sum + dataChecksum(b[off:off+ipv6AddrsLen], ipv6AddrsLen), true
+	}
+	return 0, false
+}
+
+// recomputeChecksums writes the checksums computed from the patched frame data, the inner
+// most checksums are computed first as the outer L4 checksums include the inner layers.
+// The checksum given in the frame string of a layer is written in place of the checksum.
+func (l *PatchLayer) recomputeChecksums(b []byte) {
+
+	fr := l.hdr.fr
+	for i := len(fr.layerInfo) - 1; i >= 0; i-- {
+		switch x := fr.layerInfo[i].Layer.(type) {
+		case *IPv4Layer:
+			off := int(fr.protoOffset(&x.hdr.proto))
+			hdr := b[off : off+int(x.hdr.proto.length)]
+			cksum := patchChecksum(hdr, ipv4ChecksumOffset, 0)
+			binary.BigEndian.PutUint16(hdr[ipv4ChecksumOffset:], x.cksum.checksum(cksum))
+		case *UDPLayer:
+			if _, ok := fr.outerLayer(&x.hdr.proto, LayerIPv4, LayerIPv6).(*IPv4Layer); ok && !x.udpHdr.Checksum {
+				continue
+			}
+			if sum, ok := l.pseudoHdrSum(b, &x.hdr.proto, ProtocolUDP); ok {
+				off := int(fr.protoOffset(&x.hdr.proto))
+				data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+				cksum := patchChecksum(data, UDPChecksumOffset, sum)
+				if cksum == 0 {
+					cksum = 0xffff
+				}
+				binary.BigEndian.PutUint16(data[UDPChecksumOffset:], x.cksum.checksum(cksum))
+			}
+		case *TCPLayer:
+			if sum, ok := l.pseudoHdrSum(b, &x.hdr.proto, ProtocolTCP); ok {
+				off := int(fr.protoOffset(&x.hdr.proto))
+				data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+				binary.BigEndian.PutUint16(data[TCPChecksumOffset:], x.cksum.checksum(patchChecksum(data, TCPChecksumOffset, sum)))
+			}
+		case *ICMPv4Layer:
+			off := int(fr.protoOffset(&x.hdr.proto))
+			data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+			binary.BigEndian.PutUint16(data[ICMPChecksumOffset:], patchChecksum(data, ICMPChecksumOffset, 0))
+		case *ICMPv6Layer:
+			if sum, ok := l.pseudoHdrSum(b, &x.hdr.proto, ProtocolICMPv6); ok {
+				off := int(fr.protoOffset(&x.hdr.proto))
+				data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+				binary.BigEndian.PutUint16(data[ICMPChecksumOffset:], patchChecksum(data, ICMPChecksumOffset, sum))
+			}
+		case *SCTPLayer:
+			off := int(fr.protoOffset(&x.hdr.proto))
+			data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+			binary.LittleEndian.PutUint32(data[SCTPChecksumOffset:], SCTPChecksum(data))
+		case *GRELayer:
+			if x.greHdr.Flags&GREChecksumFlag == 0 {
+				continue
+			}
+			off := int(fr.protoOffset(&x.hdr.proto))
+			data := b[off : off+int(fr.protoLength(&x.hdr.proto))]
+			binary.BigEndian.PutUint16(data[GREChecksumOffset:], patchChecksum(data, GREChecksumOffset, 0))
+		}
+	}
+}
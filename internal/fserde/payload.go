@@ -5,8 +5,13 @@
 package fserde
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"math/rand"
+	"os"
 	"strconv"
 	"strings"
 )
@@ -20,30 +25,54 @@ const (
 	fill32Type
 	fill64Type
 	fillStringType
+	fillHexType
+	fillRandomType
+	fillInc8Type
+	fillFileType
 )
 
 type PayloadLayer struct {
-	hdr    *LayerHdr
-	length uint16
-	fill   fillType
-	data   []byte
+	hdr       *LayerHdr
+	length    uint16
+	fill      fillType
+	data      []byte
+	frameSize uint16 // Frame size of the framesize option, zero if not given
+	noFCS     bool   // Frame size does not include the FCS
+	seed      int64  // Seed of the random payload data
+	file      string // Path of the file containing the payload data
+	offset    uint32 // Offset of the payload data in the file
 }
 
 func (pl *PayloadLayer) String() string {
-	s := fmt.Sprintf("Payload(length=%d, ", pl.length)
+	if pl.length == 0 && pl.frameSize == 0 {
+		return "Payload()"
+	}
+	s := fmt.Sprintf("Payload(size=%d", pl.length)
+	if pl.frameSize != 0 {
+		s = fmt.Sprintf("Payload(framesize=%d", pl.frameSize)
+		if pl.noFCS {
+			s += ", fcs=false"
+		}
+	}
 	switch pl.fill {
 	case fillStringType:
-		s += fmt.Sprintf("string=%q)", pl.data)
+		s += fmt.Sprintf(", string=%s", quoteString(string(pl.data)))
+	case fillHexType:
+		s += fmt.Sprintf(", hex=%x", pl.data)
 	case fill8Type:
-		s += fmt.Sprintf("fill=%#x)", pl.data[0])
+		s += fmt.Sprintf(", fill=%#x", pl.data[0])
 	case fill16Type:
-		s += fmt.Sprintf("fill16=%#x)", binary.BigEndian.Uint16(pl.data))
+		s += fmt.Sprintf(", fill16=%#x", binary.BigEndian.Uint16(pl.data))
 	case fill32Type:
-		s += fmt.Sprintf("fill32=%#x)", binary.BigEndian.Uint32(pl.data))
+		s += fmt.Sprintf(", fill32=%#x", binary.BigEndian.Uint32(pl.data))
 	case fill64Type:
-		s += fmt.Sprintf("fill64=%#x)", binary.BigEndian.Uint64(pl.data))
-	default:
-		s += "fill=Unknown"
+		s += fmt.Sprintf(", fill64=%#x", binary.BigEndian.Uint64(pl.data))
+	case fillRandomType:
+		s += fmt.Sprintf(", random, seed=%d", pl.seed)
+	case fillInc8Type:
+		s += fmt.Sprintf(", inc8=%#x", pl.data[0])
+	case fillFileType:
+		s += fmt.Sprintf(", file=%s, offset=%d", quoteString(pl.file), pl.offset)
 	}
 	return s + ")"
 }
@@ -64,18 +93,28 @@ func (l *PayloadLayer) Name() LayerName {
 func (l *PayloadLayer) Parse(opts string) error {
 
 	if len(opts) > 0 {
-		options := strings.Split(opts, ",")
+		options := splitOptions(opts)
 
+		offsetSet := false
 		for _, opt := range options {
 			opt = strings.TrimSpace(opt)
 
-			kvp := strings.Split(opt, "=")
-			if len(kvp) != 2 {
-				return fmt.Errorf("invalid option: %s", opt)
+			// The random and inc8 options may be given without a value
+			switch strings.ToLower(opt) {
+			case "random":
+				l.fill = fillRandomType
+				continue
+			case "inc8":
+				l.fill = fillInc8Type
+				l.data = []byte{0}
+				continue
 			}
 
-			key := strings.ToLower(strings.TrimSpace(kvp[0]))
-			val := strings.TrimSpace(kvp[1])
+			key, val, err := splitKeyValue(opt)
+			if err != nil {
+				return err
+			}
+			key = strings.ToLower(key)
 
 			switch key {
 			case "size", "length", "len":
@@ -84,45 +123,115 @@ func (l *PayloadLayer) Parse(opts string) error {
 				} else {
 					l.length = uint16(v)
 				}
+			case "framesize":
+				if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+					return err
+				} else {
+					l.frameSize = uint16(v)
+				}
+			case "fcs":
+				switch strings.ToLower(val) {
+				case "on", "yes", "true", "enable", "enabled", "1":
+					l.noFCS = false
+				case "off", "no", "false", "disable", "disabled", "0":
+					l.noFCS = true
+				default:
+					return fmt.Errorf("fcs invalid value: %s", val)
+				}
 			case "fill", "fill8":
-				if v, err := strconv.ParseInt(val, 0, 0); err != nil {
+				if v, err := strconv.ParseUint(val, 0, 8); err != nil {
 					return err
 				} else {
 					l.fill = fill8Type
 					l.data = []byte{byte(uint8(v))}
 				}
 			case "fill16":
-				if v, err := strconv.ParseInt(val, 0, 0); err != nil {
+				if v, err := strconv.ParseUint(val, 0, 16); err != nil {
 					return err
 				} else {
 					l.fill = fill16Type
 					l.data = binary.BigEndian.AppendUint16([]byte{}, uint16(v))
 				}
 			case "fill32":
-				if v, err := strconv.ParseInt(val, 0, 0); err != nil {
+				if v, err := strconv.ParseUint(val, 0, 32); err != nil {
 					return err
 				} else {
 					l.fill = fill32Type
 					l.data = binary.BigEndian.AppendUint32([]byte{}, uint32(v))
 				}
 			case "fill64":
-				if v, err := strconv.ParseInt(val, 0, 0); err != nil {
+				if v, err := strconv.ParseUint(val, 0, 64); err != nil {
 					return err
 				} else {
 					l.fill = fill64Type
 					l.data = binary.BigEndian.AppendUint64([]byte{}, uint64(v))
 				}
 			case "string":
-				// trim the quotes from the string
-				val = strings.TrimLeft(val, "'")
-				val = strings.TrimRight(val, "'")
+				// remove the quotes and escapes from the string
+				if val, err = unquote(val); err != nil {
+					return err
+				}
 
 				l.fill = fillStringType
 				for i := 0; i < len(val); i++ {
 					l.data = append(l.data, val[i])
 				}
+			case "hex":
+				if v, err := parseHexData(val); err != nil {
+					return err
+				} else {
+					l.fill = fillHexType
+					l.data = v
+				}
+			case "random":
+				switch strings.ToLower(val) {
+				case "on", "yes", "true", "enable", "enabled", "1":
+					l.fill = fillRandomType
+				case "off", "no", "false", "disable", "disabled", "0":
+				default:
+					return fmt.Errorf("random invalid value: %s", val)
+				}
+			case "seed":
+				if v, err := strconv.ParseInt(val, 0, 64); err != nil {
+					return err
+				} else {
+					l.fill = fillRandomType
+					l.seed = v
+				}
+			case "inc8":
+				if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+					return err
+				} else {
+					l.fill = fillInc8Type
+					l.data = []byte{byte(v)}
+				}
+			case "file":
+				if val, err = unquote(val); err != nil {
+					return err
+				}
+				if val == "" {
+					return fmt.Errorf("payload file name is empty")
+				}
+				l.file = val
+			case "offset":
+				if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+					return err
+				} else {
+					l.offset = uint32(v)
+					offsetSet = true
+				}
+			default:
+				return fmt.Errorf("unknown payload option: [%s]", opt)
 			}
 		}
+
+		if l.file != "" {
+			if err := l.readFile(); err != nil {
+				return err
+			}
+		} else if offsetSet {
+			return fmt.Errorf("payload offset is only used with the file option")
+		}
 	}
 
 	// The size is not given use the length of the data byte slice
@@ -146,27 +255,116 @@ func (l *PayloadLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	if dl, ok := d.GetLayer(LayerPayload).(*PayloadLayer); !ok {
+	if dl, ok := d.GetLayerIndex(LayerPayload, l.hdr.index).(*PayloadLayer); !ok {
 		return nil
 	} else {
-		if l.length == 0 && dl.length != 0 {
-			l.length = dl.length
+		if l.length == 0 && l.frameSize == 0 {
+			if dl.length != 0 && dl.frameSize == 0 {
+				l.length = dl.length
+			}
+			l.frameSize, l.noFCS = dl.frameSize, dl.noFCS
 		}
 		if l.fill == fillTypeNone && dl.fill != fillTypeNone {
 			l.fill = dl.fill
 			l.data = dl.data
+			l.seed = dl.seed
+			l.file, l.offset = dl.file, dl.offset
 		}
-		l.hdr.fr.GetProtocol(l.Name()).length = l.length
+		l.hdr.proto.length = l.length
 	}
 
 	return nil
 }
 
+// readFile reads the payload data from the file starting at the offset, the number of
+// bytes read is the payload length when given or the rest of the file.
+func (l *PayloadLayer) readFile() error {
+
+	b, err := os.ReadFile(l.file)
+	if err != nil {
+		return err
+	}
+	if int64(l.offset) > int64(len(b)) {
+		return fmt.Errorf("payload offset %d is past the end of file %s, %d bytes", l.offset, l.file, len(b))
+	}
+	b = b[l.offset:]
+
+	if l.length != 0 {
+		if len(b) < int(l.length) {
+			return fmt.Errorf("payload file %s has %d bytes at offset %d, less than len %d",
+				l.file, len(b), l.offset, l.length)
+		}
+		b = b[:l.length]
+	}
+	if len(b) == 0 {
+		return fmt.Errorf("payload file %s has no data at offset %d", l.file, l.offset)
+	}
+	if len(b) > math.MaxUint16 {
+		return fmt.Errorf("payload file %s has more than %d bytes, use the len option", l.file, math.MaxUint16)
+	}
+	l.fill = fillFileType
+	l.data = b
+
+	return nil
+}
+
+// updateFrameSize sets the payload length to give the frame size of the framesize option
+// or the IMIX() frame size of the frame being encoded, which is used for the outer most
+// payload layer. The frame size includes the FCS unless the fcs option is false.
+func (l *PayloadLayer) updateFrameSize() error {
+
+	fr := l.hdr.fr
+
+	size, fcs := int(l.frameSize), !l.noFCS
+	if imix, ok := fr.GetLayer(LayerIMIX).(*IMIXLayer); ok && l.hdr.index == 0 {
+		size, fcs = imix.frameSize(fr.variant), true
+	}
+	if size == 0 {
+		return nil
+	}
+	if fcs {
+		size -= FCSLen
+	}
+
+	// The length of the other protocols in the frame, the total length less the payload
+	hdrs := int(fr.protoOffset(nil)) - int(l.hdr.proto.length)
+	if size < hdrs {
+		return fmt.Errorf("frame size %d is smaller than the frame headers %d bytes", size, hdrs)
+	}
+	l.length = uint16(size - hdrs)
+	l.hdr.proto.length = l.length
+
+	return nil
+}
+
 func (l *PayloadLayer) WriteLayer() error {
 
 	fr := l.hdr.fr
 	data := fr.frame
 
+	// The random and incrementing data is generated for the payload length, the random
+	// data is the same for a given seed.
+	switch l.fill {
+	case fillRandomType:
+		b := make([]byte, l.length)
+		rand.New(rand.NewSource(l.seed)).Read(b)
+		data.Append(b)
+		return nil
+	case fillInc8Type:
+		b := make([]byte, l.length)
+		for i := range b {
+			b[i] = l.data[0] + byte(i)
+		}
+		data.Append(b)
+		return nil
+	}
+
+	// No fill data given, fill the payload with zeros
+	if len(l.data) == 0 {
+		data.Append(make([]byte, l.length))
+		return nil
+	}
+
 	k := 0
 	for i := uint16(0); i < l.length; i++ {
 		data.Append(l.data[k])
@@ -176,6 +374,111 @@ func (l *PayloadLayer) WriteLayer() error {
 		}
 	}
 
-
 	return nil
 }
+
+// isPayloadString returns true if the data can be given as a string='...' option.
+func isPayloadString(data []byte) bool {
+
+	for _, c := range data {
+		if c <= ' ' || c > '~' || strings.IndexByte("'\",/()=", c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isPayloadFill returns true if the data is a repeating pattern of n bytes.
+func isPayloadFill(data []byte, n int) bool {
+
+	if len(data) < n {
+		return false
+	}
+	for i := n; i < len(data); i++ {
+		if data[i] != data[i%n] {
+			return false
+		}
+	}
+	return true
+}
+
+// isPayloadInc8 returns true if the data is incrementing byte values.
+func isPayloadInc8(data []byte) bool {
+
+	if len(data) < 4 {
+		return false
+	}
+	for i := 1; i < len(data); i++ {
+		if data[i] != data[0]+byte(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode the payload from the binary frame data, the payload is the remaining data.
+func (l *PayloadLayer) Decode(data []byte) (int, error) {
+
+	l.length = uint16(len(data))
+
+	switch {
+	case l.length == 0:
+		l.fill = fillTypeNone
+	case isPayloadFill(data, 1):
+		l.fill = fill8Type
+		l.data = bytes.Clone(data[:1])
+	case isPayloadString(data):
+		l.fill = fillStringType
+		l.data = bytes.Clone(data)
+	case isPayloadFill(data, 2):
+		l.fill = fill16Type
+		l.data = bytes.Clone(data[:2])
+	case isPayloadFill(data, 4):
+		l.fill = fill32Type
+		l.data = bytes.Clone(data[:4])
+	case isPayloadFill(data, 8):
+		l.fill = fill64Type
+		l.data = bytes.Clone(data[:8])
+	case isPayloadInc8(data):
+		l.fill = fillInc8Type
+		l.data = bytes.Clone(data[:1])
+	default:
+		l.fill = fillHexType
+		l.data = bytes.Clone(data)
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = l.length
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return len(data), nil
+}
+
+// parseHexData returns the bytes of a quoted or unquoted hex string, the hex digits may have
+// a 0x prefix and be separated by white space or ':' characters.
+func parseHexData(val string) ([]byte, error) {
+
+	// remove the quotes and escapes from the string
+	val, err := unquote(val)
+	if err != nil {
+		return nil, err
+	}
+
+	val = strings.Map(func(r rune) rune {
+		if r == ':' || r == ' ' || r == '\t' || r == '\n' {
+			return -1
+		}
+		return r
+	}, strings.TrimPrefix(strings.ToLower(val), "0x"))
+
+	v, err := hex.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		return nil, fmt.Errorf("hex value is empty")
+	}
+	return v, nil
+}
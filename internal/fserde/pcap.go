@@ -10,31 +10,73 @@ import (
 	"github.com/pktgen/go-pktgen/internal/pcap"
 )
 
+// WritePCAP writes the frames of the frame type to the pcap file, the frames are written
+// with the FCS bits of the pcap link type set when the frames have an FCS. The frames must
+// all have or all not have an FCS as the FCS bits are for all frames in the file.
 func (fg *FrameSerde) WritePCAP(path string, frameType FrameType) error {
 
 	if len(path) == 0 {
 		return fmt.Errorf("path is empty")
 	}
-	if pc := pcap.New(); pc == nil {
-		return fmt.Errorf("failed to create pcap file %s", path)
-	} else {
-		for _, name := range fg.frameNames {
-			key := FrameKey{name: name, ftype: frameType}
-			if fr, ok := fg.frames[key]; ok {
-				b := fr.frame.Bytes()
-
-				// pad out the packet length to the minimum packet length (60).
-				if fr.frame.Len() < MinPacketLen {
-					b = append(b, bytes.Repeat([]byte("\x00"), MinPacketLen-fr.frame.Len())...)
-				}
-				cl := fr.layersMap[LayerCount].(*CountLayer)
-				for i := 0; i < int(cl.count); i++ {
-					pc.AddPacket(b)
-				}
+
+	// pad out the packet length to the minimum packet length (60).
+	pad := func(b []byte) []byte {
+		if len(b) < MinPacketLen {
+			b = append(bytes.Clone(b), bytes.Repeat([]byte("\x00"), MinPacketLen-len(b))...)
+		}
+		return b
+	}
+
+	packets := make([][]byte, 0)
+	fcsFrames := 0
+	for _, name := range fg.frameNames {
+		key := FrameKey{name: name, ftype: frameType}
+		fr, ok := fg.frames[key]
+		if !ok {
+			continue
+		}
+		if el, ok := fr.GetLayer(LayerEther).(*EtherLayer); ok && el.fcsSet {
+			fcsFrames++
+		}
+
+		// Each frame is different when the frame has field modifiers
+		if variants := fr.Variants(); len(variants) > 0 {
+			for _, b := range variants {
+				packets = append(packets, pad(b))
 			}
+			continue
+		}
+
+		b := pad(fr.frame.Bytes())
+		cl := fr.GetLayer(LayerCount).(*CountLayer)
+		for i := 0; i < int(cl.count); i++ {
+			packets = append(packets, b)
 		}
-		pc.Write(path)
+	}
+	if fcsFrames > 0 && fcsFrames != len(fg.FrameNames(frameType)) {
+		return fmt.Errorf("frames with and without an FCS can not be written to pcap file %s", path)
+	}
+
+	pc := pcap.New()
+	if pc == nil {
+		return fmt.Errorf("failed to create pcap file %s", path)
+	}
+
+	// The span length is increased for jumbo frames
+	span := pcap.DefaultSpanLength
+	for _, b := range packets {
+		span = max(span, len(b))
+	}
+	pc.SetSpanLen(uint32(span))
+
+	// The pcap FCS length is the number of 16-bit words of the FCS
+	if fcsFrames > 0 {
+		pc.SetFCSPresent(true).SetFCSLength(FCSLen / 2)
+	}
+
+	for _, b := range packets {
+		pc.AddPacket(b)
 	}
 
-	return nil
+	return pc.Write(path)
 }
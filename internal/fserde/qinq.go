@@ -12,7 +12,7 @@ import (
 
 type QinQLayer struct {
 	hdr *LayerHdr
-	q   [2]*Dot1qLayer
+	q   [2]*VlanLayer
 }
 
 func (e *QinQLayer) String() string {
@@ -48,7 +48,10 @@ func (l *QinQLayer) Name() LayerName {
 
 func (l *QinQLayer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
+	if len(options) > len(l.q) {
+		return fmt.Errorf("invalid QinQ options, only two Dot1q{...} tags allowed: %v", opts)
+	}
 
 	for i, opt := range options {
 		opt = strings.TrimSpace(opt)
@@ -70,14 +73,13 @@ func (l *QinQLayer) Parse(opts string) error {
 			return fmt.Errorf("invalid QinQ should be Dot1q{...}: %s", str[0])
 		}
 
-		if len(str[1]) > 0 {
-			if err := l.q[i].ParseDot1q(str[1]); err != nil {
-				return err
-			}
+		if err := l.q[i].parseTag(str[1]); err != nil {
+			return err
 		}
 	}
-	l.q[0].dot1q.tPid = QinQID
-
+	if !l.q[0].tpidSet {
+		l.q[0].tpid = QinQID
+	}
 
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
@@ -95,7 +97,7 @@ func (l *QinQLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	_, ok := d.GetLayer(LayerQinQ).(*QinQLayer)
+	_, ok := d.GetLayerIndex(LayerQinQ, l.hdr.index).(*QinQLayer)
 	if !ok {
 		return nil
 	}
@@ -105,20 +107,26 @@ func (l *QinQLayer) ApplyDefaults() error {
 
 func (l *QinQLayer) WriteLayer() error {
 
-	data := l.hdr.fr.frame
+	writeVlanTags(l.hdr.fr, &l.hdr.proto, l.q[0].tpid, l.q[0].tci, l.q[1].tpid, l.q[1].tci)
+
+	return nil
+}
 
-	dstSrcMAC := make([]byte, 12)
-	copy(dstSrcMAC, data.Bytes()[:12])
-	restData := make([]byte, data.Len()-12)
-	copy(restData, data.Bytes()[12:])
+// Decode the two VLAN tags from the binary frame data starting at the outer TPID value.
+func (l *QinQLayer) Decode(data []byte) (int, error) {
 
-	data.Reset()
-	data.Append(dstSrcMAC)
-	data.Append(l.q[0].dot1q.tPid)
-	data.Append(l.q[0].dot1q.tci)
-	data.Append(l.q[1].dot1q.tPid)
-	data.Append(l.q[1].dot1q.tci)
-	data.Append(restData)
+	if len(data) < 8 {
+		return 0, fmt.Errorf("QinQ tags too short: %d bytes", len(data))
+	}
 
-	return nil
+	l.q[0].decodeTag(data[0:])
+	l.q[1].decodeTag(data[4:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = 8
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return 8, nil
 }
@@ -0,0 +1,96 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Raw() is a layer to insert the given bytes into the frame as a protocol of its own, which
+// is used for headers not known to this package. The bytes are part of the lengths and
+// checksums of the outer layers.
+//    e.g., Ether()/IPv4()/UDP(dport=7777)/Raw(hex='01 02 00 10')/Payload(size=16)
+//
+// hex - is the bytes as hex digits, which may be separated by white space or ':' characters.
+
+// RawLayer the structure holding the information on each layer.
+type RawLayer struct {
+	hdr  *LayerHdr
+	data []byte
+}
+
+func (l *RawLayer) String() string {
+	return fmt.Sprintf("%s(hex=%x)", l.hdr.layerName, l.data)
+}
+
+// RawNew creates a new RawLayer and is registered as the Raw layer create function.
+func RawNew(fr *Frame) *RawLayer {
+	return &RawLayer{
+		hdr: LayerConstructor(fr, LayerRaw, LayerRawType),
+	}
+}
+
+// Name returns the name of the layer.
+func (l *RawLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// Parse parses the layer options string.
+func (l *RawLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+
+		switch key {
+		case "hex":
+			if l.data, err = parseHexData(val); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown raw option: [%s]", opt)
+		}
+	}
+	if len(l.data) == 0 {
+		return fmt.Errorf("raw needs the hex option")
+	}
+	if len(l.data) > math.MaxUint16 {
+		return fmt.Errorf("raw data is %d bytes, more than %d bytes", len(l.data), math.MaxUint16)
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(len(l.data))
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+// ApplyDefaults applies the default values for the layer.
+func (l *RawLayer) ApplyDefaults() error {
+
+	return nil
+}
+
+// WriteLayer writes the layer to hdr.frame []byte.
+func (l *RawLayer) WriteLayer() error {
+
+	l.hdr.fr.frame.Append(l.data)
+
+	return nil
+}
@@ -5,15 +5,41 @@
 package fserde
 
 import (
+	"encoding/binary"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
+// The SCTP() protocol layer is the SCTP common header and is followed by one or more
+// chunk layers SCTPInit(), SCTPHeartbeat() or SCTPData(). The options are:
+//
+// [sport|srcport], [dport|dstport] - are the source and destination ports.
+// [vtag|tag] - is the 32 bit verification tag.
+//
+// The CRC32c checksum is computed over the SCTP packet after the frame is written.
+
+const (
+	SCTPHeaderLen      = 12
+	SCTPChecksumOffset = 8
+)
+
+type SCTPHdr struct {
+	SrcPort  uint16 // SCTP source port
+	DstPort  uint16 // SCTP destination port
+	VTag     uint32 // SCTP verification tag
+	Checksum uint32 // CRC32c checksum, computed after the frame is written
+}
+
 type SCTPLayer struct {
-	hdr *LayerHdr
+	hdr     *LayerHdr
+	sctpHdr SCTPHdr
 }
 
 func (l *SCTPLayer) String() string {
-	return fmt.Sprintf("%s()", l.hdr.layerName)
+	h := l.sctpHdr
+
+	return fmt.Sprintf("%s(sport=%d, dport=%d, vtag=%#x)", l.Name(), h.SrcPort, h.DstPort, h.VTag)
 }
 
 func SCTPNew(fr *Frame) *SCTPLayer {
@@ -28,9 +54,48 @@ func (l *SCTPLayer) Name() LayerName {
 
 func (l *SCTPLayer) Parse(opts string) error {
 
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "sport", "srcport":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.sctpHdr.SrcPort = uint16(v)
+			}
+		case "dport", "dstport":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.sctpHdr.DstPort = uint16(v)
+			}
+		case "vtag", "tag":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.sctpHdr.VTag = uint32(v)
+			}
+		default:
+			return fmt.Errorf("unknown sctp option: [%s]", opt)
+		}
+	}
+
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
+	l.hdr.proto.length = SCTPHeaderLen
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
@@ -44,17 +109,83 @@ func (l *SCTPLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerSCTP).(*SCTPLayer)
+	dl, ok := d.GetLayerIndex(LayerSCTP, l.hdr.index).(*SCTPLayer)
 	if !ok {
 		return nil
 	}
 
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
+	if l.sctpHdr.SrcPort == 0 && dl.sctpHdr.SrcPort != 0 {
+		l.sctpHdr.SrcPort = dl.sctpHdr.SrcPort
+	}
+	if l.sctpHdr.DstPort == 0 && dl.sctpHdr.DstPort != 0 {
+		l.sctpHdr.DstPort = dl.sctpHdr.DstPort
+	}
+	if l.sctpHdr.VTag == 0 && dl.sctpHdr.VTag != 0 {
+		l.sctpHdr.VTag = dl.sctpHdr.VTag
+	}
 
 	return nil
 }
 
 func (l *SCTPLayer) WriteLayer() error {
 
+	data := l.hdr.fr.frame
+
+	data.Append(l.sctpHdr.SrcPort)
+	data.Append(l.sctpHdr.DstPort)
+	data.Append(l.sctpHdr.VTag)
+	data.Append(uint32(0)) // force checksum to zero, update later
+
 	return nil
 }
+
+// updateChecksum computes the CRC32c checksum over the SCTP packet in the frame data,
+// the checksum is stored in the header in little endian byte order.
+func (l *SCTPLayer) updateChecksum() error {
+
+	fr := l.hdr.fr
+	off := int(fr.protoOffset(&l.hdr.proto))
+	end := off + int(fr.protoLength(&l.hdr.proto))
+
+	if end > fr.frame.Len() {
+		return fmt.Errorf("sctp packet exceeds frame length: %d > %d", end, fr.frame.Len())
+	}
+
+	l.sctpHdr.Checksum = SCTPChecksum(fr.frame.Bytes()[off:end])
+
+	cksum := binary.LittleEndian.AppendUint32([]byte{}, l.sctpHdr.Checksum)
+
+	return fr.frame.WriteAt(off+SCTPChecksumOffset, cksum)
+}
+
+// decodeValid returns true if the data slice contains an SCTP packet with a valid
+// CRC32c checksum.
+func (l *SCTPLayer) decodeValid(data []byte) bool {
+
+	if len(data) < SCTPHeaderLen {
+		return false
+	}
+
+	return SCTPChecksum(data) == binary.LittleEndian.Uint32(data[SCTPChecksumOffset:])
+}
+
+// Decode the SCTP common header from the binary frame data.
+func (l *SCTPLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < SCTPHeaderLen {
+		return 0, fmt.Errorf("sctp header too short: %d bytes", len(data))
+	}
+
+	l.sctpHdr.SrcPort = binary.BigEndian.Uint16(data[0:])
+	l.sctpHdr.DstPort = binary.BigEndian.Uint16(data[2:])
+	l.sctpHdr.VTag = binary.BigEndian.Uint32(data[4:])
+	l.sctpHdr.Checksum = binary.LittleEndian.Uint32(data[SCTPChecksumOffset:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = SCTPHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return SCTPHeaderLen, nil
+}
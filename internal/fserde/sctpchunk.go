@@ -0,0 +1,629 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The SCTP chunk layers follow the SCTP() layer, each chunk layer may be given once.
+//
+// SCTPInit() - is the INIT chunk without optional parameters, the options are:
+//   [tag|inittag] - is the initiate tag, defaults to 1.
+//   [rwnd|a_rwnd] - is the advertised receiver window credit.
+//   [os|outstreams], [mis|instreams] - are the number of outbound and inbound streams, default to 1.
+//   tsn - is the initial TSN.
+//
+// SCTPHeartbeat() - is the HEARTBEAT chunk with a heartbeat info parameter, the options are:
+//   info - is the heartbeat info data as a hex string.
+//
+// SCTPData() - is the DATA chunk and the user data is the Payload() layer, the DATA chunk
+// must be the last chunk of the frame. The options are:
+//   tsn - is the transmission sequence number.
+//   [stream|sid] - is the stream identifier.
+//   [ssn|seq] - is the stream sequence number.
+//   ppid - is the payload protocol identifier.
+//   flags - are the chunk flags as a value or [U | B | E], defaults to [B | E].
+//
+// The DATA chunk length is computed from the user data and the chunk is padded to a
+// multiple of 4 bytes.
+
+const (
+	SCTPChunkHeaderLen     = 4  // Chunk type, flags and length
+	SCTPDataHeaderLen      = 16 // DATA chunk header length without user data
+	SCTPInitLen            = 20 // INIT chunk length without optional parameters
+	SCTPHeartbeatInfoLen   = 4  // Heartbeat info parameter type and length
+	SCTPHeartbeatInfoType  = 1  // Heartbeat info parameter type
+	SCTPDefaultInitTag     = 1
+	SCTPDefaultStreamCount = 1
+)
+
+// SCTP chunk types supported by the SCTP chunk layers.
+const (
+	SCTPChunkData      = 0
+	SCTPChunkInit      = 1
+	SCTPChunkHeartbeat = 4
+)
+
+// Flags that may be set in a DATA chunk.
+const (
+	SCTPDataEndFlag = 1 << iota
+	SCTPDataBeginFlag
+	SCTPDataUnorderedFlag
+)
+
+// sctpPadding is the name of the protocol information for the DATA chunk padding.
+const sctpPadding LayerName = "SCTPPadding"
+
+// sctpPadLen returns the number of bytes needed to pad the length to a multiple of 4 bytes.
+func sctpPadLen(length int) int {
+	return (4 - length%4) % 4
+}
+
+// isZero returns true if all of the bytes in the data slice are zero.
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type SCTPDataLayer struct {
+	hdr      *LayerHdr
+	flags    uint8     // Chunk flags U, B and E
+	flagsSet bool      // Flags were given in the options
+	length   uint16    // Chunk length including the user data without padding
+	tsn      uint32    // Transmission sequence number
+	stream   uint16    // Stream identifier
+	ssn      uint16    // Stream sequence number
+	ppid     uint32    // Payload protocol identifier
+	pad      ProtoInfo // Padding after the user data
+}
+
+func (l *SCTPDataLayer) String() string {
+	return fmt.Sprintf("%s(flags=%#x, tsn=%d, stream=%d, ssn=%d, ppid=%d)",
+		l.Name(), l.flags, l.tsn, l.stream, l.ssn, l.ppid)
+}
+
+func SCTPDataNew(fr *Frame) *SCTPDataLayer {
+	return &SCTPDataLayer{
+		hdr: LayerConstructor(fr, LayerSCTPData, LayerSCTPDataType),
+	}
+}
+
+func (l *SCTPDataLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func parseSCTPDataFlags(f string) uint8 {
+
+	switch strings.TrimSpace(f) {
+	case "e", "end":
+		return SCTPDataEndFlag
+	case "b", "begin":
+		return SCTPDataBeginFlag
+	case "u", "unordered":
+		return SCTPDataUnorderedFlag
+	default:
+		return 0
+	}
+}
+
+func (l *SCTPDataLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "tsn":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tsn = uint32(v)
+			}
+		case "stream", "sid":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.stream = uint16(v)
+			}
+		case "ssn", "seq":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.ssn = uint16(v)
+			}
+		case "ppid":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.ppid = uint32(v)
+			}
+		case "flags":
+			if strings.HasPrefix(val, "0x") {
+				if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+					return err
+				} else {
+					l.flags = uint8(v)
+				}
+			} else {
+				str := strings.FieldsFunc(val, func(r rune) bool {
+					return r == '[' || r == ']'
+				})
+				if len(str) > 0 {
+					for _, f := range strings.Split(str[0], "|") {
+						l.flags |= parseSCTPDataFlags(f)
+					}
+				}
+			}
+			l.flagsSet = true
+		default:
+			return fmt.Errorf("unknown sctpdata option: [%s]", opt)
+		}
+	}
+
+	if !l.flagsSet {
+		l.flags = SCTPDataBeginFlag | SCTPDataEndFlag
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = SCTPDataHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *SCTPDataLayer) ApplyDefaults() error {
+
+	// The user data of the DATA chunk is the rest of the frame, no chunk may follow it
+	found := false
+	for _, p := range l.hdr.fr.protocols {
+		switch {
+		case p == &l.hdr.proto:
+			found = true
+		case found && (p.name == LayerSCTPInit || p.name == LayerSCTPHeartbeat || p.name == LayerSCTPData):
+			return fmt.Errorf("%s chunk must not follow the %s chunk", p.name, l.Name())
+		}
+	}
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerSCTPData, l.hdr.index).(*SCTPDataLayer)
+	if !ok {
+		return nil
+	}
+
+	if l.tsn == 0 && dl.tsn != 0 {
+		l.tsn = dl.tsn
+	}
+	if l.stream == 0 && dl.stream != 0 {
+		l.stream = dl.stream
+	}
+	if l.ppid == 0 && dl.ppid != 0 {
+		l.ppid = dl.ppid
+	}
+
+	return nil
+}
+
+// updateLength sets the chunk length to the length of the DATA chunk header plus the
+// user data and adds the padding needed after the user data to the frame protocols.
+func (l *SCTPDataLayer) updateLength() {
+
+	fr := l.hdr.fr
+
+	if l.pad.length == 0 {
+		l.length = fr.protoLength(&l.hdr.proto)
+		if n := sctpPadLen(int(l.length)); n > 0 {
+			l.pad = ProtoInfo{
+				name:   sctpPadding,
+				offset: fr.protoOffset(&l.hdr.proto) + l.length,
+				length: uint16(n),
+			}
+			fr.AddProtocol(&l.pad)
+		}
+	}
+}
+
+func (l *SCTPDataLayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+
+	data.Append(uint8(SCTPChunkData))
+	data.Append(l.flags)
+	data.Append(l.length)
+	data.Append(l.tsn)
+	data.Append(l.stream)
+	data.Append(l.ssn)
+	data.Append(l.ppid)
+
+	return nil
+}
+
+// writePadding writes the padding after the user data, must be called after all of
+// the layers are written.
+func (l *SCTPDataLayer) writePadding() {
+
+	if l.pad.length > 0 {
+		l.hdr.fr.frame.Append(make([]byte, l.pad.length))
+	}
+}
+
+// decodeValid returns true if the data slice contains a DATA chunk which is the last
+// chunk in the packet, only followed by zero padding.
+func (l *SCTPDataLayer) decodeValid(data []byte) bool {
+
+	if len(data) < SCTPDataHeaderLen || data[0] != SCTPChunkData {
+		return false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < SCTPDataHeaderLen || length+sctpPadLen(length) != len(data) {
+		return false
+	}
+	return isZero(data[length:])
+}
+
+// Decode the DATA chunk header from the binary frame data, the user data is not consumed.
+func (l *SCTPDataLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < SCTPDataHeaderLen {
+		return 0, fmt.Errorf("sctp data chunk too short: %d bytes", len(data))
+	}
+
+	l.flags = data[1]
+	l.flagsSet = true
+	l.length = binary.BigEndian.Uint16(data[2:])
+	l.tsn = binary.BigEndian.Uint32(data[4:])
+	l.stream = binary.BigEndian.Uint16(data[8:])
+	l.ssn = binary.BigEndian.Uint16(data[10:])
+	l.ppid = binary.BigEndian.Uint32(data[12:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = SCTPDataHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return SCTPDataHeaderLen, nil
+}
+
+type SCTPInitLayer struct {
+	hdr  *LayerHdr
+	tag  uint32 // Initiate tag
+	rwnd uint32 // Advertised receiver window credit
+	os   uint16 // Number of outbound streams
+	mis  uint16 // Number of inbound streams
+	tsn  uint32 // Initial TSN
+}
+
+func (l *SCTPInitLayer) String() string {
+	return fmt.Sprintf("%s(tag=%#x, rwnd=%d, os=%d, mis=%d, tsn=%d)",
+		l.Name(), l.tag, l.rwnd, l.os, l.mis, l.tsn)
+}
+
+func SCTPInitNew(fr *Frame) *SCTPInitLayer {
+	return &SCTPInitLayer{
+		hdr: LayerConstructor(fr, LayerSCTPInit, LayerSCTPInitType),
+	}
+}
+
+func (l *SCTPInitLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func (l *SCTPInitLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "tag", "inittag":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tag = uint32(v)
+			}
+		case "rwnd", "a_rwnd":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.rwnd = uint32(v)
+			}
+		case "os", "outstreams":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.os = uint16(v)
+			}
+		case "mis", "instreams":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.mis = uint16(v)
+			}
+		case "tsn":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tsn = uint32(v)
+			}
+		default:
+			return fmt.Errorf("unknown sctpinit option: [%s]", opt)
+		}
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = SCTPInitLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *SCTPInitLayer) ApplyDefaults() error {
+
+	// The initiate tag and number of streams must not be zero
+	defer func() {
+		if l.tag == 0 {
+			l.tag = SCTPDefaultInitTag
+		}
+		if l.os == 0 {
+			l.os = SCTPDefaultStreamCount
+		}
+		if l.mis == 0 {
+			l.mis = SCTPDefaultStreamCount
+		}
+	}()
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(LayerSCTPInit, l.hdr.index).(*SCTPInitLayer)
+	if !ok {
+		return nil
+	}
+
+	if l.tag == 0 && dl.tag != 0 {
+		l.tag = dl.tag
+	}
+	if l.rwnd == 0 && dl.rwnd != 0 {
+		l.rwnd = dl.rwnd
+	}
+	if l.os == 0 && dl.os != 0 {
+		l.os = dl.os
+	}
+	if l.mis == 0 && dl.mis != 0 {
+		l.mis = dl.mis
+	}
+	if l.tsn == 0 && dl.tsn != 0 {
+		l.tsn = dl.tsn
+	}
+
+	return nil
+}
+
+func (l *SCTPInitLayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+
+	data.Append(uint8(SCTPChunkInit))
+	data.Append(uint8(0))
+	data.Append(uint16(SCTPInitLen))
+	data.Append(l.tag)
+	data.Append(l.rwnd)
+	data.Append(l.os)
+	data.Append(l.mis)
+	data.Append(l.tsn)
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains an INIT chunk without optional
+// parameters and with non-zero initiate tag and stream counts.
+func (l *SCTPInitLayer) decodeValid(data []byte) bool {
+
+	if len(data) < SCTPInitLen || data[0] != SCTPChunkInit || data[1] != 0 {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[2:]) != SCTPInitLen {
+		return false
+	}
+	return binary.BigEndian.Uint32(data[4:]) != 0 &&
+		binary.BigEndian.Uint16(data[12:]) != 0 && binary.BigEndian.Uint16(data[14:]) != 0
+}
+
+// Decode the INIT chunk from the binary frame data.
+func (l *SCTPInitLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < SCTPInitLen {
+		return 0, fmt.Errorf("sctp init chunk too short: %d bytes", len(data))
+	}
+
+	l.tag = binary.BigEndian.Uint32(data[4:])
+	l.rwnd = binary.BigEndian.Uint32(data[8:])
+	l.os = binary.BigEndian.Uint16(data[12:])
+	l.mis = binary.BigEndian.Uint16(data[14:])
+	l.tsn = binary.BigEndian.Uint32(data[16:])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = SCTPInitLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return SCTPInitLen, nil
+}
+
+type SCTPHeartbeatLayer struct {
+	hdr  *LayerHdr
+	info []byte // Heartbeat info parameter data
+}
+
+func (l *SCTPHeartbeatLayer) String() string {
+	if len(l.info) == 0 {
+		return fmt.Sprintf("%s()", l.Name())
+	}
+	return fmt.Sprintf("%s(info=0x%x)", l.Name(), l.info)
+}
+
+func SCTPHeartbeatNew(fr *Frame) *SCTPHeartbeatLayer {
+	return &SCTPHeartbeatLayer{
+		hdr: LayerConstructor(fr, LayerSCTPHeartbeat, LayerSCTPHeartbeatType),
+	}
+}
+
+func (l *SCTPHeartbeatLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// length returns the chunk length without padding.
+func (l *SCTPHeartbeatLayer) length() int {
+	return SCTPChunkHeaderLen + SCTPHeartbeatInfoLen + len(l.info)
+}
+
+func (l *SCTPHeartbeatLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "info":
+			val = strings.Trim(val, "'\"")
+			if b, err := hex.DecodeString(strings.TrimPrefix(val, "0x")); err != nil {
+				return fmt.Errorf("invalid heartbeat info: %s", val)
+			} else {
+				l.info = b
+			}
+		default:
+			return fmt.Errorf("unknown sctpheartbeat option: [%s]", opt)
+		}
+	}
+
+	length := l.length()
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(length + sctpPadLen(length))
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *SCTPHeartbeatLayer) ApplyDefaults() error {
+
+	// The heartbeat info is not taken from the default frame, as the chunk length
+	// is already part of the frame protocols.
+	return nil
+}
+
+func (l *SCTPHeartbeatLayer) WriteLayer() error {
+
+	data := l.hdr.fr.frame
+	length := l.length()
+
+	data.Append(uint8(SCTPChunkHeartbeat))
+	data.Append(uint8(0))
+	data.Append(uint16(length))
+	data.Append(uint16(SCTPHeartbeatInfoType))
+	data.Append(uint16(SCTPHeartbeatInfoLen + len(l.info)))
+	data.Append(l.info)
+	data.Append(make([]byte, sctpPadLen(length)))
+
+	return nil
+}
+
+// decodeValid returns true if the data slice contains a HEARTBEAT chunk with a single
+// heartbeat info parameter followed by zero padding.
+func (l *SCTPHeartbeatLayer) decodeValid(data []byte) bool {
+
+	if len(data) < SCTPChunkHeaderLen+SCTPHeartbeatInfoLen || data[0] != SCTPChunkHeartbeat || data[1] != 0 {
+		return false
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < SCTPChunkHeaderLen+SCTPHeartbeatInfoLen || length+sctpPadLen(length) > len(data) {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[4:]) != SCTPHeartbeatInfoType ||
+		int(binary.BigEndian.Uint16(data[6:])) != length-SCTPChunkHeaderLen {
+		return false
+	}
+	return isZero(data[length : length+sctpPadLen(length)])
+}
+
+// Decode the HEARTBEAT chunk from the binary frame data.
+func (l *SCTPHeartbeatLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < SCTPChunkHeaderLen+SCTPHeartbeatInfoLen {
+		return 0, fmt.Errorf("sctp heartbeat chunk too short: %d bytes", len(data))
+	}
+
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length < SCTPChunkHeaderLen+SCTPHeartbeatInfoLen || length+sctpPadLen(length) > len(data) {
+		return 0, fmt.Errorf("sctp heartbeat chunk invalid length: %d", length)
+	}
+	l.info = bytes.Clone(data[SCTPChunkHeaderLen+SCTPHeartbeatInfoLen : length])
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = uint16(length + sctpPadLen(length))
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return int(l.hdr.proto.length), nil
+}
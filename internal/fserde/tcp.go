@@ -5,6 +5,8 @@
 package fserde
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"strings"
@@ -52,20 +54,20 @@ type TCPHdr struct {
 type TCPLayer struct {
 	hdr    *LayerHdr
 	tcpHdr TCPHdr
+	cksum  checksumOverride // Checksum given in the options
 }
 
 func (t *TCPLayer) String() string {
 	h := t.tcpHdr
-	s := fmt.Sprintf("TCP(sport=%v, dport=%v, seq=%v, ack=%v, len=%v flags=%v, window=%v, checksum=%v, urgent=%v, ",
-		h.SrcPort, h.DstPort, h.SeqNum, h.AckNum, h.HdrLen, h.Flags, h.Window, h.Checksum, h.Urgent)
+	s := fmt.Sprintf("TCP(sport=%v, dport=%v, seq=%v, ack=%v, flags=%#x, window=%v, urgent=%v",
+		h.SrcPort, h.DstPort, h.SeqNum, h.AckNum, h.Flags, h.Window, h.Urgent)
 	if len(h.tcpOptions) > 0 {
-		s += "options="
-		for i, o := range h.tcpOptions {
-			s += fmt.Sprintf("<%d,%d,%v>", o.kind, o.length, o.data)
-			if i < len(h.tcpOptions)-1 {
-				s += ","
-			}
-		}
+		s += fmt.Sprintf(", options=%s", formatTCPOptions(h.tcpOptions))
+	} else if len(h.Options) > 0 {
+		s += fmt.Sprintf(", options=%s", quoteString(string(h.Options)))
+	}
+	if t.cksum.set {
+		s += fmt.Sprintf(", checksum=%v", t.cksum)
 	}
 	return s + ")"
 }
@@ -107,7 +109,7 @@ func parseTCPFlags(f string) uint16 {
 
 func (l *TCPLayer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
 
 	for _, opt := range options {
 
@@ -116,12 +118,14 @@ func (l *TCPLayer) Parse(opts string) error {
 			continue
 		}
 
-		kvp := strings.Split(opt, "=")
-		if len(kvp) != 2 {
-			return fmt.Errorf("option needs a key/value pair")
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		if !isQuoted(val) { // the quoted strings are literal
+			val = strings.ToLower(val)
 		}
-		key := strings.ToLower(strings.TrimSpace(kvp[0]))
-		val := strings.ToLower(strings.TrimSpace(kvp[1]))
 
 		switch key {
 		case "sport":
@@ -182,10 +186,23 @@ func (l *TCPLayer) Parse(opts string) error {
 			} else {
 				l.tcpHdr.Urgent = uint16(v)
 			}
-		case "options": // TODO: convert this string to an array of TCPOptions structures.
-			// trim the quotes from the string
-			val = strings.TrimLeft(val, "'")
-			val = strings.TrimRight(val, "'")
+		case "checksum", "cksum":
+			if l.cksum, err = parseChecksumOverride(val); err != nil {
+				return err
+			}
+		case "options":
+			if strings.HasPrefix(val, "[") {
+				if l.tcpHdr.tcpOptions, err = parseTCPOptions(val); err != nil {
+					return err
+				}
+				l.tcpHdr.Options = encodeTCPOptions(l.tcpHdr.tcpOptions)
+				break
+			}
+
+			// remove the quotes and escapes from the string
+			if val, err = unquote(val); err != nil {
+				return err
+			}
 
 			str := fmt.Sprint(val)
 
@@ -201,6 +218,9 @@ func (l *TCPLayer) Parse(opts string) error {
 					l.tcpHdr.Options = append(l.tcpHdr.Options, 0)
 				}
 			}
+			if len(l.tcpHdr.Options) > TCPMaxOptLen {
+				return fmt.Errorf("tcp options are %d bytes, more than %d bytes", len(l.tcpHdr.Options), TCPMaxOptLen)
+			}
 
 		default:
 			return fmt.Errorf("unknown tcp option: [%s]", opt)
@@ -224,17 +244,11 @@ func (l *TCPLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerTCP).(*TCPLayer)
+	dl, ok := d.GetLayerIndex(LayerTCP, l.hdr.index).(*TCPLayer)
 	if !ok {
 		return nil
 	}
 
-	if ip, ok := l.hdr.fr.layersMap[LayerIPv4].(*IPv4Layer); ok {
-		ip.ipHdr.Protocol = ProtocolTCP
-	} else {
-		return fmt.Errorf("not support ipv4")
-	}
-
 	if l.tcpHdr.SrcPort == 0 && dl.tcpHdr.SrcPort != 0 {
 		l.tcpHdr.SrcPort = dl.tcpHdr.SrcPort
 	}
@@ -259,8 +273,14 @@ func (l *TCPLayer) ApplyDefaults() error {
 	if l.tcpHdr.HdrLen == 0 && dl.tcpHdr.HdrLen != 0 {
 		l.tcpHdr.HdrLen = dl.tcpHdr.HdrLen
 	}
-	if (l.tcpHdr.Options == nil || len(l.tcpHdr.Options) == 0) && dl.tcpHdr.Options != nil {
+	if len(l.tcpHdr.Options) == 0 && len(dl.tcpHdr.Options) > 0 {
 		l.tcpHdr.Options = dl.tcpHdr.Options
+		l.tcpHdr.tcpOptions = dl.tcpHdr.tcpOptions
+		l.tcpHdr.HdrLen = uint16(TCPDefaultLen + len(l.tcpHdr.Options))
+		l.hdr.proto.length = l.tcpHdr.HdrLen
+	}
+	if !l.cksum.set && dl.cksum.set {
+		l.cksum = dl.cksum
 	}
 
 	return nil
@@ -284,3 +304,91 @@ func (l *TCPLayer) WriteLayer() error {
 
 	return nil
 }
+
+// updateChecksum computes the TCP checksum using the IPv4 or IPv6 layer carrying the segment.
+func (l *TCPLayer) updateChecksum() error {
+
+	fr := l.hdr.fr
+	off := fr.protoOffset(&l.hdr.proto)
+	d := fr.frame.Bytes()[off+TCPHeaderLen : off+fr.protoLength(&l.hdr.proto)]
+
+	var cksum uint16
+	switch ip := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(type) {
+	case *IPv4Layer:
+		cksum = IPv4TCPChecksum(&ip.ipHdr, &l.tcpHdr, d)
+	case *IPv6Layer:
+		cksum = IPv6TCPChecksum(&ip.ip6Hdr, &l.tcpHdr, d)
+	default:
+		return nil
+	}
+
+	return fr.frame.WriteValueAt(int(off+TCPChecksumOffset), l.cksum.checksum(cksum))
+}
+
+// decodeValid returns true if the data slice contains a TCP header the TCP layer can
+// represent, which is a header with known options and a valid checksum.
+func (l *TCPLayer) decodeValid(data []byte) bool {
+
+	if len(data) < TCPHeaderLen {
+		return false
+	}
+	hl := int(data[12]>>4) << 2
+	if hl < TCPHeaderLen || hl > len(data) {
+		return false
+	}
+	if _, ok := decodeTCPOptions(data[TCPHeaderLen:hl]); !ok {
+		return false
+	}
+
+	hdr := TCPHdr{
+		SrcPort: binary.BigEndian.Uint16(data[0:]),
+		DstPort: binary.BigEndian.Uint16(data[2:]),
+		SeqNum:  binary.BigEndian.Uint32(data[4:]),
+		AckNum:  binary.BigEndian.Uint32(data[8:]),
+		Flags:   binary.BigEndian.Uint16(data[12:]) & 0x0FFF,
+		Window:  binary.BigEndian.Uint16(data[14:]),
+		Urgent:  binary.BigEndian.Uint16(data[18:]),
+		HdrLen:  uint16(hl),
+	}
+	cksum := binary.BigEndian.Uint16(data[TCPChecksumOffset:])
+
+	ipLayer := l.hdr.fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6)
+	if ip, ok := ipLayer.(*IPv4Layer); ok {
+		return IPv4TCPChecksum(&ip.ipHdr, &hdr, data[TCPHeaderLen:]) == cksum
+	} else if ip, ok := ipLayer.(*IPv6Layer); ok {
+		return IPv6TCPChecksum(&ip.ip6Hdr, &hdr, data[TCPHeaderLen:]) == cksum
+	}
+	return false
+}
+
+// Decode the TCP header from the binary frame data.
+func (l *TCPLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < TCPHeaderLen {
+		return 0, fmt.Errorf("tcp header too short: %d bytes", len(data))
+	}
+
+	h := &l.tcpHdr
+	h.SrcPort = binary.BigEndian.Uint16(data[0:])
+	h.DstPort = binary.BigEndian.Uint16(data[2:])
+	h.SeqNum = binary.BigEndian.Uint32(data[4:])
+	h.AckNum = binary.BigEndian.Uint32(data[8:])
+	h.HdrLen = uint16(data[12]>>4) << 2
+	h.Flags = binary.BigEndian.Uint16(data[12:]) & 0x0FFF
+	h.Window = binary.BigEndian.Uint16(data[14:])
+	h.Checksum = binary.BigEndian.Uint16(data[TCPChecksumOffset:])
+	h.Urgent = binary.BigEndian.Uint16(data[18:])
+
+	if int(h.HdrLen) > TCPHeaderLen && int(h.HdrLen) <= len(data) {
+		h.Options = bytes.Clone(data[TCPHeaderLen:h.HdrLen])
+		h.tcpOptions, _ = decodeTCPOptions(h.Options)
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = h.HdrLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return int(h.HdrLen), nil
+}
@@ -0,0 +1,240 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The TCP(options=[...]) option is a list of TCP options, the options are padded with zero
+// bytes to a multiple of 4 bytes and the data offset is set to include the options, i.e.,
+// TCP(flags=[syn], options=[mss=1460, sackok, ts=(1,0), nop, wscale=7]).
+//
+// eol, nop - are the end of option list and no operation options.
+// mss=N - is the maximum segment size.
+// wscale=N - is the window scale shift count.
+// sackok - is the SACK permitted option.
+// ts=(val,ecr) - is the timestamp value and echo reply.
+// sack=[(left,right), ...] - is the list of SACK blocks, at most 4 blocks.
+//
+// A quoted string is copied as the option bytes, i.e., options='\x01\x01\x01\x00'.
+
+const (
+	TCPOptEOL      = 0  // End of option list
+	TCPOptNOP      = 1  // No operation
+	TCPOptMSS      = 2  // Maximum segment size
+	TCPOptWScale   = 3  // Window scale
+	TCPOptSACKOK   = 4  // SACK permitted
+	TCPOptSACK     = 5  // SACK blocks
+	TCPOptTS       = 8  // Timestamps
+	TCPMaxOptLen   = 40 // Maximum length of the TCP options
+	TCPMaxSACKList = 4  // Maximum number of SACK blocks
+)
+
+func (o TCPOptions) String() string {
+
+	switch o.kind {
+	case TCPOptEOL:
+		return "eol"
+	case TCPOptNOP:
+		return "nop"
+	case TCPOptMSS:
+		return fmt.Sprintf("mss=%d", binary.BigEndian.Uint16(o.data))
+	case TCPOptWScale:
+		return fmt.Sprintf("wscale=%d", o.data[0])
+	case TCPOptSACKOK:
+		return "sackok"
+	case TCPOptTS:
+		return fmt.Sprintf("ts=(%d,%d)", binary.BigEndian.Uint32(o.data), binary.BigEndian.Uint32(o.data[4:]))
+	case TCPOptSACK:
+		list := make([]string, 0)
+		for i := 0; i+8 <= len(o.data); i += 8 {
+			list = append(list, fmt.Sprintf("(%d,%d)", binary.BigEndian.Uint32(o.data[i:]),
+				binary.BigEndian.Uint32(o.data[i+4:])))
+		}
+		return "sack=[" + strings.Join(list, ", ") + "]"
+	}
+	return fmt.Sprintf("kind%d", o.kind)
+}
+
+// formatTCPOptions returns the options in the options=[...] format.
+func formatTCPOptions(opts []TCPOptions) string {
+
+	list := make([]string, 0, len(opts))
+	for _, o := range opts {
+		list = append(list, o.String())
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// parseTCPPair parses a (a,b) pair of 32-bit values.
+func parseTCPPair(s string) (uint32, uint32, error) {
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return 0, 0, fmt.Errorf("tcp option value must be a pair (a,b): %s", s)
+	}
+	a, b, found := strings.Cut(s[1:len(s)-1], ",")
+	if !found {
+		return 0, 0, fmt.Errorf("tcp option value must be a pair (a,b): %s", s)
+	}
+	va, err := strconv.ParseUint(strings.TrimSpace(a), 0, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	vb, err := strconv.ParseUint(strings.TrimSpace(b), 0, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(va), uint32(vb), nil
+}
+
+// parseTCPOptions parses the options=[...] option value into the list of options.
+func parseTCPOptions(val string) ([]TCPOptions, error) {
+
+	if !strings.HasPrefix(val, "[") || !strings.HasSuffix(val, "]") {
+		return nil, fmt.Errorf("tcp options must be a list [...]: %s", val)
+	}
+
+	opts := make([]TCPOptions, 0)
+	for _, item := range splitOptions(val[1 : len(val)-1]) {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		switch item {
+		case "eol":
+			opts = append(opts, TCPOptions{kind: TCPOptEOL})
+			continue
+		case "nop":
+			opts = append(opts, TCPOptions{kind: TCPOptNOP})
+			continue
+		case "sackok":
+			opts = append(opts, TCPOptions{kind: TCPOptSACKOK, length: 2})
+			continue
+		}
+
+		key, v, err := splitKeyValue(item)
+		if err != nil {
+			return nil, fmt.Errorf("unknown tcp option: %s", item)
+		}
+
+		var o TCPOptions
+		switch key {
+		case "mss":
+			n, err := strconv.ParseUint(v, 0, 16)
+			if err != nil {
+				return nil, err
+			}
+			o = TCPOptions{kind: TCPOptMSS, data: binary.BigEndian.AppendUint16(nil, uint16(n))}
+		case "wscale":
+			n, err := strconv.ParseUint(v, 0, 8)
+			if err != nil {
+				return nil, err
+			}
+			o = TCPOptions{kind: TCPOptWScale, data: []byte{uint8(n)}}
+		case "ts":
+			tsVal, tsEcr, err := parseTCPPair(v)
+			if err != nil {
+				return nil, err
+			}
+			o = TCPOptions{kind: TCPOptTS, data: binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, tsVal), tsEcr)}
+		case "sack":
+			if !strings.HasPrefix(v, "[") || !strings.HasSuffix(v, "]") {
+				return nil, fmt.Errorf("tcp sack must be a list [(left,right), ...]: %s", v)
+			}
+			o = TCPOptions{kind: TCPOptSACK, data: []byte{}}
+			for _, block := range splitOptions(v[1 : len(v)-1]) {
+				if len(strings.TrimSpace(block)) == 0 {
+					continue
+				}
+				left, right, err := parseTCPPair(block)
+				if err != nil {
+					return nil, err
+				}
+				o.data = binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(o.data, left), right)
+			}
+			if n := len(o.data) / 8; n == 0 || n > TCPMaxSACKList {
+				return nil, fmt.Errorf("tcp sack needs 1 to %d blocks: %s", TCPMaxSACKList, v)
+			}
+		default:
+			return nil, fmt.Errorf("unknown tcp option: %s", item)
+		}
+		o.length = uint8(2 + len(o.data))
+		opts = append(opts, o)
+	}
+
+	if n := len(encodeTCPOptions(opts)); n > TCPMaxOptLen {
+		return nil, fmt.Errorf("tcp options are %d bytes, more than %d bytes", n, TCPMaxOptLen)
+	}
+	return opts, nil
+}
+
+// encodeTCPOptions returns the option bytes padded with zeros to a multiple of 4 bytes.
+func encodeTCPOptions(opts []TCPOptions) []byte {
+
+	b := make([]byte, 0, TCPMaxOptLen)
+	for _, o := range opts {
+		if o.kind == TCPOptEOL || o.kind == TCPOptNOP {
+			b = append(b, o.kind)
+			continue
+		}
+		b = append(b, o.kind, o.length)
+		b = append(b, o.data...)
+	}
+	if n := len(b) % 4; n != 0 {
+		b = append(b, make([]byte, 4-n)...)
+	}
+	return b
+}
+
+// decodeTCPOptions decodes the option bytes of a TCP header, false is returned when the
+// options can not be represented by the options=[...] option.
+func decodeTCPOptions(data []byte) ([]TCPOptions, bool) {
+
+	opts := make([]TCPOptions, 0)
+	for i := 0; i < len(data); {
+		o := TCPOptions{kind: data[i]}
+
+		switch o.kind {
+		case TCPOptEOL:
+			// The end of the list is the padding when the rest of the bytes are zero
+			if bytes.Count(data[i:], []byte{0}) == len(data[i:]) && len(data[i:]) < 4 {
+				return opts, bytes.Equal(encodeTCPOptions(opts), data)
+			}
+			i++
+		case TCPOptNOP:
+			i++
+		case TCPOptMSS, TCPOptWScale, TCPOptSACKOK, TCPOptTS, TCPOptSACK:
+			if i+1 >= len(data) || int(data[i+1]) < 2 || i+int(data[i+1]) > len(data) {
+				return nil, false
+			}
+			o.length = data[i+1]
+			o.data = bytes.Clone(data[i+2 : i+int(o.length)])
+
+			valid := map[uint8]bool{
+				TCPOptMSS:    len(o.data) == 2,
+				TCPOptWScale: len(o.data) == 1,
+				TCPOptSACKOK: len(o.data) == 0,
+				TCPOptTS:     len(o.data) == 8,
+				TCPOptSACK:   len(o.data) > 0 && len(o.data)%8 == 0,
+			}
+			if !valid[o.kind] {
+				return nil, false
+			}
+			i += int(o.length)
+		default:
+			return nil, false
+		}
+		opts = append(opts, o)
+	}
+
+	return opts, bytes.Equal(encodeTCPOptions(opts), data)
+}
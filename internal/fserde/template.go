@@ -0,0 +1,427 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// A Template is the compiled frame data of a frame with the offsets and widths of the named
+// fields of the frame, i.e., "IPv4.src", "UDP.sport" or "TSC.seq". A repeated layer has the
+// index in the name, i.e., "IPv4[1].src" for the inner IPv4 layer of a tunnel.
+//
+// Template.Render() copies the frame data into a buffer and writes the field values given,
+// the IPv4 header, UDP, TCP, ICMP and GRE checksums are updated incrementally from the
+// changed bytes and the SCTP checksum, VXLAN flow entropy source port and FCS are computed
+// again. Render() does not allocate
+// memory, which allows the transmit path to generate the packets at line rate.
+//
+//	tmpl, _ := fr.Compile()
+//	seq, _ := tmpl.Field("TSC.seq")
+//	n, err := tmpl.Render(buf, Override{Field: seq, Value: 1})
+
+const (
+	templateMaxChecksums = 16 // Maximum number of checksums of a template
+	templateMaxWidth     = 16 // Maximum width of a field, the IPv6 address width
+	templateMaxSpans     = 2  // Data and pseudo header spans of a checksum
+
+	fnv32Offset = 2166136261 // FNV-1a 32-bit offset basis of the flow entropy hash
+	fnv32Prime  = 16777619   // FNV-1a 32-bit prime of the flow entropy hash
+)
+
+// TemplateField is a named field of the frame data of a template.
+type TemplateField struct {
+	Name   string // Name of the field, i.e., "IPv4.src" or "IPv4[1].src"
+	Offset uint16 // Offset of the field in the frame data
+	Width  uint8  // Width of the field in bytes
+}
+
+// Override is a field value written by Template.Render(), the value is written in network
+// byte order using the width of the field or Bytes is written when not nil.
+type Override struct {
+	Field int    // Index of the field returned by Template.Field()
+	Value uint64 // Value of a field of at most 8 bytes
+	Bytes []byte // Bytes of the field, the length must be the width of the field
+}
+
+// templateFieldDef is a field of a layer, the offset is from the start of the protocol.
+type templateFieldDef struct {
+	name   string
+	offset uint16
+	width  uint8
+}
+
+var vlanTemplateFields = []templateFieldDef{{"tci", 0, 2}}
+
+// templateFields are the fields of the layers which do not change the lengths of the frame.
+var templateFields = map[LayerName][]templateFieldDef{
+	LayerEther:  {{"dst", 0, 6}, {"src", 6, 6}},
+	LayerVlan:   vlanTemplateFields,
+	LayerDot1Q:  vlanTemplateFields,
+	LayerDot1AD: vlanTemplateFields,
+	LayerIPv4:   {{"tos", 1, 1}, {"id", 4, 2}, {"ttl", 8, 1}, {"src", 12, 4}, {"dst", 16, 4}},
+	LayerIPv6:   {{"hlim", 7, 1}, {"src", 8, 16}, {"dst", 24, 16}},
+	LayerUDP:    {{"sport", 0, 2}, {"dport", 2, 2}},
+	LayerTCP:    {{"sport", 0, 2}, {"dport", 2, 2}, {"seq", 4, 4}, {"ack", 8, 4}, {"flags", 13, 1}, {"window", 14, 2}},
+	LayerVxLan:  {{"vni", 4, 3}},
+	LayerTSC: {
+		{TSCFieldMagic, TSCMagicOffset, 4},
+		{TSCFieldStream, TSCStreamOffset, 4},
+		{TSCFieldSeq, TSCSeqOffset, 4},
+		{TSCFieldTimestamp, TSCTimestampOffset, 8},
+	},
+}
+
+// templateSpan is a range of the frame data covered by a checksum, the 16-bit words of the
+// checksum start at the start of the span.
+type templateSpan struct {
+	start, end int
+}
+
+// templateChecksum is a checksum of the frame data updated by Template.Render().
+type templateChecksum struct {
+	offset int                            // Offset of the checksum in the frame data
+	spans  [templateMaxSpans]templateSpan // Frame data and pseudo header covered by the checksum
+	nspans int                            // Number of spans
+	udp    bool                           // A zero UDP checksum is written as 0xffff
+	sctp   bool                           // The SCTP CRC32c is computed again over the first span
+}
+
+// templateEntropy is a VXLAN outer UDP source port computed from a hash of the inner frame
+// headers, the port is written before the checksum at index before as the outer checksums
+// include the port.
+type templateEntropy struct {
+	port   int          // Offset of the outer UDP source port in the frame data
+	span   templateSpan // Inner frame headers hashed for the port
+	before int          // Index of the first checksum including the port
+}
+
+// Template is the compiled frame data returned by Frame.Compile().
+type Template struct {
+	data    []byte
+	fields  []TemplateField
+	index   map[string]int
+	cksums  []templateChecksum
+	entropy []templateEntropy
+	fcsCalc bool // The FCS is the CRC32 of the frame
+	fcsBad  bool // The FCS is the CRC32 of the frame with the bits inverted
+}
+
+// Compile returns the template of the frame data with the named fields of the frame. The
+// frame data is the first frame of a frame with variants and a frame with a Fragment() or
+// Truncate() layer can not be compiled.
+func (fr *Frame) Compile() (*Template, error) {
+
+	if fr.frameType != NormalFrameType {
+		return nil, fmt.Errorf("frame %s is not a normal frame", fr.name)
+	}
+	if _, ok := fr.GetLayer(LayerFragment).(*FragmentLayer); ok {
+		return nil, fmt.Errorf("frame %s with a %s layer can not be compiled", fr.name, LayerFragment)
+	}
+	if _, ok := fr.GetLayer(LayerTruncate).(*TruncateLayer); ok {
+		return nil, fmt.Errorf("frame %s with a %s layer can not be compiled", fr.name, LayerTruncate)
+	}
+
+	t := &Template{
+		data:  bytes.Clone(fr.frame.Bytes()),
+		index: make(map[string]int),
+	}
+
+	for _, p := range fr.protocols {
+		name := string(p.name)
+		if p.index > 0 {
+			name = fmt.Sprintf("%s[%d]", p.name, p.index)
+		}
+		off := fr.protoOffset(p)
+		for _, def := range templateFields[p.name] {
+			f := TemplateField{Name: name + "." + def.name, Offset: off + def.offset, Width: def.width}
+			t.index[f.Name] = len(t.fields)
+			t.fields = append(t.fields, f)
+		}
+	}
+
+	if err := t.compileChecksums(fr); err != nil {
+		return nil, err
+	}
+
+	if el, ok := fr.GetLayer(LayerEther).(*EtherLayer); ok && el.fcsSet {
+		t.fcsCalc, t.fcsBad = el.fcsCalc, el.fcsBad
+	}
+
+	return t, nil
+}
+
+// compileChecksums adds the checksums of the frame, the inner most checksums are first as
+// the outer checksums include the inner checksums of a tunnel. The checksums given in the
+// frame string are not updated.
+func (t *Template) compileChecksums(fr *Frame) error {
+
+	span := func(proto *ProtoInfo) templateSpan {
+		off := int(fr.protoOffset(proto))
+		return templateSpan{start: off, end: off + int(fr.protoLength(proto))}
+	}
+
+	// The pseudo header addresses of the IP layer in front of the protocol
+	pseudo := func(proto *ProtoInfo) (templateSpan, bool) {
+		switch ip := fr.outerLayer(proto, LayerIPv4, LayerIPv6).(type) {
+		case *IPv4Layer:
+			off := int(fr.protoOffset(&ip.hdr.proto)) + ipv4AddrOffset
+			return templateSpan{start: off, end: off + ipv4AddrsLen}, true
+		case *IPv6Layer:
+			off := int(fr.protoOffset(&ip.hdr.proto)) + ipv6AddrOffset
+			return templateSpan{start: off, end: off + ipv6AddrsLen}, true
+		}
+		return templateSpan{}, false
+	}
+
+	for i := len(fr.layerInfo) - 1; i >= 0; i-- {
+		var c templateChecksum
+
+		switch l := fr.layerInfo[i].Layer.(type) {
+		case *IPv4Layer:
+			if l.cksum.set {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			s.end = s.start + int(l.hdr.proto.length)
+			c = templateChecksum{offset: s.start + ipv4ChecksumOffset, spans: [templateMaxSpans]templateSpan{s}, nspans: 1}
+		case *UDPLayer:
+			ps, ok := pseudo(&l.hdr.proto)
+			if _, v4 := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv4Layer); !ok || l.cksum.set || (v4 && !l.udpHdr.Checksum) {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + UDPChecksumOffset, spans: [templateMaxSpans]templateSpan{s, ps}, nspans: 2, udp: true}
+		case *TCPLayer:
+			ps, ok := pseudo(&l.hdr.proto)
+			if !ok || l.cksum.set {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + TCPChecksumOffset, spans: [templateMaxSpans]templateSpan{s, ps}, nspans: 2}
+		case *ICMPv4Layer:
+			if _, ok := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(*IPv4Layer); !ok {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + ICMPChecksumOffset, spans: [templateMaxSpans]templateSpan{s}, nspans: 1}
+		case *ICMPv6Layer:
+			ps, ok := pseudo(&l.hdr.proto)
+			if !ok {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + ICMPChecksumOffset, spans: [templateMaxSpans]templateSpan{s, ps}, nspans: 2}
+		case *GRELayer:
+			if l.greHdr.Flags&GREChecksumFlag == 0 {
+				continue
+			}
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + GREChecksumOffset, spans: [templateMaxSpans]templateSpan{s}, nspans: 1}
+		case *SCTPLayer:
+			s := span(&l.hdr.proto)
+			c = templateChecksum{offset: s.start + SCTPChecksumOffset, spans: [templateMaxSpans]templateSpan{s}, nspans: 1, sctp: true}
+		case *VxLanLayer:
+			udp, ok := fr.outerLayer(&l.hdr.proto, LayerUDP).(*UDPLayer)
+			if !ok || !l.entropy {
+				continue
+			}
+			if len(t.entropy) == templateMaxChecksums {
+				return fmt.Errorf("frame %s has more than %d %s layers", fr.name, templateMaxChecksums, LayerVxLan)
+			}
+			start, end := l.entropySpan()
+			t.entropy = append(t.entropy, templateEntropy{
+				port:   int(fr.protoOffset(&udp.hdr.proto)),
+				span:   templateSpan{start: int(start), end: int(end)},
+				before: len(t.cksums),
+			})
+			continue
+		default:
+			continue
+		}
+
+		if len(t.cksums) == templateMaxChecksums {
+			return fmt.Errorf("frame %s has more than %d checksums", fr.name, templateMaxChecksums)
+		}
+		t.cksums = append(t.cksums, c)
+	}
+
+	return nil
+}
+
+// Len returns the length of the frame data of the template.
+func (t *Template) Len() int {
+	return len(t.data)
+}
+
+// Fields returns the named fields of the template.
+func (t *Template) Fields() []TemplateField {
+	return append([]TemplateField{}, t.fields...)
+}
+
+// Field returns the index of the named field used in an Override, false is returned when
+// the template does not have the field.
+func (t *Template) Field(name string) (int, bool) {
+
+	i, ok := t.index[name]
+	return i, ok
+}
+
+// add returns the sum plus the difference of the old and new bytes at the offset of the
+// frame data within the span, false is returned when none of the bytes are in the span.
+func (s templateSpan) add(sum uint32, off int, old, new []byte) (uint32, bool) {
+
+	found := false
+	for i := range old {
+		o := off + i
+		if o < s.start || o >= s.end {
+			continue
+		}
+		shift := 0
+		if (o-s.start)%2 == 0 {
+			shift = 8
+		}
+		sum += uint32(^(uint16(old[i]) << shift)) + uint32(uint16(new[i])<<shift)
+		found = true
+	}
+	return sum, found
+}
+
+// update adds the difference of the old and new bytes at the offset to the sums of the
+// checksums, starting with the checksum at index first.
+func (t *Template) update(sums *[templateMaxChecksums]uint32, dirty *[templateMaxChecksums]bool,
+	first, off int, old, new []byte) {
+
+	for k := first; k < len(t.cksums); k++ {
+		c := &t.cksums[k]
+		for i := 0; i < c.nspans; i++ {
+			var found bool
+			if sums[k], found = c.spans[i].add(sums[k], off, old, new); found {
+				dirty[k] = true
+			}
+		}
+	}
+}
+
+// updateEntropy writes the flow entropy source ports written before the checksum at index k,
+// the port of an override is not changed.
+func (t *Template) updateEntropy(dst []byte, sums *[templateMaxChecksums]uint32, dirty *[templateMaxChecksums]bool,
+	fixed *[templateMaxChecksums]bool, k int) {
+
+	for j := range t.entropy {
+		e := &t.entropy[j]
+		if e.before != k || fixed[j] {
+			continue
+		}
+
+		h := uint32(fnv32Offset)
+		for _, c := range dst[e.span.start:e.span.end] {
+			h ^= uint32(c)
+			h *= fnv32Prime
+		}
+		var port [2]byte
+		binary.BigEndian.PutUint16(port[:], uint16(VxLanMinSrcPort+h%VxLanSrcPortRange))
+
+		b := dst[e.port : e.port+2]
+		if b[0] != port[0] || b[1] != port[1] {
+			t.update(sums, dirty, k, e.port, b, port[:])
+			copy(b, port[:])
+		}
+	}
+}
+
+// Render copies the frame data of the template into dst and writes the field values of the
+// overrides, the checksums and FCS are updated for the new values. The length of the frame
+// data is returned and dst must be at least Template.Len() bytes.
+func (t *Template) Render(dst []byte, overrides ...Override) (int, error) {
+
+	n := len(t.data)
+	if len(dst) < n {
+		return 0, fmt.Errorf("render buffer is %d bytes, the frame is %d bytes", len(dst), n)
+	}
+	dst = dst[:n]
+	copy(dst, t.data)
+
+	var sums [templateMaxChecksums]uint32
+	var dirty [templateMaxChecksums]bool
+	var fixed [templateMaxChecksums]bool // The entropy source port is an override
+
+	for i := range overrides {
+		o := &overrides[i]
+		if o.Field < 0 || o.Field >= len(t.fields) {
+			return 0, fmt.Errorf("render field index %d is not a template field", o.Field)
+		}
+		f := &t.fields[o.Field]
+
+		var buf [templateMaxWidth]byte
+		val := buf[:f.Width]
+		if o.Bytes != nil {
+			if len(o.Bytes) != int(f.Width) {
+				return 0, fmt.Errorf("render field %s is %d bytes, not %d bytes", f.Name, f.Width, len(o.Bytes))
+			}
+			copy(val, o.Bytes)
+		} else {
+			if f.Width < 8 && o.Value>>(8*uint(f.Width)) != 0 {
+				return 0, fmt.Errorf("render field %s value %#x is more than %d bytes", f.Name, o.Value, f.Width)
+			}
+			v := o.Value
+			for j := len(val) - 1; j >= 0; j-- {
+				val[j] = byte(v)
+				v >>= 8
+			}
+		}
+
+		b := dst[f.Offset : int(f.Offset)+int(f.Width)]
+		t.update(&sums, &dirty, 0, int(f.Offset), b, val)
+		copy(b, val)
+
+		for j := range t.entropy {
+			if p := t.entropy[j].port; p >= int(f.Offset) && p < int(f.Offset)+int(f.Width) {
+				fixed[j] = true
+			}
+		}
+	}
+
+	// The checksum changes are added to the outer checksums including the checksum
+	for k := range t.cksums {
+		t.updateEntropy(dst, &sums, &dirty, &fixed, k)
+		if !dirty[k] {
+			continue
+		}
+		c := &t.cksums[k]
+
+		var old [4]byte
+		if c.sctp {
+			b := dst[c.offset : c.offset+4]
+			copy(old[:], b)
+			binary.LittleEndian.PutUint32(b, SCTPChecksum(dst[c.spans[0].start:c.spans[0].end]))
+			t.update(&sums, &dirty, k+1, c.offset, old[:], b)
+			continue
+		}
+
+		b := dst[c.offset : c.offset+2]
+		copy(old[:], b)
+		cksum := ^reduceChecksum(uint32(^binary.BigEndian.Uint16(b)) + sums[k])
+		if c.udp && cksum == 0 {
+			cksum = 0xffff
+		}
+		binary.BigEndian.PutUint16(b, cksum)
+		t.update(&sums, &dirty, k+1, c.offset, old[:2], b)
+	}
+	t.updateEntropy(dst, &sums, &dirty, &fixed, len(t.cksums))
+
+	if t.fcsCalc || t.fcsBad {
+		fcs := crc32.ChecksumIEEE(dst[:n-FCSLen])
+		if t.fcsBad {
+			fcs = ^fcs
+		}
+		binary.LittleEndian.PutUint32(dst[n-FCSLen:], fcs)
+	}
+
+	return n, nil
+}
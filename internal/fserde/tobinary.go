@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"golang.org/x/net/ipv6"
 )
 
 type ToBinaryConverter interface {
@@ -17,163 +19,21 @@ type ToBinaryConverter interface {
 	WriteLayer() error
 }
 
-// splitLayerString returns the LayerName and LayerOptions strings by removing ()
-// plus removing leading and trailing whitespace.
-func (fr *Frame) splitLayerString(layer string) (lType, lOptions string) {
-
-	lType, lOptions = "", ""
-
-	if len(layer) > 0 {
-		// Split the frame string into two parts LayerType and LayerOptions strings.
-		// i.e., Ether(ethertype=0x800) to lType: "Ether" and lOptions: "ethertype=0x800".
-		str := strings.FieldsFunc(layer, func(r rune) bool {
-			return r == '(' || r == ')'
-		})
-		if len(str) == 2 {
-			lType = strings.TrimSpace(str[0])
-			lOptions = strings.TrimSpace(str[1])
-		} else if len(str) == 1 {
-			lType = strings.TrimSpace(str[0])
-			lOptions = ""
-		} else {
-			return
-		}
-		if lType == "" {
-			return "", ""
-		}
-	}
-	return
-}
-
 func (fr *Frame) toBinaryParse(li *LayerInfo) error {
 
 	if li == nil {
 		return fmt.Errorf("layer info is nil")
 	}
 
-	switch li.Name {
-	case LayerCount:
-		l := newFuncs.countNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerDot1AD:
-		l := newFuncs.dot1adNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerDot1Q:
-		l := newFuncs.dot1qNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerEcho:
-		l := newFuncs.echoNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerEther:
-		l := newFuncs.etherNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerICMPv4:
-		l := newFuncs.icmpv4NewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerICMPv6:
-		l := newFuncs.icmpv6NewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerIPv4:
-		l := newFuncs.ipv4NewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerIPv6:
-		l := newFuncs.ipv6NewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerPayload:
-		l := newFuncs.payloadNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerQinQ:
-		l := newFuncs.qinqNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerSCTP:
-		l := newFuncs.sctpNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerTCP:
-		l := newFuncs.tcpNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerTSC:
-		l := newFuncs.tscNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerUDP:
-		l := newFuncs.udpNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerVxLan:
-		l := newFuncs.vxlanNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	case LayerDefaults:
-		l := newFuncs.defaultsNewFn(fr)
-		if err := l.Parse(li.Opts); err != nil {
-			return err
-		}
-		fr.layersMap[li.Name] = l
-		li.Layer = l
-	default:
-		return fmt.Errorf("invalid layer type: '%s'", li.Name)
+	l, err := newLayer(fr, li.Name)
+	if err != nil {
+		return err
+	}
+	if err := l.Parse(li.Opts); err != nil {
+		return err
 	}
+	fr.addLayer(li.Name, l)
+	li.Layer = l
 
 	return nil
 }
@@ -181,79 +41,14 @@ func (fr *Frame) toBinaryParse(li *LayerInfo) error {
 func (fr *Frame) toBinaryApplyDefaults() error {
 
 	for _, s := range fr.layerInfo {
-		if layer, ok := fr.layersMap[s.Name]; ok {
-			switch d := layer.(type) {
-			case *CountLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *Dot1adLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *Dot1qLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *EchoLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *EtherLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *ICMPv4Layer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *ICMPv6Layer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *IPv4Layer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *IPv6Layer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *PayloadLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *QinQLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *SCTPLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *TCPLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *TSCLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *UDPLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *VxLanLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			case *DefaultsLayer:
-				if err := d.ApplyDefaults(); err != nil {
-					return err
-				}
-			default:
+		if layer := s.Layer; layer != nil {
+			l, ok := layer.(Layer)
+			if !ok {
 				return fmt.Errorf("Frame.toBinaryApplyDefaults:invalid layer type: '%T'", layer)
 			}
+			if err := l.ApplyDefaults(); err != nil {
+				return fr.layerError(s, err)
+			}
 		} else {
 			return fmt.Errorf("unknown layer: %s", s.Name)
 		}
@@ -264,161 +59,174 @@ func (fr *Frame) toBinaryApplyDefaults() error {
 
 func (fr *Frame) toBinaryUpdateLengths() error {
 
-	if payload, ok := fr.GetLayer(LayerPayload).(*PayloadLayer); ok {
-		if ip, ok := fr.GetLayer(LayerIPv4).(*IPv4Layer); ok {
-			if udp, ok := fr.GetLayer(LayerUDP).(*UDPLayer); ok {
-				udp.udpHdr.Length += uint16(payload.length)
-				ip.ipHdr.TotalLen += int(udp.udpHdr.Length)
-			} else if tcp, ok := fr.GetLayer(LayerTCP).(*TCPLayer); ok {
-				ip.ipHdr.TotalLen += int(tcp.tcpHdr.HdrLen) + int(payload.length)
+	// The payload length of a frame size is set before the lengths of the other layers
+	for i := 0; i < fr.LayerCount(LayerPayload); i++ {
+		if err := fr.GetLayerIndex(LayerPayload, i).(*PayloadLayer).updateFrameSize(); err != nil {
+			return fr.layerError(fr.layerInfoOf(LayerPayload, i), err)
+		}
+	}
+
+	// The SCTP DATA chunk padding is part of the L3 packet length
+	for i := 0; i < fr.LayerCount(LayerSCTPData); i++ {
+		fr.GetLayerIndex(LayerSCTPData, i).(*SCTPDataLayer).updateLength()
+	}
+
+	// The lengths are computed for each encapsulation level, from the layer to the end
+	// of the frame, which includes any inner layers.
+	for _, li := range fr.layerInfo {
+		switch l := li.Layer.(type) {
+		case *IPv4Layer:
+			if l.totalLenSet {
+				continue
 			}
-		} else if ip, ok := fr.GetLayer(LayerIPv6).(*IPv6Layer); ok {
-			if udp, ok := fr.GetLayer(LayerUDP).(*UDPLayer); ok {
-				udp.udpHdr.Length += uint16(payload.length)
-				ip.ip6Hdr.PayloadLen += int(udp.udpHdr.Length)
-			} else if tcp, ok := fr.GetLayer(LayerTCP).(*TCPLayer); ok {
-				ip.ip6Hdr.PayloadLen += int(tcp.tcpHdr.HdrLen)
+			l.ipHdr.TotalLen = int(fr.protoLength(&l.hdr.proto))
+		case *IPv6Layer:
+			l.ip6Hdr.PayloadLen = int(fr.protoLength(&l.hdr.proto)) - ipv6.HeaderLen
+		case *UDPLayer:
+			if l.lenSet {
+				continue
 			}
+			l.udpHdr.Length = fr.protoLength(&l.hdr.proto)
 		}
 	}
 
+	// The offsets of the protocols following a layer may change when the layer length
+	// is set by the default frame values or the padding is added.
+	for _, p := range fr.protocols {
+		p.offset = fr.protoOffset(p)
+	}
+
 	return nil
 }
 
 func (fr *Frame) toBinaryWriteLayer() error {
 
 	for _, s := range fr.layerInfo {
-		if layer, ok := fr.layersMap[s.Name]; ok {
-			switch d := layer.(type) {
-			case *CountLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *Dot1adLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *Dot1qLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *EchoLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *EtherLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *ICMPv4Layer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *ICMPv6Layer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *IPv4Layer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *IPv6Layer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *PayloadLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *QinQLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *SCTPLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *TCPLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *TSCLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *UDPLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *VxLanLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			case *DefaultsLayer:
-				if err := d.WriteLayer(); err != nil {
-					return err
-				}
-			default:
+		if layer := s.Layer; layer != nil {
+			l, ok := layer.(Layer)
+			if !ok {
 				return fmt.Errorf("Frame.toBinaryWrite:invalid layer type: '%T'", layer)
 			}
+			if err := l.WriteLayer(); err != nil {
+				return fr.layerError(s, err)
+			}
 		} else {
 			return fmt.Errorf("unknown layer: %s", s.Name)
 		}
 	}
 
+	for i := 0; i < fr.LayerCount(LayerSCTPData); i++ {
+		fr.GetLayerIndex(LayerSCTPData, i).(*SCTPDataLayer).writePadding()
+	}
+
 	return nil
 }
 
 func (fr *Frame) toBinaryUpdateL4Checksum() error {
 
-	if payload, ok := fr.GetLayer(LayerPayload).(*PayloadLayer); ok {
-		if ip, ok := fr.GetLayer(LayerIPv4).(*IPv4Layer); ok {
-			if udp, ok := fr.GetLayer(LayerUDP).(*UDPLayer); ok {
-				if udp.udpHdr.Checksum {
-					data := fr.frame
-					off := fr.GetOffset(LayerUDP)
-					len := fr.GetLength(LayerUDP)
-					len -= UDPHeaderLen
-
-					var d []byte
-					if len > 0 {
-						d = data.Bytes()[off+UDPHeaderLen : (off + UDPHeaderLen + len)]
-					} else {
-						d = data.Bytes()[off+UDPHeaderLen:]
-					}
-					cksum := IPv4UDPChecksum(&ip.ipHdr, &udp.udpHdr, d)
-
-					data.WriteValueAt(int(off+uint16(UDPChecksumOffset)), cksum)
-				}
-			} else if tcp, ok := fr.GetLayer(LayerTCP).(*TCPLayer); ok {
-				data := fr.frame
-				off := fr.GetOffset(LayerTCP)
-				len := fr.GetLength(LayerTCP)
-				len -= tcp.tcpHdr.HdrLen
-
-				var d []byte
-				if len > 0 {
-					d = data.Bytes()[off+TCPHeaderLen : (off + TCPHeaderLen + len)]
-				} else {
-					d = data.Bytes()[off+TCPHeaderLen:]
-				}
-				cksum := IPv4TCPChecksum(&ip.ipHdr, &tcp.tcpHdr, d)
-
-				data.WriteValueAt(int(off+uint16(TCPChecksumOffset)), cksum)
-			}
-		} else if ip, ok := fr.GetLayer(LayerIPv6).(*IPv6Layer); ok {
-			if udp, ok := fr.GetLayer(LayerUDP).(*UDPLayer); ok {
-				udp.udpHdr.Length += uint16(payload.length)
-				ip.ip6Hdr.PayloadLen += int(udp.udpHdr.Length)
-			} else if tcp, ok := fr.GetLayer(LayerTCP).(*TCPLayer); ok {
-				ip.ip6Hdr.PayloadLen += int(tcp.tcpHdr.HdrLen)
+	if _, ok := fr.GetLayer(LayerPayload).(*PayloadLayer); !ok {
+		return nil
+	}
+
+	// The inner most checksums are computed first, as the outer L4 checksums
+	// include the inner layers of a tunnel.
+	for i := len(fr.layerInfo) - 1; i >= 0; i-- {
+		var err error
+
+		switch l := fr.layerInfo[i].Layer.(type) {
+		case *UDPLayer:
+			err = l.updateChecksum()
+		case *TCPLayer:
+			err = l.updateChecksum()
+		case *ICMPv4Layer:
+			err = l.updateChecksum()
+		case *ICMPv6Layer:
+			err = l.updateChecksum()
+		case *SCTPLayer:
+			err = l.updateChecksum()
+		case *GRELayer:
+			err = l.updateChecksum()
+		case *VxLanLayer:
+			// The source port is part of the outer UDP checksum
+			err = l.updateSourcePort()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toBinaryFinish patches and fragments the frame, checks the MTU, appends the FCS and
+// truncates the frame data, which is done after the lengths and checksums are written as
+// each changes the frame data. The frame data is the whole datagram and the fragments are kept when the frame is
+// fragmented.
+func (fr *Frame) toBinaryFinish() error {
+
+	for i := 0; i < fr.LayerCount(LayerPatch); i++ {
+		if err := fr.GetLayerIndex(LayerPatch, i).(*PatchLayer).patch(); err != nil {
+			return fr.layerError(fr.layerInfoOf(LayerPatch, i), err)
+		}
+	}
+
+	frames := [][]byte{bytes.Clone(fr.frame.Bytes())}
+
+	fl, fragment := fr.GetLayer(LayerFragment).(*FragmentLayer)
+	if fragment {
+		var err error
+		if frames, err = fl.fragment(); err != nil {
+			return fr.layerError(fr.layerInfoOf(LayerFragment, 0), err)
+		}
+	}
+
+	el, _ := fr.GetLayer(LayerEther).(*EtherLayer)
+	tl, _ := fr.GetLayer(LayerTruncate).(*TruncateLayer)
+	for i, b := range frames {
+		if el != nil {
+			if err := el.checkMTU(b); err != nil {
+				return err
 			}
+			b = el.appendFCS(b)
 		}
+		if tl != nil {
+			b = tl.truncate(b)
+		}
+		frames[i] = b
+	}
+
+	if fragment {
+		fr.fragments = frames
+	} else {
+		fr.frame.Reset()
+		fr.frame.Append(frames[0])
 	}
 
 	return nil
 }
 
+// frames returns the frame data of each fragment or the frame data when the frame is not
+// fragmented.
+func (fr *Frame) frames() [][]byte {
+
+	if fr.fragments != nil {
+		return fr.fragments
+	}
+	return [][]byte{fr.frame.Bytes()}
+}
+
+// layerInfoOf returns the layer information of the layer with the given name and index.
+func (fr *Frame) layerInfoOf(name LayerName, index int) *LayerInfo {
+
+	layer := fr.GetLayerIndex(name, index)
+	for _, li := range fr.layerInfo {
+		if li.Layer == layer {
+			return li
+		}
+	}
+	return &LayerInfo{Name: name}
+}
+
 func (fr *Frame) layerInfoNew(lName, lOptions string) (*LayerInfo, error) {
 
 	lType := layerTypeFromName(lName)
@@ -441,11 +249,14 @@ func (fr *Frame) toBinaryAddDefaultLayers() error {
 	// Add a payload layer to the frame if one doesn't already exist
 	if _, ok := fr.GetLayer(LayerPayload).(*PayloadLayer); !ok {
 		opts := ""
-		l := newFuncs.payloadNewFn(fr)
+		l, err := newLayer(fr, LayerPayload)
+		if err != nil {
+			return err
+		}
 		if err := l.Parse(opts); err != nil {
 			return err
 		}
-		fr.layersMap[LayerPayload] = l
+		fr.addLayer(LayerPayload, l)
 
 		li := &LayerInfo{
 			Name:  LayerPayload,
@@ -459,11 +270,14 @@ func (fr *Frame) toBinaryAddDefaultLayers() error {
 	// Add a payload layer to the frame if one doesn't already exist
 	if _, ok := fr.GetLayer(LayerCount).(*CountLayer); !ok {
 		opts := "1"
-		l := newFuncs.countNewFn(fr)
+		l, err := newLayer(fr, LayerCount)
+		if err != nil {
+			return err
+		}
 		if err := l.Parse(opts); err != nil {
 			return err
 		}
-		fr.layersMap[LayerCount] = l
+		fr.addLayer(LayerCount, l)
 
 		li := &LayerInfo{
 			Name:  LayerCount,
@@ -479,57 +293,86 @@ func (fr *Frame) toBinaryAddDefaultLayers() error {
 
 func (f *FrameSerde) toBinaryFrame(frameString string, frameType FrameType) (*Frame, error) {
 
-	// split the string into slices of strings for the layers then trim those layer strings
-	splitLayers := func(str string) []string {
-		var layers []string
-
-		for _, v := range strings.Split(str, "/") {
-			layers = append(layers, strings.TrimSpace(v))
-		}
-		return layers
+	// Replace the variable references with the variable values
+	frameString, err := f.expandVars(frameString)
+	if err != nil {
+		return nil, err
 	}
 
-	// Split frame string into slices of strings name and frame text
-	s := strings.Split(frameString, ":=")
-	if len(s) != 2 {
-		return nil, fmt.Errorf("missing ':=' <FrameName>:=<Layers>: %v", frameString)
+	// Parse the frame string into the frame name and the layer names and options
+	fn, err := parseFrameString(frameString)
+	if err != nil {
+		return nil, err
 	}
-	k := strings.TrimSpace(s[0]) // Frame name or key
-	v := strings.TrimSpace(s[1]) // Frame string to be encoded
 
-	key := FrameKey{name: k, ftype: NormalFrameType}
+	key := FrameKey{name: fn.name, ftype: NormalFrameType}
 	if _, ok := f.frames[key]; ok {
-		return nil, fmt.Errorf("duplicate frame name: %v", k)
+		return nil, fmt.Errorf("duplicate frame name: %v", fn.name)
+	}
+
+	opts := make([]string, 0, len(fn.layers))
+	for _, ln := range fn.layers {
+		opts = append(opts, ln.opts)
+	}
+
+	mods, err := newFrameModifiers(fn.name, opts)
+	if err != nil {
+		return nil, &ParseError{Frame: fn.name, Err: err}
+	}
+
+	fr, err := f.toBinaryLayers(fn.name, frameType, fn.layers, mods.layerOptions(0), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode a frame for each of the Count() frames with the field modifier values or the
+	// IMIX frame sizes, the count is at least the number of IMIX frames. The fragments of
+	// each frame are added in order when the frame is fragmented.
+	imix, _ := fr.GetLayer(LayerIMIX).(*IMIXLayer)
+	_, fragment := fr.GetLayer(LayerFragment).(*FragmentLayer)
+	if frameType == NormalFrameType && (mods.enabled() || imix != nil || fragment) {
+		fr.variants = fr.frames()
+
+		cl := fr.GetLayer(LayerCount).(*CountLayer)
+		if imix != nil && int(cl.count) < len(imix.sequence) {
+			cl.count = uint32(len(imix.sequence))
+		}
+		for i := 1; i < int(cl.count); i++ {
+			vf, err := f.toBinaryLayers(fn.name, frameType, fn.layers, mods.layerOptions(i), i)
+			if err != nil {
+				return nil, err
+			}
+			fr.variants = append(fr.variants, vf.frames()...)
+		}
 	}
 
-	// split up the frame string into the layers for encoding later
+	return fr, nil
+}
+
+// toBinaryLayers parses the layers of a frame and encodes the frame data, the options of
+// each layer are given as the options may contain the field modifier values of the variant.
+func (f *FrameSerde) toBinaryLayers(name string, frameType FrameType, layers []layerNode, opts []string, variant int) (*Frame, error) {
+
 	fr := &Frame{
 		serde:     f,
 		frameType: frameType,
-		name:      k,
+		name:      name,
+		variant:   variant,
 		layersMap: make(LayerMap, 0),
 		protocols: make([]*ProtoInfo, 0),
 		frame:     &MyBuffer{Buf: bytes.Buffer{}},
 	}
 
-	layers := splitLayers(v)
-	if len(layers) == 0 {
-		return nil, fmt.Errorf("empty frame string: %v", v)
-	}
-
 	// Build up the layer information and parse each layer
-	for _, str := range layers {
-		lName, lOptions := fr.splitLayerString(str)
-		if len(lName) == 0 {
-			return nil, fmt.Errorf("invalid layer string: '%s'", str)
-		}
-
-		if li, err := fr.layerInfoNew(lName, lOptions); err != nil {
-			return nil, err
+	for i, ln := range layers {
+		if li, err := fr.layerInfoNew(ln.name, opts[i]); err != nil {
+			return nil, parseError(name, ln.pos, "%v", err)
 		} else {
+			li.pos = ln.pos
+
 			// process the layers into the encoded frame data
 			if err := fr.toBinaryParse(li); err != nil {
-				return nil, err
+				return nil, fr.layerError(li, err)
 			}
 		}
 	}
@@ -543,6 +386,10 @@ func (f *FrameSerde) toBinaryFrame(frameString string, frameType FrameType) (*Fr
 			return nil, err
 		}
 
+		if err := fr.toBinaryInferProtocols(); err != nil {
+			return nil, err
+		}
+
 		if err := fr.toBinaryUpdateLengths(); err != nil {
 			return nil, err
 		}
@@ -554,6 +401,10 @@ func (f *FrameSerde) toBinaryFrame(frameString string, frameType FrameType) (*Fr
 		if err := fr.toBinaryUpdateL4Checksum(); err != nil {
 			return nil, err
 		}
+
+		if err := fr.toBinaryFinish(); err != nil {
+			return nil, err
+		}
 	}
 
 	return fr, nil
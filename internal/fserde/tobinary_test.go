@@ -4,7 +4,15 @@
 package fserde
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"testing"
@@ -58,6 +66,92 @@ var (
 			"IPv4(dst=192.168.1.1)/" +
 			"TCP(sport=5685, dport=1000, seq=4000, ack=4001, window=1024, flags=[ACK | PSH])/" +
 			"Defaults(Defaults-2)",
+		"Port7:=Ether(dst=00:11:22:33:44:55, proto=0x86dd)/" +
+			"IPv6(src=2001:db8::1, dst=2001:db8::2, tc=0x10, flowlabel=0x12345, hlim=32)/" +
+			"UDP(sport=1234, dport=5678)/" +
+			"Payload(size=26, fill=0x5a)",
+		"Port8:=Ether(dst=00:11:22:33:44:55, proto=0x86dd)/" +
+			"Dot1Q(vlan=100)/" +
+			"IPv6(src=fe80::1, dst=fe80::2)/" +
+			"TCP(sport=5685, dport=80, seq=1, flags=[SYN])/" +
+			"Defaults(Defaults-2)",
+		"Port9:=Ether(src=00:11:22:33:44:55, proto=0x86dd)/" +
+			"IPv6(src=2001:db8::1)/" +
+			"ICMPv6(type=ns, target=2001:db8::1:2, slla=00:11:22:33:44:55)",
+		"Port10:=Ether(dst=00:11:22:33:44:66, proto=0x86dd)/" +
+			"IPv6(src=2001:db8::1, dst=2001:db8::2)/" +
+			"ICMPv6(type=echo-request, id=0x1234, seq=7)/" +
+			"Payload(string='ping6')",
+		"Port11:=Ether(dst=00:11:22:33:44:66, proto=0x86dd)/" +
+			"IPv6(src=2001:db8::2, dst=2001:db8::1)/" +
+			"ICMPv6(type=na, flags=[S | O], target=2001:db8::2, tlla=00:11:22:33:44:66)",
+		"Port12:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(dst=10.0.0.1, src=10.0.0.2)/" +
+			"SCTP(sport=1, dport=2, vtag=0x1234)/" +
+			"SCTPData(tsn=1, stream=0, ppid=46)/" +
+			"Payload(string='hello')",
+		"Port13:=Ether(dst=00:11:22:33:44:66, proto=0x86dd)/" +
+			"IPv6(src=2001:db8::1, dst=2001:db8::2)/" +
+			"SCTP(sport=2905, dport=2905)/" +
+			"SCTPInit(tag=0xdeadbeef, rwnd=65535, os=10, mis=10, tsn=100)",
+		"Port14:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(dst=10.0.0.1, src=10.0.0.2)/" +
+			"SCTP(sport=3868, dport=3868, vtag=0x55aa)/" +
+			"SCTPHeartbeat(info=0x0102030405)/" +
+			"SCTPData(tsn=7, stream=3, ssn=2, ppid=60, flags=[U | B | E])/" +
+			"Payload(size=8, fill=0x42)",
+		"Port15:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"IPv4(src=192.168.0.1, dst=192.168.0.2, ttl=32)/" +
+			"UDP(sport=1234, dport=5678, checksum=true)/" +
+			"Payload(size=6, fill=0x11)",
+		"Port16:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"IPv6(src=2001:db8::1, dst=2001:db8::2)/" +
+			"TCP(sport=1234, dport=80, flags=[SYN])",
+		"Port17:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"UDP(checksum=true)/" +
+			"VxLan(vni=0x1234)/" +
+			"Ether(dst=00:aa:bb:cc:dd:ee, src=00:11:11:11:11:11, proto=0x800)/" +
+			"IPv4(src=192.168.1.1, dst=192.168.1.2)/" +
+			"UDP(sport=1000, dport=2000)/" +
+			"Payload(size=10, fill=0x33)",
+		"Port18:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"GRE(key=0x1234, seq=7, checksum=true)/" +
+			"IPv4(src=192.168.1.1, dst=192.168.1.2)/" +
+			"UDP(sport=1000, dport=2000)/" +
+			"Payload(size=5, fill=0x44)",
+		"Port19:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"NVGRE(vsid=0x123456, flowid=9)/" +
+			"Ether(dst=00:aa:bb:cc:dd:ee, src=00:11:11:11:11:11, proto=0x800)/" +
+			"IPv4(src=192.168.1.1, dst=192.168.1.2)/" +
+			"UDP(sport=1000, dport=2000)/" +
+			"Payload(size=4, fill=0x55)",
+		"Port20:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"UDP(sport=4321, checksum=true)/" +
+			"Geneve(vni=0x2345, opts=[0x0102:0x80:0x01020304, 0xffff:0x01, 0x0103:0x02:0x0a0b0c0d0e])/" +
+			"Ether(dst=00:aa:bb:cc:dd:ee, src=00:11:11:11:11:11, proto=0x800)/" +
+			"IPv4(src=192.168.1.1, dst=192.168.1.2)/" +
+			"UDP(sport=1000, dport=2000)/" +
+			"Payload(size=6, fill=0x66)",
+		"Port21:=Ether(dst=00:11:22:33:44:55)/" +
+			"MPLS(label=100, tc=5, ttl=10)/" +
+			"MPLS(label=0xfffff)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"UDP(sport=1000, dport=2000)/" +
+			"Payload(size=6, fill=0x77)",
+		"Port22:=Ether(src=00:01:02:03:04:05)/" +
+			"ARP(op=request, spa=10.0.0.1, tpa=10.0.0.2)",
+		"Port23:=Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+			"Dot1Q(vlan=10)/" +
+			"IPv4(src=10.0.0.1, dst=10.0.0.2)/" +
+			"UDP(sport=1000, dport=2000, checksum=true)/" +
+			"TSC(stream=7, seq=3)/" +
+			"Payload(size=12, fill=0x88)",
 	}
 	toBinaryDefaultFrames = []string{
 		"Defaults-0 := Ether(src=00:01:02:03:04:FF, proto=0x800)/" +
@@ -89,6 +183,66 @@ var (
 	}
 )
 
+// testTagLayer is a layer added with RegisterLayer() using only the exported API.
+type testTagLayer struct {
+	hdr *LayerHdr
+	id  uint16
+}
+
+const testTagName LayerName = "Tag"
+
+var testTagType LayerType
+
+func testTagNew(fr *Frame) Layer {
+	return &testTagLayer{hdr: LayerConstructor(fr, testTagName, testTagType)}
+}
+
+func (l *testTagLayer) Name() LayerName {
+	return testTagName
+}
+
+func (l *testTagLayer) String() string {
+	return fmt.Sprintf("%s(id=%#x)", l.Name(), l.id)
+}
+
+func (l *testTagLayer) Parse(opts string) error {
+
+	for _, opt := range strings.Split(opts, ",") {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+		key, val, _ := strings.Cut(opt, "=")
+		if strings.TrimSpace(key) != "id" {
+			return fmt.Errorf("unknown tag option: [%s]", opt)
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(val), 0, 16)
+		if err != nil {
+			return err
+		}
+		l.id = uint16(v)
+	}
+
+	return l.hdr.AddProtocol(2)
+}
+
+func (l *testTagLayer) ApplyDefaults() error {
+
+	if d := l.hdr.Frame().DefaultsFrame(); d != nil && l.id == 0 {
+		if dl, ok := d.GetLayerIndex(testTagName, l.hdr.Index()).(*testTagLayer); ok {
+			l.id = dl.id
+		}
+	}
+	return nil
+}
+
+func (l *testTagLayer) WriteLayer() error {
+
+	l.hdr.Frame().Data().Append(l.id)
+
+	return nil
+}
+
 func TestDeserializeBegin(t *testing.T) {
 
 	g := goblin.Goblin(t)
@@ -199,6 +353,1441 @@ func TestDeserializeBegin(t *testing.T) {
 			}
 		})
 
+		g.It("ToBinary IPv6", func() {
+			if fg, err := Create("Test 5", defs); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[8])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port7", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 40 + 8 + 26)
+				g.Assert(b[14:18]).Equal([]byte{0x61, 0x01, 0x23, 0x45}) // version, tc and flow label
+				g.Assert(b[18:22]).Equal([]byte{0x00, 8 + 26, ProtocolUDP, 32})
+
+				// The checksum over the pseudo-header and UDP datagram must be 0xffff
+				sum := dataChecksum(b[22:54], 32) + uint32(8+26) + ProtocolUDP + dataChecksum(b[54:], len(b[54:]))
+				g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+
+				err = fg.StringToBinary("Bad:=Ether()/IPv6(src=10.0.0.1)")
+				g.Assert(err != nil).IsTrue("IPv4 address in IPv6 layer should fail")
+			}
+
+			// The hop limit of the defaults frame is used when the frame does not give one
+			cfg := &FrameSerdeConfig{Defaults: []string{"D6:=Ether()/IPv6(hlim=5)/UDP()"}}
+			if fg, err := Create("Test 5", cfg); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Hlim0:=Ether()/IPv6()/UDP()/Payload(size=4)/Defaults(D6)",
+					"Hlim1:=Ether()/IPv6(hlim=0)/UDP()/Payload(size=4)/Defaults(D6)",
+					"Hlim2:=Ether()/IPv6()/UDP()/Payload(size=4)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				for name, hlim := range map[string]uint8{"Hlim0": 5, "Hlim1": 0, "Hlim2": IPv6DefaultHopLimit} {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					g.Assert(fr.frame.Bytes()[21]).Equal(hlim, name)
+				}
+			}
+		})
+
+		g.It("ToBinary ICMPv6", func() {
+			if fg, err := Create("Test 6", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[10])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port9", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 40 + 8 + 16 + 8)
+				g.Assert(b[0:6]).Equal([]byte{0x33, 0x33, 0xff, 0x01, 0x00, 0x02})
+				g.Assert(b[20:22]).Equal([]byte{ProtocolICMPv6, ICMPv6NDHopLimit})
+				g.Assert(b[38:54]).Equal([]byte{0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01, 0xff, 0x01, 0x00, 0x02})
+				g.Assert(b[54]).Equal(uint8(ICMPv6NeighborSolicitation))
+				g.Assert(b[78:80]).Equal([]byte{ICMPv6OptSrcLLAddr, 1})
+
+				// The checksum over the pseudo-header and ICMPv6 message must be 0xffff
+				sum := dataChecksum(b[22:54], 32) + uint32(len(b[54:])) + ProtocolICMPv6 + dataChecksum(b[54:], len(b[54:]))
+				g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+
+				err = fg.StringToBinary("Bad:=Ether()/IPv6()/ICMPv6(type=foo)")
+				g.Assert(err != nil).IsTrue("invalid ICMPv6 type should fail")
+
+				g.Assert(fg.StringToBinary("Na0:=Ether()/IPv6()/ICMPv6(type=na, flags=5, target=2001:db8::2)") == nil).IsTrue("numeric flags failed")
+				fr, _ = fg.GetFrame("Na0", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[58]).Equal(uint8(5 << 5))
+
+				for _, bad := range []string{
+					"Bad1:=Ether()/IPv6()/ICMPv6(type=na, flags=[X])",
+					"Bad2:=Ether()/IPv6()/ICMPv6(type=na, flags=9)",
+					"Bad3:=Ether()/IPv6()/ICMPv6(type=na, flags=abc)",
+					"Bad4:=Ether()/IPv6()/ICMPv6(type=echo-request, id=5, flags=[R])",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary ICMPv4", func() {
+			if fg, err := Create("Test 6", &FrameSerdeConfig{Defaults: []string{"D4:=Ether()/IPv4()/ICMPv4(type=8, ident=7, seq=3)"}}); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary("Icmp0:=Ether()/IPv4()/ICMPv4(seq=9)/Payload(size=4)/Defaults(D4)")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Icmp0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[34]).Equal(uint8(8))
+				g.Assert(b[38:42]).Equal([]byte{0x00, 0x07, 0x00, 0x09})
+				g.Assert(^reduceChecksum(dataChecksum(b[34:], len(b[34:])))).Equal(uint16(0))
+			}
+		})
+
+		g.It("ToBinary SCTP", func() {
+			if fg, err := Create("Test 7", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary(toBinaryFrames[13:16])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				// The checksum is the CRC32c over the packet with a zero checksum field
+				crc32c := func(b []byte) uint32 {
+					pkt := append([]byte{}, b...)
+					copy(pkt[SCTPChecksumOffset:], []byte{0, 0, 0, 0})
+					return crc32.Checksum(pkt, crc32.MakeTable(crc32.Castagnoli))
+				}
+
+				fr, _ := fg.GetFrame("Port12", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 12 + 16 + 5 + 3)
+				g.Assert(b[23]).Equal(uint8(ProtocolSCTP))
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(20 + 12 + 16 + 5 + 3))
+				g.Assert(b[34:42]).Equal([]byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x12, 0x34})
+				g.Assert(b[46:50]).Equal([]byte{SCTPChunkData, 0x03, 0x00, 16 + 5})
+				g.Assert(b[58:62]).Equal([]byte{0x00, 0x00, 0x00, 46})
+				g.Assert(string(b[62:67])).Equal("hello")
+				g.Assert(b[67:]).Equal([]byte{0, 0, 0})
+				g.Assert(binary.LittleEndian.Uint32(b[42:])).Equal(crc32c(b[34:]))
+
+				fr, _ = fg.GetFrame("Port13", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 40 + 12 + 20)
+				g.Assert(b[20]).Equal(uint8(ProtocolSCTP))
+				g.Assert(b[66:70]).Equal([]byte{SCTPChunkInit, 0x00, 0x00, SCTPInitLen})
+				g.Assert(b[70:74]).Equal([]byte{0xde, 0xad, 0xbe, 0xef})
+				g.Assert(binary.LittleEndian.Uint32(b[62:])).Equal(crc32c(b[54:]))
+
+				fr, _ = fg.GetFrame("Port14", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 12 + 16 + 16 + 8)
+				g.Assert(b[46:54]).Equal([]byte{SCTPChunkHeartbeat, 0x00, 0x00, 13, 0x00, 0x01, 0x00, 9})
+				g.Assert(b[59:62]).Equal([]byte{0, 0, 0})
+				g.Assert(b[62:66]).Equal([]byte{SCTPChunkData, 0x07, 0x00, 16 + 8})
+				g.Assert(binary.LittleEndian.Uint32(b[42:])).Equal(crc32c(b[34:]))
+
+				err = fg.StringToBinary("Bad:=Ether()/IPv4()/SCTP(foo=1)")
+				g.Assert(err != nil).IsTrue("unknown SCTP option should fail")
+
+				err = fg.StringToBinary("Bad1:=Ether()/IPv4()/SCTP()/SCTPData(tsn=1)/SCTPInit(tag=1)/Payload(size=3)")
+				g.Assert(err != nil).IsTrue("a chunk after the DATA chunk should fail")
+			}
+		})
+
+		g.It("ToBinary repeated layers", func() {
+			if fg, err := Create("Test 8", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary(toBinaryFrames[16:18])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port15", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 20 + 8 + 6)
+
+				outer, ok := fr.GetLayer(LayerIPv4).(*IPv4Layer)
+				g.Assert(ok).IsTrue("outer IPv4 layer not found")
+				inner, ok := fr.GetLayer("IPv4[1]").(*IPv4Layer)
+				g.Assert(ok).IsTrue("inner IPv4 layer not found")
+				g.Assert(fr.GetLayerIndex(LayerIPv4, 1) == inner).IsTrue("indexed layer mismatch")
+				g.Assert(fr.GetLayer("IPv4[0]") == outer).IsTrue("outer layer mismatch")
+				g.Assert(fr.GetLayer("IPv4[2]") == nil).IsTrue("third IPv4 layer should not exist")
+				g.Assert(fr.LayerCount(LayerIPv4)).Equal(2)
+
+				g.Assert(fr.GetOffset(LayerIPv4)).Equal(uint16(14))
+				g.Assert(fr.GetOffset("IPv4[1]")).Equal(uint16(34))
+				g.Assert(fr.GetLength("IPv4[1]")).Equal(uint16(20 + 8 + 6))
+
+				// Lengths and protocols are set per encapsulation level
+				g.Assert(b[16:18]).Equal([]byte{0x00, 20 + 20 + 8 + 6})
+				g.Assert(b[23]).Equal(uint8(ProtocolIPv4))
+				g.Assert(b[36:38]).Equal([]byte{0x00, 20 + 8 + 6})
+				g.Assert(b[43]).Equal(uint8(ProtocolUDP))
+				g.Assert(reduceChecksum(dataChecksum(b[14:34], 20))).Equal(uint16(0xffff))
+				g.Assert(reduceChecksum(dataChecksum(b[34:54], 20))).Equal(uint16(0xffff))
+
+				// The UDP checksum uses the inner IPv4 addresses
+				sum := dataChecksum(b[46:54], 8) + uint32(8+6) + ProtocolUDP + dataChecksum(b[54:], len(b[54:]))
+				g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+
+				fr, _ = fg.GetFrame("Port16", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 40 + 20)
+				g.Assert(b[23]).Equal(uint8(ProtocolIPv6))
+				g.Assert(b[40]).Equal(uint8(ProtocolTCP))
+			}
+		})
+
+		g.It("ToBinary VxLan", func() {
+			if fg, err := Create("Test 9", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[18])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port17", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 8 + 8 + 14 + 20 + 8 + 10)
+
+				// Outer UDP ports, the source port is the flow entropy value
+				sport := binary.BigEndian.Uint16(b[34:])
+				g.Assert(sport >= VxLanMinSrcPort).IsTrue(fmt.Sprintf("invalid source port %d", sport))
+				g.Assert(binary.BigEndian.Uint16(b[36:])).Equal(uint16(VxLanPort))
+				g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(8 + 8 + 14 + 20 + 8 + 10))
+
+				g.Assert(b[42:50]).Equal([]byte{VxLanValidVNIFlag, 0, 0, 0, 0x00, 0x12, 0x34, 0x00})
+				g.Assert(b[50:56]).Equal([]byte{0x00, 0xaa, 0xbb, 0xcc, 0xdd, 0xee})
+				g.Assert(binary.BigEndian.Uint16(b[66:])).Equal(uint16(20 + 8 + 10))
+
+				// The outer UDP checksum covers the inner frame
+				sum := dataChecksum(b[26:34], 8) + uint32(len(b[34:])) + ProtocolUDP + dataChecksum(b[34:], len(b[34:]))
+				g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+
+				// The same inner frame gives the same source port, a different inner frame
+				// gives a different source port and a given source port is not changed.
+				inner := "Ether(proto=0x800)/IPv4(src=192.168.1.1, dst=192.168.1.2)/UDP(sport=1000, dport=2000)"
+				err = fg.StringsToBinary([]string{
+					"Vx0:=Ether(proto=0x800)/IPv4()/UDP()/VxLan()/" + inner,
+					"Vx1:=Ether(proto=0x800)/IPv4()/UDP()/VxLan()/" + inner,
+					"Vx2:=Ether(proto=0x800)/IPv4()/UDP()/VxLan()/" + strings.Replace(inner, "1000", "1001", 1),
+					"Vx3:=Ether(proto=0x800)/IPv4()/UDP(sport=1234)/VxLan()/" + inner,
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				ports := []uint16{}
+				for _, name := range []string{"Vx0", "Vx1", "Vx2", "Vx3"} {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					ports = append(ports, binary.BigEndian.Uint16(fr.frame.Bytes()[34:]))
+				}
+				g.Assert(ports[0]).Equal(ports[1])
+				g.Assert(ports[0] != ports[2]).IsTrue("inner frame hash did not change the source port")
+				g.Assert(ports[3]).Equal(uint16(1234))
+
+				err = fg.StringToBinary("Bad:=Ether()/IPv4()/UDP()/VxLan(vni=0x1000000)")
+				g.Assert(err != nil).IsTrue("invalid VNI should fail")
+			}
+		})
+
+		g.It("ToBinary GRE", func() {
+			if fg, err := Create("Test 10", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary(toBinaryFrames[19:21])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port18", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 16 + 20 + 8 + 5)
+				g.Assert(b[23]).Equal(uint8(ProtocolGRE))
+
+				// Flags, protocol type of the inner IPv4 packet, key and sequence number
+				g.Assert(binary.BigEndian.Uint16(b[34:])).Equal(uint16(GREChecksumFlag | GREKeyFlag | GRESeqFlag))
+				g.Assert(binary.BigEndian.Uint16(b[36:])).Equal(uint16(EtherTypeIPv4))
+				g.Assert(binary.BigEndian.Uint32(b[42:])).Equal(uint32(0x1234))
+				g.Assert(binary.BigEndian.Uint32(b[46:])).Equal(uint32(7))
+				g.Assert(reduceChecksum(dataChecksum(b[34:], len(b[34:])))).Equal(uint16(0xffff))
+
+				fr, _ = fg.GetFrame("Port19", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 8 + 14 + 20 + 8 + 4)
+				g.Assert(b[23]).Equal(uint8(ProtocolGRE))
+				g.Assert(b[34:42]).Equal([]byte{0x20, 0x00, 0x65, 0x58, 0x12, 0x34, 0x56, 0x09})
+				g.Assert(b[42:48]).Equal([]byte{0x00, 0xaa, 0xbb, 0xcc, 0xdd, 0xee})
+
+				err = fg.StringToBinary("Bad:=Ether()/IPv4()/NVGRE(vsid=0x1000000)")
+				g.Assert(err != nil).IsTrue("invalid VSID should fail")
+			}
+		})
+
+		g.It("ToBinary Geneve", func() {
+			if fg, err := Create("Test 11", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[21])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port20", NormalFrameType)
+				b := fr.frame.Bytes()
+				optLen := 8 + 4 + 12
+				g.Assert(len(b)).Equal(14 + 20 + 8 + 8 + optLen + 14 + 20 + 8 + 6)
+				g.Assert(fr.GetLength(LayerGeneve)).Equal(uint16(8 + optLen + 14 + 20 + 8 + 6))
+
+				// Outer UDP destination port and the base header with the options length in
+				// 4 byte units, the critical flag and the protocol type of the inner frame.
+				g.Assert(binary.BigEndian.Uint16(b[36:])).Equal(uint16(GenevePort))
+				g.Assert(b[42:50]).Equal([]byte{uint8(optLen / 4), GeneveCriticalFlag, 0x65, 0x58, 0x00, 0x23, 0x45, 0x00})
+
+				// The option data is padded to a multiple of 4 bytes
+				g.Assert(b[50:58]).Equal([]byte{0x01, 0x02, 0x80, 0x01, 0x01, 0x02, 0x03, 0x04})
+				g.Assert(b[58:62]).Equal([]byte{0xff, 0xff, 0x01, 0x00})
+				g.Assert(b[62:74]).Equal([]byte{0x01, 0x03, 0x02, 0x02, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x00, 0x00, 0x00})
+				g.Assert(b[74:80]).Equal([]byte{0x00, 0xaa, 0xbb, 0xcc, 0xdd, 0xee})
+
+				sum := dataChecksum(b[26:34], 8) + uint32(len(b[34:])) + ProtocolUDP + dataChecksum(b[34:], len(b[34:]))
+				g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+
+				err = fg.StringToBinary("Gn0:=Ether(proto=0x800)/IPv4()/UDP()/Geneve(oam=true)/IPv4()/UDP()")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+				fr, _ = fg.GetFrame("Gn0", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[42:46]).Equal([]byte{0x00, GeneveOAMFlag, 0x08, 0x00})
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4()/UDP()/Geneve(vni=0x1000000)",
+					"Bad1:=Ether()/IPv4()/UDP()/Geneve(opts=0x0102:0x01)",
+					"Bad2:=Ether()/IPv4()/UDP()/Geneve(opts=[0x0102])",
+					"Bad3:=Ether()/IPv4()/UDP()/Geneve(opts=[0x0102:0x01:0x" + strings.Repeat("00", 128) + "])",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary MPLS", func() {
+			if fg, err := Create("Test 12", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[22])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port21", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 4 + 4 + 20 + 8 + 6)
+				g.Assert(fr.LayerCount(LayerMPLS)).Equal(2)
+				g.Assert(fr.GetOffset("MPLS[1]")).Equal(uint16(18))
+
+				// EtherType, label stack with the bottom of stack bit on the last label
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeMPLS))
+				g.Assert(binary.BigEndian.Uint32(b[14:])).Equal(uint32(100<<12 | 5<<9 | 10))
+				g.Assert(binary.BigEndian.Uint32(b[18:])).Equal(uint32(0xfffff<<12 | MPLSBoSFlag | DefaultTTL))
+				g.Assert(b[22] >> 4).Equal(uint8(4))
+
+				err = fg.StringsToBinary([]string{
+					"Mpls0:=Ether(dst=01:00:5e:00:00:01)/MPLS(label=16)/IPv4()/UDP()",
+					"Mpls1:=Ether(proto=0x800)/MPLS(label=16)/IPv4()/UDP()",
+					"Mpls2:=Ether()/MPLS(label=16, bos=false, ttl=0)/IPv4()/UDP()",
+					"Mpls3:=Ether(proto=0x800)/IPv4()/GRE()/MPLS(label=17)/IPv6()/UDP()",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ = fg.GetFrame("Mpls0", NormalFrameType)
+				g.Assert(binary.BigEndian.Uint16(fr.frame.Bytes()[12:])).Equal(uint16(EtherTypeMPLSMc))
+				fr, _ = fg.GetFrame("Mpls1", NormalFrameType)
+				g.Assert(binary.BigEndian.Uint16(fr.frame.Bytes()[12:])).Equal(uint16(EtherTypeIPv4))
+				fr, _ = fg.GetFrame("Mpls2", NormalFrameType)
+				g.Assert(binary.BigEndian.Uint32(fr.frame.Bytes()[14:])).Equal(uint32(16 << 12))
+
+				// The label stack inside of a tunnel does not change the outer EtherType
+				fr, _ = fg.GetFrame("Mpls3", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeIPv4))
+				g.Assert(binary.BigEndian.Uint16(b[36:])).Equal(uint16(EtherTypeMPLS))
+				g.Assert(binary.BigEndian.Uint32(b[38:])).Equal(uint32(17<<12 | MPLSBoSFlag | DefaultTTL))
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/MPLS(label=0x100000)",
+					"Bad1:=Ether()/MPLS(tc=8)",
+					"Bad2:=Ether()/MPLS(bos=maybe)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary ARP", func() {
+			if fg, err := Create("Test 13", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[23])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port22", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + ARPHeaderLen)
+
+				// Broadcast destination, ARP EtherType and the sender hardware address
+				// taken from the Ether source MAC address.
+				g.Assert(b[0:6]).Equal([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeARP))
+				g.Assert(b[14:22]).Equal([]byte{0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, ARPOpRequest})
+				g.Assert(b[22:28]).Equal([]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05})
+				g.Assert(b[28:32]).Equal([]byte{10, 0, 0, 1})
+				g.Assert(b[32:38]).Equal([]byte{0, 0, 0, 0, 0, 0})
+				g.Assert(b[38:42]).Equal([]byte{10, 0, 0, 2})
+
+				err = fg.StringsToBinary([]string{
+					"Arp0:=Ether(src=00:01:02:03:04:05)/ARP(op=reply, spa=10.0.0.2, tha=00:0a:0b:0c:0d:0e, tpa=10.0.0.1)",
+					"Arp1:=Ether(src=00:01:02:03:04:05)/ARP(spa=10.0.0.9, gratuitous=true)",
+					"Arp2:=Ether(dst=00:11:22:33:44:55, proto=0x1234)/ARP(op=3, sha=00:0a:0b:0c:0d:0e)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				// A reply is sent to the target hardware address
+				fr, _ = fg.GetFrame("Arp0", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[0:6]).Equal([]byte{0x00, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e})
+				g.Assert(binary.BigEndian.Uint16(b[20:])).Equal(uint16(ARPOpReply))
+
+				// A gratuitous ARP targets the sender IPv4 address
+				fr, _ = fg.GetFrame("Arp1", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[0:6]).Equal([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+				g.Assert(b[38:42]).Equal([]byte{10, 0, 0, 9})
+				g.Assert(b[32:38]).Equal([]byte{0, 0, 0, 0, 0, 0})
+
+				// Given values are not changed
+				fr, _ = fg.GetFrame("Arp2", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[0:6]).Equal([]byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(0x1234))
+				g.Assert(binary.BigEndian.Uint16(b[20:])).Equal(uint16(3))
+				g.Assert(b[22:28]).Equal([]byte{0x00, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e})
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/ARP(op=query)",
+					"Bad1:=Ether()/ARP(spa=10.0.0)",
+					"Bad2:=Ether()/ARP(tpa=2001:db8::1)",
+					"Bad3:=Ether()/ARP(sha=00:01)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary TSC", func() {
+			if fg, err := Create("Test 14", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary(toBinaryFrames[24])
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Port23", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 4 + 20 + 8 + TSCHeaderLen + 12)
+
+				proto := fr.GetProtocol(LayerTSC)
+				g.Assert(proto != nil).IsTrue("TSC protocol not found")
+				g.Assert(proto.Offset()).Equal(uint16(14 + 4 + 20 + 8))
+				g.Assert(proto.Length()).Equal(uint16(TSCHeaderLen))
+
+				off, ok := proto.FieldOffset(TSCFieldMagic)
+				g.Assert(ok).IsTrue("magic field not found")
+				g.Assert(binary.BigEndian.Uint32(b[off:])).Equal(uint32(TSCMagic))
+				off, _ = proto.FieldOffset(TSCFieldStream)
+				g.Assert(binary.BigEndian.Uint32(b[off:])).Equal(uint32(7))
+				off, _ = proto.FieldOffset(TSCFieldSeq)
+				g.Assert(binary.BigEndian.Uint32(b[off:])).Equal(uint32(3))
+				off, _ = proto.FieldOffset(TSCFieldTimestamp)
+				g.Assert(off).Equal(uint16(14 + 4 + 20 + 8 + TSCTimestampOffset))
+				_, ok = proto.FieldOffset("unknown")
+				g.Assert(ok).IsFalse("unknown field found")
+
+				// The payload follows the signature and the length matches the frame
+				g.Assert(fr.GetOffset(LayerPayload)).Equal(uint16(14 + 4 + 20 + 8 + TSCHeaderLen))
+				g.Assert(b[len(b)-12:]).Equal(bytes.Repeat([]byte{0x88}, 12))
+
+				// Patch the fields as the transmit path does and decode them again
+				rx := bytes.Clone(b)
+				off, _ = proto.FieldOffset(TSCFieldSeq)
+				binary.BigEndian.PutUint32(rx[off:], 1000)
+				off, _ = proto.FieldOffset(TSCFieldTimestamp)
+				binary.BigEndian.PutUint64(rx[off:], 0x0102030405060708)
+				binary.BigEndian.PutUint16(rx[14+4+20+6:], 0) // no UDP checksum
+
+				hdr, err := TSCDecode(rx)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("TSCDecode failed: %v", err))
+				g.Assert(hdr).Equal(TSCHdr{Magic: TSCMagic, StreamID: 7, SeqNum: 1000, Timestamp: 0x0102030405060708})
+
+				err = fg.StringToBinary("NoTsc:=Ether(proto=0x800)/IPv4()/UDP()/Payload(size=32)")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+				fr, _ = fg.GetFrame("NoTsc", NormalFrameType)
+				_, err = TSCDecode(fr.frame.Bytes())
+				g.Assert(err != nil).IsTrue("TSCDecode should fail without a signature")
+			}
+		})
+
+		g.It("ToBinary field modifiers", func() {
+			if fg, err := Create("Test 15", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				frameString := "Mod0:=Ether(dst=inc(00:11:22:33:44:fe, 4), proto=0x800)/" +
+					"IPv4(src=10.0.0.1..10.0.0.3, dst=10.0.1.1)/" +
+					"UDP(sport=rand(1024,65535), dport=2000, checksum=true)/" +
+					"Payload(size=8, fill=0x99)/Count(5)"
+				err := fg.StringsToBinary([]string{
+					frameString,
+					"Mod1:=Ether(proto=0x800)/IPv4(src=10.0.0.0..10.0.0.255 step 64, dst=2.2.2.2)/UDP()/Count(5)",
+					"Mod2:=Ether(proto=0x86dd)/IPv6(src=inc(2001:db8::ffff, 3), dst=2001:db8::1)/TCP(sport=0x10..0x11)/Count(3)",
+					"Mod3:=Ether(proto=0x800)/IPv4()/UDP()/Count(4)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Mod0", NormalFrameType)
+				variants := fr.Variants()
+				g.Assert(len(variants)).Equal(5)
+				g.Assert(variants[0]).Equal(fr.frame.Bytes())
+
+				macs := [][]byte{
+					{0x00, 0x11, 0x22, 0x33, 0x44, 0xfe},
+					{0x00, 0x11, 0x22, 0x33, 0x44, 0xff},
+					{0x00, 0x11, 0x22, 0x33, 0x45, 0x00},
+					{0x00, 0x11, 0x22, 0x33, 0x45, 0x01},
+					{0x00, 0x11, 0x22, 0x33, 0x44, 0xfe},
+				}
+				srcs := []byte{1, 2, 3, 1, 2}
+				for i, b := range variants {
+					g.Assert(len(b)).Equal(14 + 20 + 8 + 8)
+					g.Assert(b[0:6]).Equal(macs[i])
+					g.Assert(b[26:30]).Equal([]byte{10, 0, 0, srcs[i]})
+					sport := binary.BigEndian.Uint16(b[34:])
+					g.Assert(sport >= 1024).IsTrue(fmt.Sprintf("source port %d out of range", sport))
+
+					// The checksums are computed for each frame
+					g.Assert(reduceChecksum(dataChecksum(b[14:34], 20))).Equal(uint16(0xffff))
+					sum := dataChecksum(b[26:34], 8) + uint32(8+8) + ProtocolUDP + dataChecksum(b[34:], len(b[34:]))
+					g.Assert(reduceChecksum(sum)).Equal(uint16(0xffff))
+				}
+
+				// The random values are the same each time the frame string is encoded
+				fs, _ := Create("Test 15.1", nil)
+				defer fs.Destroy()
+				err = fs.StringToBinary(frameString)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+				rf, _ := fs.GetFrame("Mod0", NormalFrameType)
+				g.Assert(rf.Variants()).Equal(variants)
+
+				fr, _ = fg.GetFrame("Mod1", NormalFrameType)
+				for i, last := range []byte{0, 64, 128, 192, 0} {
+					g.Assert(fr.Variants()[i][26:30]).Equal([]byte{10, 0, 0, last})
+				}
+
+				fr, _ = fg.GetFrame("Mod2", NormalFrameType)
+				g.Assert(len(fr.Variants())).Equal(3)
+				g.Assert(fr.Variants()[2][22+12 : 22+16]).Equal([]byte{0x00, 0x01, 0x00, 0x01})
+				g.Assert(binary.BigEndian.Uint16(fr.Variants()[2][54:])).Equal(uint16(0x10))
+
+				fr, _ = fg.GetFrame("Mod3", NormalFrameType)
+				g.Assert(fr.Variants() == nil).IsTrue("frame without modifiers has variants")
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4(src=10.0.0.5..10.0.0.1)/Count(2)",
+					"Bad1:=Ether()/IPv4()/UDP(sport=rand(10,1))/Count(2)",
+					"Bad2:=Ether(dst=inc(00:11:22:33:44:55, 0))/Count(2)",
+					"Bad3:=Ether()/IPv4(src=10.0.0.1..10.0.0.9 step 0)/Count(2)",
+					"Bad4:=Ether()/IPv4(src=10.0.0.1..5)/Count(2)",
+					"Bad5:=Ether()/IPv4()/UDP(sport=rand(1,2,3))/Count(2)",
+					"Bad6:=Ether()/IPv4(src=10.0.0.1..10.0.0.3 by 1)/Count(2)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary registered layer", func() {
+			_, err := RegisterLayer(LayerUDP, testTagNew)
+			g.Assert(err != nil).IsTrue("registering a built-in layer name should fail")
+			_, err = RegisterLayer("Tag(1)", testTagNew)
+			g.Assert(err != nil).IsTrue("registering an invalid layer name should fail")
+			_, err = RegisterLayer("Tag", nil)
+			g.Assert(err != nil).IsTrue("registering a nil create function should fail")
+
+			testTagType, err = RegisterLayer(testTagName, testTagNew)
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("RegisterLayer failed: %v", err))
+			defer unregisterLayer(testTagName)
+			g.Assert(testTagType > MaxLayerType).IsTrue("layer type must follow the built-in layers")
+			g.Assert(testTagType.String()).Equal(testTagName)
+			_, err = RegisterLayer("tag", testTagNew)
+			g.Assert(err != nil).IsTrue("registering a layer twice should fail")
+
+			if fg, err := Create("Test 16", &FrameSerdeConfig{Defaults: []string{"DefTag:=Ether()/IPv4()/UDP()/Tag(id=0x55aa)"}}); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Tag0:=Ether(proto=0x800)/IPv4()/UDP(dport=5000)/tag(id=0x1234)/Payload(size=4)",
+					"Tag1:=Ether(proto=0x800)/IPv4()/UDP(dport=5000)/Tag()/Payload(size=4)/Defaults(DefTag)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Tag0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(len(b)).Equal(14 + 20 + 8 + 2 + 4)
+				g.Assert(b[42:44]).Equal([]byte{0x12, 0x34})
+				g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(8 + 2 + 4))
+				g.Assert(fr.GetOffset(testTagName)).Equal(uint16(42))
+				g.Assert(fr.GetLayer(testTagName).(*testTagLayer).String()).Equal("Tag(id=0x1234)")
+
+				fr, _ = fg.GetFrame("Tag1", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[42:44]).Equal([]byte{0x55, 0xaa})
+
+				g.Assert(fg.StringToBinary("Tag2:=Ether()/IPv4()/UDP()/Tag(vlan=1)") != nil).IsTrue("unknown option should fail")
+			}
+		})
+
+		g.It("ToBinary quoted strings and parse errors", func() {
+			if fg, err := Create("Test 17", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Quote0:=Ether(proto=0x800)/IPv4()/UDP()/Payload(string='http://a/b,c=d(e)')",
+					`Quote1:=Ether(proto=0x800)/IPv4()/UDP()/Payload(string="it's \x41\t\"x\"")`,
+					"Quote2 := Ether(proto=0x800) /\n\tIPv4(src=10.0.0.1)/\n\tTCP(flags=[SYN | ACK])/\n\tPayload(size=4)",
+					"Quote3:=Ether()/QinQ(Dot1q{vlan=0x22, prio=7}, Dot1q{vlan=0x33})/IPv4()/UDP()",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Quote0", NormalFrameType)
+				g.Assert(string(fr.frame.Bytes()[42:])).Equal("http://a/b,c=d(e)")
+				g.Assert(strings.Contains(fr.String(), "string='http://a/b,c=d(e)'")).IsTrue(fr.String())
+
+				fr, _ = fg.GetFrame("Quote1", NormalFrameType)
+				g.Assert(string(fr.frame.Bytes()[42:])).Equal("it's A\t\"x\"")
+				g.Assert(strings.Contains(fr.String(), `string='it\'s A\t"x"'`)).IsTrue(fr.String())
+
+				fr, _ = fg.GetFrame("Quote2", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[47]).Equal(byte(TCPSynFlag | TCPAckFlag))
+
+				fr, _ = fg.GetFrame("Quote3", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[12:20]).Equal([]byte{0x88, 0xa8, 0xe0, 0x22, 0x81, 0x00, 0x00, 0x33})
+
+				tests := []struct {
+					frame      string
+					name       string
+					line, col  int
+					errContain string
+				}{
+					{"Err0:=Ether()/IPv4(src=1.1.1.1, dst=)", "Err0", 1, 36, "needs a value"},
+					{"Err1:=Ether()/\n  IPv4(foo=1)", "Err1", 2, 3, "unknown ipv4 option"},
+					{"Err2:=Ether()/Payload(string='abc)", "Err2", 1, 30, "unterminated"},
+					{"Err3:=Ether()/IPv4(src=1.1.1.1", "Err3", 1, 19, "missing closing bracket"},
+					{"Err4:=Ether()/QinQ(Dot1q{vlan=1)", "Err4", 1, 32, "unexpected ')'"},
+					{"Err5:=Ether()/Foo()", "Err5", 1, 15, "unknown layer type"},
+					{"Err6:=Ether() IPv4()", "Err6", 1, 15, "expected '/'"},
+					{"Err7:=Ether()/Payload(string='\\q')", "Err7", 1, 30, "invalid escape"},
+					{"Err8:=Ether()/Payload(string=a=b)", "Err8", 1, 31, "more than one '='"},
+					{"Ether()/IPv4()", "", 1, 15, "missing ':='"},
+				}
+				for _, tt := range tests {
+					err := fg.StringToBinary(tt.frame)
+					var pe *ParseError
+					g.Assert(errors.As(err, &pe)).IsTrue(fmt.Sprintf("%s: not a parse error: %v", tt.frame, err))
+					g.Assert(pe.Frame).Equal(tt.name)
+					g.Assert([]int{pe.Line, pe.Column}).Equal([]int{tt.line, tt.col}, err.Error())
+					g.Assert(strings.Contains(err.Error(), tt.errContain)).IsTrue(err.Error())
+				}
+			}
+		})
+
+		g.It("ToBinary protocol inference", func() {
+			if fg, err := Create("Test 18", &FrameSerdeConfig{Defaults: []string{"DefInf:=Ether(proto=0x800)/IPv4()"}}); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Inf0:=Ether()/IPv6()/UDP()",
+					"Inf1:=Ether()/Dot1Q(vlan=5)/IPv4()/TCP()",
+					"Inf2:=Ether()/IPv4()/GRE()/IPv6()/UDP()",
+					"Inf3:=Ether(proto=0x800)/IPv6()/UDP()",
+					"Inf4:=Ether()/IPv4(protocol=6)/UDP()",
+					"Inf5:=Ether()/IPv6()/UDP()/Defaults(DefInf)",
+					"Inf6:=Ether(dst=01:00:5e:00:00:01)/MPLS(label=10)/IPv4()/UDP()",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				frame := func(name string) []byte {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					return fr.frame.Bytes()
+				}
+				warnings := func(name string) []string {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					return fr.Warnings()
+				}
+
+				b := frame("Inf0")
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeIPv6))
+				g.Assert(b[14+6]).Equal(byte(ProtocolUDP))
+
+				b = frame("Inf1")
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(EtherTypeIPv4))
+				g.Assert(b[18+9]).Equal(byte(ProtocolTCP))
+
+				b = frame("Inf2")
+				g.Assert(b[14+9]).Equal(byte(ProtocolGRE))
+				g.Assert(binary.BigEndian.Uint16(b[34+2:])).Equal(uint16(EtherTypeIPv6))
+				g.Assert(b[38+6]).Equal(byte(ProtocolUDP))
+
+				// The values given in the frame string are kept with a warning
+				b = frame("Inf3")
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeIPv4))
+				g.Assert(len(warnings("Inf3"))).Equal(1)
+				g.Assert(strings.Contains(warnings("Inf3")[0], "Ether proto=0x800 does not match the next layer IPv6")).IsTrue(warnings("Inf3")[0])
+
+				b = frame("Inf4")
+				g.Assert(b[14+9]).Equal(byte(ProtocolTCP))
+				g.Assert(len(warnings("Inf4"))).Equal(1)
+
+				// The following layer is used before the default frame values
+				b = frame("Inf5")
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeIPv6))
+				g.Assert(warnings("Inf5") == nil).IsTrue("unexpected warnings")
+
+				b = frame("Inf6")
+				g.Assert(binary.BigEndian.Uint16(b[12:])).Equal(uint16(EtherTypeMPLSMc))
+			}
+		})
+
+		g.It("ToBinary frame size and IMIX", func() {
+			if fg, err := Create("Test 19", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Size0:=Ether()/IPv4()/UDP()/Payload(framesize=64)",
+					"Size1:=Ether()/Dot1Q(vlan=1)/IPv6()/TCP()/Payload(framesize=1518, fill=0xaa)",
+					"Size2:=Ether()/IPv4()/UDP()/Payload(framesize=128, fcs=false)",
+					"Size3:=Ether()/IPv4()/UDP()/IMIX(simple)",
+					"Size4:=Ether()/IPv4()/UDP()/IMIX(100:1, 200:2)/Count(6)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Size0", NormalFrameType)
+				g.Assert(fr.frame.Len()).Equal(60)
+				g.Assert(binary.BigEndian.Uint16(fr.frame.Bytes()[16:])).Equal(uint16(60 - 14))
+				g.Assert(fr.GetLayer(LayerPayload).(*PayloadLayer).String()).Equal("Payload(framesize=64)")
+
+				fr, _ = fg.GetFrame("Size1", NormalFrameType)
+				g.Assert(fr.frame.Len()).Equal(1514)
+				g.Assert(binary.BigEndian.Uint16(fr.frame.Bytes()[18+4:])).Equal(uint16(1514 - 18 - 40))
+
+				fr, _ = fg.GetFrame("Size2", NormalFrameType)
+				g.Assert(fr.frame.Len()).Equal(128)
+
+				fr, _ = fg.GetFrame("Size3", NormalFrameType)
+				g.Assert(len(fr.Variants())).Equal(12)
+				g.Assert(fr.GetLayer(LayerCount).(*CountLayer).count).Equal(uint32(12))
+				sizes := map[int]int{}
+				for _, b := range fr.Variants() {
+					sizes[len(b)]++
+					g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(len(b) - 14))
+					g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(len(b) - 34))
+				}
+				g.Assert(sizes).Equal(map[int]int{60: 7, 590: 4, 1514: 1})
+				g.Assert(len(fr.Variants()[0])).Equal(60)
+
+				fr, _ = fg.GetFrame("Size4", NormalFrameType)
+				lens := []int{}
+				for _, b := range fr.Variants() {
+					lens = append(lens, len(b))
+				}
+				g.Assert(lens).Equal([]int{196, 96, 196, 196, 96, 196})
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4()/UDP()/Payload(framesize=40)",
+					"Bad1:=Ether()/IPv4()/UDP()/IMIX(64:0)",
+					"Bad2:=Ether()/IPv4()/UDP()/IMIX(large)",
+					"Bad3:=Ether()/IPv4()/UDP()/IMIX(simple, 64:1)",
+					"Bad4:=Ether()/IPv4()/UDP()/IMIX()",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary payload generators", func() {
+			if fg, err := Create("Test 20", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				dir, err := os.MkdirTemp("", "fserde")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("MkdirTemp failed: %v", err))
+				defer os.RemoveAll(dir)
+
+				blob := filepath.Join(dir, "blob.bin")
+				g.Assert(os.WriteFile(blob, []byte("0123456789abcdef"), 0644) == nil).IsTrue("WriteFile failed")
+				g.Assert(os.Mkdir(filepath.Join(dir, "sub"), 0755) == nil).IsTrue("Mkdir failed")
+
+				err = fg.StringsToBinary([]string{
+					"Gen0:=Ether()/IPv4()/UDP()/Payload(size=64, random, seed=7)",
+					"Gen1:=Ether()/IPv4()/UDP()/Payload(size=64, seed=7)",
+					"Gen2:=Ether()/IPv4()/UDP()/Payload(size=64, random, seed=8)",
+					"Gen3:=Ether()/IPv4()/UDP()/Payload(size=300, inc8)",
+					"Gen4:=Ether()/IPv4()/UDP()/Payload(size=4, inc8=0xfe)",
+					"Gen5:=Ether()/IPv4()/UDP()/Payload(hex='0xde:ad be:ef')",
+					fmt.Sprintf("Gen6:=Ether()/IPv4()/UDP()/Payload(file=%s, offset=2, len=4)", quoteString(blob)),
+					fmt.Sprintf("Gen9:=Ether()/IPv4()/UDP()/Payload(file=%s/sub/../blob.bin, len=4)", dir),
+					fmt.Sprintf("Gen7:=Ether()/IPv4()/UDP()/Payload(file=%s, offset=10)", quoteString(blob)),
+					"Gen8:=Ether()/IPv4()/UDP()/Payload(framesize=128, random, seed=1)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				payload := func(name string) []byte {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					return fr.frame.Bytes()[42:]
+				}
+
+				g.Assert(len(payload("Gen0"))).Equal(64)
+				g.Assert(payload("Gen0")).Equal(payload("Gen1"))
+				g.Assert(bytes.Equal(payload("Gen0"), payload("Gen2"))).IsFalse("seeds 7 and 8 give the same data")
+				g.Assert(isPayloadFill(payload("Gen0"), 1)).IsFalse("random payload is a fill")
+
+				fr, _ := fg.GetFrame("Gen0", NormalFrameType)
+				g.Assert(fr.GetLayer(LayerPayload).(*PayloadLayer).String()).Equal("Payload(size=64, random, seed=7)")
+
+				b := payload("Gen3")
+				g.Assert(len(b)).Equal(300)
+				for i := range b {
+					g.Assert(b[i]).Equal(byte(i))
+				}
+				g.Assert(payload("Gen4")).Equal([]byte{0xfe, 0xff, 0x00, 0x01})
+				g.Assert(payload("Gen5")).Equal([]byte{0xde, 0xad, 0xbe, 0xef})
+				g.Assert(payload("Gen6")).Equal([]byte("2345"))
+				g.Assert(payload("Gen7")).Equal([]byte("abcdef"))
+				g.Assert(payload("Gen9")).Equal([]byte("0123"))
+
+				fr, _ = fg.GetFrame("Gen6", NormalFrameType)
+				g.Assert(fr.GetLayer(LayerPayload).(*PayloadLayer).String()).Equal(
+					fmt.Sprintf("Payload(size=4, file=%s, offset=2)", quoteString(blob)))
+
+				fr, _ = fg.GetFrame("Gen8", NormalFrameType)
+				g.Assert(fr.frame.Len()).Equal(124)
+
+				// The incrementing payload is decoded as inc8
+				fr, _ = fg.GetFrame("Gen3", NormalFrameType)
+				str, err := fg.BinaryToString("Dec3", fr.frame.Bytes())
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "Payload(size=300, inc8=0x0)")).IsTrue(str)
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4()/UDP()/Payload(size=8, seed=x)",
+					"Bad1:=Ether()/IPv4()/UDP()/Payload(size=8, inc8=256)",
+					"Bad2:=Ether()/IPv4()/UDP()/Payload(hex='xyz')",
+					fmt.Sprintf("Bad3:=Ether()/IPv4()/UDP()/Payload(file=%s)", quoteString(filepath.Join(dir, "none.bin"))),
+					fmt.Sprintf("Bad4:=Ether()/IPv4()/UDP()/Payload(file=%s, offset=17)", quoteString(blob)),
+					fmt.Sprintf("Bad5:=Ether()/IPv4()/UDP()/Payload(file=%s, offset=8, len=9)", quoteString(blob)),
+					"Bad6:=Ether()/IPv4()/UDP()/Payload(size=8, offset=2)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary malformed frames", func() {
+			if fg, err := Create("Test 21", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Mal0:=Ether()/IPv4(checksum=bad)/UDP()/Payload(size=10)",
+					"Mal1:=Ether()/IPv4(checksum=0x1234, totallen=1000)/UDP()/Payload(size=10)",
+					"Mal2:=Ether()/IPv4()/UDP(len=100, checksum=bad)/Payload(size=10)",
+					"Mal3:=Ether()/IPv4()/UDP(checksum=true)/Payload(size=10)",
+					"Mal4:=Ether()/IPv4()/TCP(checksum=0xbeef)/Payload(size=10)",
+					"Mal5:=Ether(fcs=bad)/IPv4()/UDP()/Payload(size=10)",
+					"Mal6:=Ether(fcs=0x01020304)/IPv4()/UDP()/Payload(size=10)",
+					"Mal7:=Ether()/IPv4()/UDP()/Payload(size=100)/Truncate(60)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				frame := func(name string) []byte {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					return fr.frame.Bytes()
+				}
+
+				b := frame("Mal0")
+				g.Assert(^reduceChecksum(dataChecksum(b[14:34], IPv4MinLen)) != 0).IsTrue("ipv4 checksum is valid")
+				fr, _ := fg.GetFrame("Mal0", NormalFrameType)
+				g.Assert(strings.HasSuffix(fr.GetLayer(LayerIPv4).(*IPv4Layer).String(), ", checksum=bad)")).IsTrue("no checksum=bad")
+
+				b = frame("Mal1")
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(1000))
+				g.Assert(binary.BigEndian.Uint16(b[24:])).Equal(uint16(0x1234))
+
+				b = frame("Mal2")
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(38))
+				g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(100))
+				g.Assert(binary.BigEndian.Uint16(b[40:]) != binary.BigEndian.Uint16(frame("Mal3")[40:])).IsTrue("udp checksum is valid")
+				g.Assert(binary.BigEndian.Uint16(b[40:]) != 0).IsTrue("udp checksum is zero")
+
+				g.Assert(binary.BigEndian.Uint16(frame("Mal4")[50:])).Equal(uint16(0xbeef))
+
+				b = frame("Mal5")
+				g.Assert(len(b)).Equal(MinPacketLen + FCSLen)
+				g.Assert(b[52:MinPacketLen]).Equal(make([]byte, MinPacketLen-52))
+				g.Assert(binary.LittleEndian.Uint32(b[MinPacketLen:])).Equal(^crc32.ChecksumIEEE(b[:MinPacketLen]))
+
+				g.Assert(frame("Mal6")[MinPacketLen:]).Equal([]byte{0x04, 0x03, 0x02, 0x01})
+
+				b = frame("Mal7")
+				g.Assert(len(b)).Equal(60)
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(128))
+
+				// The decoded frame string computes the UDP length after the payload is changed
+				str, err := fg.BinaryToString("Dec0", frame("Mal3"))
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "length=")).IsFalse(str)
+				str = strings.Replace(strings.Replace(str, "Dec0", "Dec1", 1), "size=10", "size=100", 1)
+				g.Assert(fg.StringToBinary(str) == nil).IsTrue(str)
+				b = frame("Dec1")
+				g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(UDPHeaderLen + 100))
+				fr, _ = fg.GetFrame("Mal2", NormalFrameType)
+				g.Assert(strings.Contains(fr.String(), "length=100")).IsTrue(fr.String())
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4(checksum=xyz)/UDP()",
+					"Bad1:=Ether()/IPv4()/TCP(checksum=0x10000)",
+					"Bad2:=Ether(fcs=good)/IPv4()/UDP()",
+					"Bad3:=Ether()/IPv4()/UDP()/VxLan()/Ether(fcs=bad)/IPv4()",
+					"Bad4:=Ether()/IPv4()/UDP()/Truncate()",
+					"Bad5:=Ether()/IPv4()/UDP()/Truncate(0)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary IPv4 options and fragments", func() {
+			if fg, err := Create("Test 22", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Opt0:=Ether()/IPv4(opts=[ra, rr{slots=2}])/UDP()/Payload(size=8)",
+					"Opt1:=Ether()/IPv4(len=44, opts=[nop, ts{slots=2, flag=1}])/TCP()",
+					"Frag0:=Ether()/IPv4(id=7, opts=[ra, rr{slots=1}])/UDP()/Payload(size=3000)/Fragment(mtu=1500)",
+					"Frag1:=Ether()/IPv4()/UDP()/Payload(size=100)/Fragment(mtu=1500)",
+					"Frag2:=Ether(fcs=bad)/IPv4(flags=2)/UDP()/Payload(size=100)/Fragment(mtu=68)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Opt0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[14]).Equal(byte(0x49))
+				g.Assert(b[34:50]).Equal([]byte{0x94, 0x04, 0x00, 0x00, 0x07, 0x0b, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(36 + 16))
+				g.Assert(binary.BigEndian.Uint16(b[54:])).Equal(uint16(16))
+				g.Assert(^reduceChecksum(dataChecksum(b[14:50], 36))).Equal(uint16(0))
+				g.Assert(strings.Contains(fr.String(), "opts=[ra, rr{slots=2}]")).IsTrue(fr.String())
+
+				str, err := fg.BinaryToString("Dec0", b)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "IPv4(ver=4, len=36,")).IsTrue(str)
+				g.Assert(strings.Contains(str, "opts=[ra, rr{slots=2}]")).IsTrue(str)
+
+				fr, _ = fg.GetFrame("Opt1", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[14]).Equal(byte(0x4b))
+				g.Assert(b[34:39]).Equal([]byte{0x01, 0x44, 0x14, 0x05, 0x01})
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(44 + 20))
+
+				fr, _ = fg.GetFrame("Frag0", NormalFrameType)
+				whole := fr.frame.Bytes()
+				frags := fr.Variants()
+				g.Assert(len(frags)).Equal(3)
+
+				data := []byte{}
+				for i, f := range frags {
+					hl := int(f[14]&0x0F) << 2
+					g.Assert(^reduceChecksum(dataChecksum(f[14:14+hl], hl))).Equal(uint16(0))
+					g.Assert(binary.BigEndian.Uint16(f[16:])).Equal(uint16(len(f) - 14))
+					g.Assert(binary.BigEndian.Uint16(f[18:])).Equal(uint16(7))
+					flags := binary.BigEndian.Uint16(f[20:])
+					g.Assert(int(flags&0x1FFF) * 8).Equal(len(data))
+					g.Assert(flags&0x2000 != 0).Equal(i < len(frags)-1)
+					g.Assert(len(f)-14 <= 1500).IsTrue("fragment larger than the mtu")
+					if i == 0 {
+						g.Assert(hl).Equal(32)
+					} else {
+						g.Assert(hl).Equal(24)
+						g.Assert(f[34:38]).Equal([]byte{0x94, 0x04, 0x00, 0x00})
+					}
+					data = append(data, f[14+hl:]...)
+				}
+				g.Assert(data).Equal(whole[14+32:])
+
+				fr, _ = fg.GetFrame("Frag1", NormalFrameType)
+				g.Assert(fr.Variants()).Equal([][]byte{fr.frame.Bytes()})
+
+				fr, _ = fg.GetFrame("Frag2", NormalFrameType)
+				g.Assert(len(fr.Variants())).Equal(3)
+				g.Assert(len(fr.Warnings())).Equal(1)
+				for _, f := range fr.Variants() {
+					// The short fragments are padded to the minimum frame length before the FCS
+					n := len(f) - FCSLen
+					tl := int(binary.BigEndian.Uint16(f[16:]))
+					g.Assert(n == tl+14 || (n == MinPacketLen && tl+14 < MinPacketLen)).IsTrue(fmt.Sprintf("fragment length %d", tl))
+					g.Assert(binary.LittleEndian.Uint32(f[n:])).Equal(^crc32.ChecksumIEEE(f[:n]))
+				}
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4(opts=[rr, ts])/UDP()",
+					"Bad1:=Ether()/IPv4(opts=[lsrr])/UDP()",
+					"Bad2:=Ether()/IPv4(len=20, opts=[ra])/UDP()",
+					"Bad3:=Ether()/IPv4(opts=[rr{slots=0}])/UDP()",
+					"Bad4:=Ether()/IPv4(opts=[ts{flag=2}])/UDP()",
+					"Bad5:=Ether()/IPv4(opts=ra)/UDP()",
+					"Bad6:=Ether()/IPv4()/UDP()/Payload(size=100)/Fragment()",
+					"Bad7:=Ether()/IPv4()/UDP()/Payload(size=100)/Fragment(mtu=24)",
+					"Bad8:=Ether()/IPv6()/UDP()/Payload(size=2000)/Fragment(mtu=1280)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary TCP options", func() {
+			if fg, err := Create("Test 23", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Syn0:=Ether()/IPv4()/TCP(flags=[syn], options=[mss=1460, sackok, ts=(100,0), nop, wscale=7])",
+					"Sack0:=Ether()/IPv4()/TCP(flags=[ack], options=[nop, nop, sack=[(1,2), (3,4)]])/Payload(size=10)",
+					"Pad0:=Ether()/IPv6()/TCP(options=[wscale=2])",
+					"Raw0:=Ether()/IPv4()/TCP(flags=[SYN], options='ABcd')",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Syn0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[46] >> 4).Equal(byte(10))
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(60))
+				g.Assert(b[54:74]).Equal([]byte{0x02, 0x04, 0x05, 0xb4, 0x04, 0x02, 0x08, 0x0a, 0, 0, 0, 100, 0, 0, 0, 0,
+					0x01, 0x03, 0x03, 0x07})
+				g.Assert(strings.HasSuffix(fr.GetLayer(LayerTCP).(*TCPLayer).String(),
+					", options=[mss=1460, sackok, ts=(100,0), nop, wscale=7])")).IsTrue("options not in the string")
+
+				str, err := fg.BinaryToString("Dec0", b)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "options=[mss=1460, sackok, ts=(100,0), nop, wscale=7]")).IsTrue(str)
+
+				fr, _ = fg.GetFrame("Sack0", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[46] >> 4).Equal(byte(10))
+				g.Assert(b[54:74]).Equal([]byte{0x01, 0x01, 0x05, 0x12, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 4})
+				g.Assert(len(b)).Equal(84)
+
+				str, err = fg.BinaryToString("Dec1", b)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "options=[nop, nop, sack=[(1,2), (3,4)]]")).IsTrue(str)
+
+				fr, _ = fg.GetFrame("Pad0", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[66] >> 4).Equal(byte(6))
+				g.Assert(b[74:78]).Equal([]byte{0x03, 0x03, 0x02, 0x00})
+				g.Assert(binary.BigEndian.Uint16(b[18:])).Equal(uint16(24))
+
+				// The quoted raw options are written as given
+				fr, _ = fg.GetFrame("Raw0", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(string(b[54:58])).Equal("ABcd")
+				g.Assert(b[47] & 0x02).Equal(byte(0x02))
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4()/TCP(options=[mss=70000])",
+					"Bad1:=Ether()/IPv4()/TCP(options=[sack=[]])",
+					"Bad2:=Ether()/IPv4()/TCP(options=[sack=[(1,2), (3,4), (5,6), (7,8), (9,10)]])",
+					"Bad3:=Ether()/IPv4()/TCP(options=[ts=(1)])",
+					"Bad4:=Ether()/IPv4()/TCP(options=[md5])",
+					"Bad5:=Ether()/IPv4()/TCP(options=[sack=[(1,2), (3,4), (5,6), (7,8)], ts=(1,2)])",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary Vlan tags", func() {
+			if fg, err := Create("Test 24", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Vlan0:=Ether()/Vlan(vid=100)/Vlan(vid=200)/Vlan(vid=300, pcp=5)/IPv4()/UDP()",
+					"Vlan1:=Ether()/Dot1AD(vid=10)/Dot1Q(vid=20)/IPv6()/UDP()",
+					"Vlan2:=Ether()/Dot1Q(vid=20)/Dot1AD(vid=10)/IPv4()/UDP()",
+					"Vlan3:=Ether()/Vlan(tpid=0x9100, vid=1)/Vlan(vid=2)/IPv4()/UDP()",
+					"Vlan4:=Ether()/Vlan(vid=7)/IPv4()/UDP()",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Vlan0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[12:26]).Equal([]byte{0x88, 0xa8, 0x00, 0x64, 0x88, 0xa8, 0x00, 0xc8, 0x81, 0x00, 0xa1, 0x2c, 0x08, 0x00})
+				g.Assert(fr.GetOffset(LayerIPv4)).Equal(uint16(26))
+				g.Assert(len(fr.Warnings())).Equal(0)
+
+				str, err := fg.BinaryToString("Dec0", b)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				g.Assert(strings.Contains(str, "/Vlan(tpid=0x88a8, pcp=0, dei=0, vid=100)/Vlan(tpid=0x88a8, pcp=0, dei=0, vid=200)/"+
+					"Vlan(tpid=0x8100, pcp=5, dei=0, vid=300)/IPv4(")).IsTrue(str)
+				df, _ := fg.GetFrame("Dec0", NormalFrameType)
+				g.Assert(df.LayerCount(LayerVlan)).Equal(3)
+
+				fr, _ = fg.GetFrame("Vlan1", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[12:22]).Equal([]byte{0x88, 0xa8, 0x00, 0x0a, 0x81, 0x00, 0x00, 0x14, 0x86, 0xdd})
+				_, ok := fr.GetLayer(LayerDot1Q).(*VlanLayer)
+				g.Assert(ok).IsTrue("Dot1Q is not a Vlan layer")
+				g.Assert(len(fr.Warnings())).Equal(0)
+
+				fr, _ = fg.GetFrame("Vlan2", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[12:22]).Equal([]byte{0x81, 0x00, 0x00, 0x14, 0x88, 0xa8, 0x00, 0x0a, 0x08, 0x00})
+				g.Assert(len(fr.Warnings())).Equal(1)
+
+				fr, _ = fg.GetFrame("Vlan3", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[12:22]).Equal([]byte{0x91, 0x00, 0x00, 0x01, 0x81, 0x00, 0x00, 0x02, 0x08, 0x00})
+
+				fr, _ = fg.GetFrame("Vlan4", NormalFrameType)
+				g.Assert(fr.frame.Bytes()[12:18]).Equal([]byte{0x81, 0x00, 0x00, 0x07, 0x08, 0x00})
+				g.Assert(fr.GetLayer(LayerVlan).(*VlanLayer).String()).Equal("Vlan(tpid=0x8100, pcp=0, dei=0, vid=7)")
+
+				g.Assert(fg.StringToBinary("Bad0:=Ether()/Vlan(vid=1, foo=2)/IPv4()") != nil).IsTrue("unknown option should fail")
+			}
+		})
+
+		g.It("ToBinary frame variables", func() {
+			cfg := &FrameSerdeConfig{
+				Defaults: []string{"DefVars:=Ether(src=${PORT0_MAC})/IPv4()"},
+				Vars:     map[string]string{"BASE_IP": "10.1.0.254", "DPORT": "0x1000", "TTL": "17"},
+				PortVars: []map[string]string{{"MAC": "00:11:22:33:44:55"}, {"MAC": "00:11:22:33:44:ff"}},
+			}
+			if fg, err := Create("Test 25", cfg); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringToBinary("Vars0:=Ether(dst=${PORT1_MAC}+1)/IPv4(src=${BASE_IP}, dst=${BASE_IP}+3, ttl=${TTL})/\n" +
+					"UDP(sport=${DPORT}, dport=${DPORT} - 1)/Defaults(DefVars)")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Vars0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[0:12]).Equal([]byte{0x00, 0x11, 0x22, 0x33, 0x45, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55})
+				g.Assert(b[22]).Equal(byte(17))
+				g.Assert(b[26:34]).Equal([]byte{10, 1, 0, 254, 10, 1, 1, 1})
+				g.Assert(b[34:38]).Equal([]byte{0x10, 0x00, 0x0f, 0xff})
+
+				err = fg.StringToBinary("Vars1:=Ether()/\n  IPv4(dst=${DUT_IP})/UDP()")
+				var pe *ParseError
+				g.Assert(errors.As(err, &pe)).IsTrue(fmt.Sprintf("expected a ParseError: %v", err))
+				g.Assert(pe.Frame).Equal("Vars1")
+				g.Assert(pe.Line).Equal(2)
+				g.Assert(pe.Column).Equal(12)
+				g.Assert(strings.Contains(err.Error(), "undefined variable ${DUT_IP}")).IsTrue(err.Error())
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4(dst=${BASE_IP)/UDP()",
+					"Bad1:=Ether()/IPv4(dst=${})/UDP()",
+					"Bad2:=Ether()/IPv4(dst=${BASE_IP}+2)/UDP(sport=${TTL}-18)",
+					"Bad3:=Ether(dst=ff:ff:ff:ff:ff:ff)/IPv4(dst=${PORT2_MAC})/UDP()",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+
+			_, err := Create("Test 25", &FrameSerdeConfig{Vars: map[string]string{"PORT0_MAC": "00:01:02:03:04:05"},
+				PortVars: []map[string]string{{"MAC": "00:11:22:33:44:55"}}})
+			g.Assert(err != nil).IsTrue("duplicate port variable should fail")
+			_, err = Create("Test 25", &FrameSerdeConfig{Vars: map[string]string{"BAD-NAME": "1"}})
+			g.Assert(err != nil).IsTrue("invalid variable name should fail")
+		})
+
+		g.It("ToBinary Raw and Patch layers", func() {
+			if fg, err := Create("Test 26", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Raw0:=Ether()/IPv4()/UDP(dport=7777)/Raw(hex='01:02 03 04')/Payload(size=4, fill=0xaa)",
+					"Patch0:=Ether()/IPv4(src=10.0.0.1, dst=10.0.0.2)/UDP(sport=1000, dport=2000, checksum=true)/" +
+						"Payload(size=8, fill=0xaa)/Patch(offset=26, hex='0a000009', recompute=true)",
+					"Want0:=Ether()/IPv4(src=10.0.0.9, dst=10.0.0.2)/UDP(sport=1000, dport=2000, checksum=true)/" +
+						"Payload(size=8, fill=0xaa)",
+					"Patch1:=Ether()/IPv4(src=10.0.0.1, dst=10.0.0.2)/UDP(sport=1000, dport=2000, checksum=true)/" +
+						"Payload(size=8, fill=0xaa)/Patch(offset=26, hex='0a000009')",
+					"Patch2:=Ether()/IPv6(src=2001::1, dst=2001::2)/TCP(sport=1, dport=2)/Payload(size=8)/" +
+						"Patch(offset=74, hex=0xbeef)/Patch(offset=76, hex=cafe, recompute=true)",
+					"Want2:=Ether()/IPv6(src=2001::1, dst=2001::2)/TCP(sport=1, dport=2)/Payload(hex=0xbeefcafe00000000)",
+					"Patch3:=Ether()/IPv4()/GRE(checksum=true)/IPv4(src=10.0.0.1, dst=10.0.0.2)/UDP(checksum=true)/" +
+						"Payload(size=8)/Patch(offset=54, hex='0a000009', recompute=true)",
+					"Want3:=Ether()/IPv4()/GRE(checksum=true)/IPv4(src=10.0.0.9, dst=10.0.0.2)/UDP(checksum=true)/Payload(size=8)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Raw0", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(b[42:50]).Equal([]byte{0x01, 0x02, 0x03, 0x04, 0xaa, 0xaa, 0xaa, 0xaa})
+				g.Assert(binary.BigEndian.Uint16(b[38:])).Equal(uint16(16))
+				g.Assert(fr.GetOffset(LayerRaw)).Equal(uint16(42))
+				g.Assert(strings.Contains(fr.String(), "/Raw(hex=01020304)/")).IsTrue(fr.String())
+
+				fr, _ = fg.GetFrame("Patch0", NormalFrameType)
+				want, _ := fg.GetFrame("Want0", NormalFrameType)
+				g.Assert(fr.frame.Bytes()).Equal(want.frame.Bytes())
+
+				fr, _ = fg.GetFrame("Patch1", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(b[26:30]).Equal([]byte{10, 0, 0, 9})
+				g.Assert(bytes.Equal(b[24:26], want.frame.Bytes()[24:26])).IsFalse("checksum should not be recomputed")
+				g.Assert(strings.Contains(fr.String(), "/Patch(offset=26, hex=0a000009)")).IsTrue(fr.String())
+
+				fr, _ = fg.GetFrame("Patch2", NormalFrameType)
+				want, _ = fg.GetFrame("Want2", NormalFrameType)
+				g.Assert(fr.frame.Bytes()).Equal(want.frame.Bytes())
+
+				// The GRE checksum covers the patched inner packet
+				fr, _ = fg.GetFrame("Patch3", NormalFrameType)
+				want, _ = fg.GetFrame("Want3", NormalFrameType)
+				g.Assert(fr.frame.Bytes()).Equal(want.frame.Bytes())
+
+				for _, bad := range []string{
+					"Bad0:=Ether()/IPv4()/Raw()",
+					"Bad1:=Ether()/IPv4()/Raw(hex=0xzz)",
+					"Bad2:=Ether()/IPv4()/Patch(hex=00)",
+					"Bad3:=Ether()/IPv4()/Patch(offset=60, hex=0000)",
+					"Bad4:=Ether()/IPv4()/Patch(offset=0, hex=00, recompute=maybe)",
+				} {
+					g.Assert(fg.StringToBinary(bad) != nil).IsTrue(fmt.Sprintf("%s should fail", bad))
+				}
+			}
+		})
+
+		g.It("ToBinary FCS and jumbo frames", func() {
+			var jumbo []byte
+
+			if fg, err := Create("Test 27", &FrameSerdeConfig{MTU: JumboMTU}); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Jumbo0:=Ether(fcs=true)/IPv4()/UDP()/Payload(framesize=9234)",
+					"Jumbo1:=Ether(fcs=true)/Vlan(vid=5)/IPv4()/UDP()/Payload(framesize=9238)",
+					"Fcs0:=Ether(fcs=true)/IPv4()/UDP()/Payload(size=4)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				fr, _ := fg.GetFrame("Jumbo0", NormalFrameType)
+				b := fr.frame.Bytes()
+				n := len(b) - FCSLen
+				g.Assert(len(b)).Equal(9234)
+				g.Assert(binary.BigEndian.Uint16(b[16:])).Equal(uint16(JumboMTU))
+				g.Assert(binary.LittleEndian.Uint32(b[n:])).Equal(crc32.ChecksumIEEE(b[:n]))
+				g.Assert(strings.Contains(fr.String(), "fcs=true)")).IsTrue(fr.String())
+
+				fr, _ = fg.GetFrame("Jumbo1", NormalFrameType)
+				g.Assert(len(fr.frame.Bytes())).Equal(9238)
+
+				fr, _ = fg.GetFrame("Fcs0", NormalFrameType)
+				b = fr.frame.Bytes()
+				g.Assert(len(b)).Equal(MinPacketLen + FCSLen)
+				g.Assert(binary.LittleEndian.Uint32(b[MinPacketLen:])).Equal(crc32.ChecksumIEEE(b[:MinPacketLen]))
+
+				dir, err := os.MkdirTemp("", "fserde")
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("MkdirTemp failed: %v", err))
+				defer os.RemoveAll(dir)
+
+				path := filepath.Join(dir, "fcs.pcap")
+				g.Assert(fg.WritePCAP(path, NormalFrameType) == nil).IsTrue("WritePCAP failed")
+				data, err := os.ReadFile(path)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("ReadFile failed: %v", err))
+				g.Assert(binary.LittleEndian.Uint32(data[16:])).Equal(uint32(9238))
+				g.Assert(binary.LittleEndian.Uint32(data[20:])).Equal(uint32(1 | 1<<28 | (FCSLen/2)<<29))
+				g.Assert(binary.LittleEndian.Uint32(data[24+8:])).Equal(uint32(9234))
+
+				g.Assert(fg.StringToBinary("Fcs1:=Ether(fcs=false)/IPv4()/UDP()") == nil).IsTrue("fcs=false failed")
+				fr, _ = fg.GetFrame("Fcs1", NormalFrameType)
+				g.Assert(len(fr.frame.Bytes())).Equal(42)
+				g.Assert(fg.WritePCAP(path, NormalFrameType) != nil).IsTrue("mixed FCS frames should fail")
+
+				g.Assert(fg.StringToBinary("Big0:=Ether()/IPv4()/UDP()/Payload(framesize=9240)") != nil).IsTrue("frame larger than the mtu should fail")
+
+				g.Assert(fg.StringToBinary("Jumbo2:=Ether()/IPv4()/UDP()/Payload(size=8000)") == nil).IsTrue("jumbo frame failed")
+				fr, _ = fg.GetFrame("Jumbo2", NormalFrameType)
+				jumbo = fr.frame.Bytes()
+				_, err = fg.BinaryToString("Dec0", jumbo)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+			}
+
+			if fg, err := Create("Test 27", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				g.Assert(fg.StringToBinary("Max0:=Ether()/Dot1Q()/IPv4()/UDP()/Payload(size=1472)") == nil).IsTrue("1500 byte mtu failed")
+				g.Assert(fg.StringToBinary("Big0:=Ether()/IPv4()/UDP()/Payload(size=1473)") != nil).IsTrue("frame larger than the mtu should fail")
+				g.Assert(fg.StringToBinary("Frag0:=Ether()/IPv4()/UDP()/Payload(size=3000)/Fragment(mtu=1500)") == nil).IsTrue("fragments failed")
+				g.Assert(fg.StringToBinary("Trunc0:=Ether(fcs=true)/IPv4()/UDP()/Payload(size=100)/Truncate(60)") != nil).IsTrue("fcs with truncate should fail")
+
+				// The values 1 and 0 are true and false as for the other boolean options
+				g.Assert(fg.StringsToBinary([]string{"Fcs2:=Ether(fcs=1)/IPv4()/UDP()", "Fcs3:=Ether(fcs=0)/IPv4()/UDP()"}) == nil).IsTrue("fcs=1 failed")
+				fr, _ := fg.GetFrame("Fcs2", NormalFrameType)
+				b := fr.frame.Bytes()
+				g.Assert(binary.LittleEndian.Uint32(b[MinPacketLen:])).Equal(crc32.ChecksumIEEE(b[:MinPacketLen]))
+				fr, _ = fg.GetFrame("Fcs3", NormalFrameType)
+				g.Assert(len(fr.frame.Bytes())).Equal(42)
+
+				_, err := fg.BinaryToString("Dec0", jumbo)
+				g.Assert(err != nil).IsTrue("decoding a frame larger than the mtu should fail")
+			}
+
+			_, err := Create("Test 27", &FrameSerdeConfig{MTU: 10})
+			g.Assert(err != nil).IsTrue("invalid mtu should fail")
+		})
+
+		g.It("ToBinary frame templates", func() {
+			if fg, err := Create("Test 28", nil); err != nil {
+				g.Errorf("create failed: %s", err)
+			} else {
+				defer fg.Destroy()
+
+				err := fg.StringsToBinary([]string{
+					"Tmpl0:=Ether(fcs=true)/IPv4(src=10.0.0.1, dst=10.0.0.2)/UDP(sport=1000, dport=2000, checksum=true)/" +
+						"TSC(stream=1)/Payload(size=15, fill=0x5a)",
+					"Want0:=Ether(fcs=true)/IPv4(src=10.0.0.9, dst=10.0.0.2, ttl=3)/UDP(sport=4321, dport=2000, checksum=true)/" +
+						"TSC(stream=1, seq=77, ts=0x0102030405060708)/Payload(size=15, fill=0x5a)",
+					"Tmpl1:=Ether()/IPv6(src=2001::1, dst=2001::2)/TCP(sport=1, dport=2, seq=100)/Payload(size=8)",
+					"Want1:=Ether()/IPv6(src=2001::99, dst=2001::2)/TCP(sport=1, dport=2, seq=0x01020304, window=512)/Payload(size=8)",
+					"Tmpl2:=Ether()/IPv4(src=10.0.0.1, dst=10.0.0.2)/GRE(checksum=true)/IPv4(src=192.168.0.1, dst=192.168.0.2)/" +
+						"UDP(sport=7, checksum=true)/Payload(size=9)",
+					"Want2:=Ether()/IPv4(src=10.0.0.1, dst=10.0.0.2)/GRE(checksum=true)/IPv4(src=192.168.7.7, dst=192.168.0.2)/" +
+						"UDP(sport=7, checksum=true)/Payload(size=9)",
+					"Tmpl3:=Ether()/IPv4()/UDP()/Payload(size=8)/Fragment(mtu=1500)",
+					"Tmpl4:=Ether()/IPv4()/UDP(checksum=true)/VxLan(vni=5)/Ether()/IPv4(src=192.168.0.1)/UDP(sport=7)/Payload(size=9)",
+					"Want4:=Ether()/IPv4()/UDP(checksum=true)/VxLan(vni=5)/Ether()/IPv4(src=192.168.7.7)/UDP(sport=7)/Payload(size=9)",
+				})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+				frame := func(name string) *Frame {
+					fr, _ := fg.GetFrame(name, NormalFrameType)
+					return fr
+				}
+				field := func(t *Template, name string) int {
+					i, ok := t.Field(name)
+					g.Assert(ok).IsTrue(fmt.Sprintf("missing field %s", name))
+					return i
+				}
+
+				t0, err := frame("Tmpl0").Compile()
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Compile failed: %v", err))
+				g.Assert(t0.Len()).Equal(len(frame("Want0").frame.Bytes()))
+
+				seq, _ := frame("Tmpl0").GetProtocol(LayerTSC).FieldOffset(TSCFieldSeq)
+				g.Assert(t0.Fields()[field(t0, "TSC.seq")]).Equal(TemplateField{Name: "TSC.seq", Offset: seq, Width: 4})
+
+				dst := make([]byte, 2048)
+				ovs := []Override{
+					{Field: field(t0, "IPv4.src"), Bytes: []byte{10, 0, 0, 9}},
+					{Field: field(t0, "IPv4.ttl"), Value: 3},
+					{Field: field(t0, "UDP.sport"), Value: 4321},
+					{Field: field(t0, "TSC.seq"), Value: 77},
+					{Field: field(t0, "TSC.timestamp"), Value: 0x0102030405060708},
+				}
+				n, err := t0.Render(dst, ovs...)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Render failed: %v", err))
+				g.Assert(dst[:n]).Equal(frame("Want0").frame.Bytes())
+
+				g.Assert(testing.AllocsPerRun(100, func() { t0.Render(dst, ovs...) })).Equal(float64(0))
+
+				n, _ = t0.Render(dst)
+				g.Assert(dst[:n]).Equal(frame("Tmpl0").frame.Bytes())
+
+				t1, err := frame("Tmpl1").Compile()
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Compile failed: %v", err))
+				n, err = t1.Render(dst,
+					Override{Field: field(t1, "IPv6.src"), Bytes: net.ParseIP("2001::99").To16()},
+					Override{Field: field(t1, "TCP.seq"), Value: 0x01020304},
+					Override{Field: field(t1, "TCP.window"), Value: 512})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Render failed: %v", err))
+				g.Assert(dst[:n]).Equal(frame("Want1").frame.Bytes())
+
+				t2, err := frame("Tmpl2").Compile()
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Compile failed: %v", err))
+				n, err = t2.Render(dst, Override{Field: field(t2, "IPv4[1].src"), Value: 0xc0a80707})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Render failed: %v", err))
+				g.Assert(dst[:n]).Equal(frame("Want2").frame.Bytes())
+
+				_, err = t2.Render(dst, Override{Field: field(t2, "UDP.sport"), Value: 0x10000})
+				g.Assert(err != nil).IsTrue("value wider than the field should fail")
+				_, err = t2.Render(dst, Override{Field: field(t2, "IPv4.src"), Bytes: []byte{1, 2}})
+				g.Assert(err != nil).IsTrue("bytes not the field width should fail")
+				_, err = t2.Render(dst, Override{Field: -1})
+				g.Assert(err != nil).IsTrue("invalid field index should fail")
+				_, err = t2.Render(dst[:10])
+				g.Assert(err != nil).IsTrue("short buffer should fail")
+
+				_, err = frame("Tmpl3").Compile()
+				g.Assert(err != nil).IsTrue("fragmented frame should fail")
+
+				// The VXLAN flow entropy source port follows the inner frame headers
+				t4, err := frame("Tmpl4").Compile()
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Compile failed: %v", err))
+				n, err = t4.Render(dst, Override{Field: field(t4, "IPv4[1].src"), Value: 0xc0a80707})
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("Render failed: %v", err))
+				g.Assert(dst[:n]).Equal(frame("Want4").frame.Bytes())
+				g.Assert(bytes.Equal(dst[34:36], frame("Tmpl4").frame.Bytes()[34:36])).IsFalse("entropy source port not changed")
+
+				n, _ = t4.Render(dst, Override{Field: field(t4, "IPv4[1].src"), Value: 0xc0a80707}, Override{Field: field(t4, "UDP.sport"), Value: 4789})
+				g.Assert(binary.BigEndian.Uint16(dst[34:])).Equal(uint16(4789))
+			}
+		})
+
 		g.It("ToBinary Invalid frames", func() {
 			if fg, err := Create("Test 4", defs); err != nil {
 				g.Errorf("create failed: %s", err)
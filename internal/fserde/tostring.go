@@ -2,3 +2,516 @@
 // Copyright (c) 2023-2025 Intel Corporation
 
 package fserde
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+type ToStringConverter interface {
+	// Methods for ToString
+	Decode(data []byte) (int, error)
+}
+
+// toStringAddLayer decodes the layer from the data slice and adds the layer to the frame.
+// The number of bytes consumed by the layer is returned.
+func (fr *Frame) toStringAddLayer(name LayerName, layer ToStringConverter, data []byte) (int, error) {
+
+	n, err := layer.Decode(data)
+	if err != nil {
+		return 0, err
+	}
+
+	fr.addLayer(name, layer)
+	fr.layerInfo = append(fr.layerInfo, &LayerInfo{
+		Name:  name,
+		Type:  layerTypeFromName(string(name)),
+		Opts:  "",
+		Layer: layer,
+	})
+
+	return n, nil
+}
+
+// toStringVlan decodes the VLAN tags following the source MAC address and returns the
+// number of bytes consumed by the tags plus the EtherType following the tags.
+func (fr *Frame) toStringVlan(data []byte) (int, uint16, error) {
+
+	// The tags are decoded as Vlan() layers with the TPID of each tag
+	n := 0
+	for n+VlanTagLen+2 <= len(data) && isVlanTPID(binary.BigEndian.Uint16(data[n:])) {
+		m, err := fr.toStringAddLayer(LayerVlan, VlanNew(fr), data[n:])
+		if err != nil {
+			return 0, 0, err
+		}
+		n += m
+	}
+
+	return n, binary.BigEndian.Uint16(data[n:]), nil
+}
+
+// toStringMPLS decodes the MPLS label stack up to and including the label with the bottom
+// of stack bit set. The number of bytes consumed and the EtherType of the IPv4 or IPv6
+// packet following the label stack are returned, the EtherType is zero when the packet
+// following the label stack is not IPv4 or IPv6.
+func (fr *Frame) toStringMPLS(data []byte) (int, uint16, error) {
+
+	// The bottom of stack bit must be set in one of the labels
+	end := 0
+	for off := 0; off+MPLSHeaderLen <= len(data); off += MPLSHeaderLen {
+		if binary.BigEndian.Uint32(data[off:])&MPLSBoSFlag != 0 {
+			end = off + MPLSHeaderLen
+			break
+		}
+	}
+	if end == 0 {
+		return 0, 0, nil
+	}
+
+	for off := 0; off < end; off += MPLSHeaderLen {
+		if _, err := fr.toStringAddLayer(LayerMPLS, MPLSNew(fr), data[off:]); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if end < len(data) {
+		switch data[end] >> 4 {
+		case 4:
+			return end, EtherTypeIPv4, nil
+		case 6:
+			return end, EtherTypeIPv6, nil
+		}
+	}
+	return end, 0, nil
+}
+
+// toStringL3 decodes the L3 layer for the given EtherType. The number of bytes consumed,
+// the L4 protocol ID and the end of the L3 packet within the data slice are returned.
+// A zero length means the L3 layer is not supported and the data is left as payload.
+func (fr *Frame) toStringL3(etherType uint16, data []byte) (int, int, int, error) {
+
+	switch etherType {
+	case EtherTypeIPv4:
+		ip := IPv4New(fr)
+		if !ip.decodeValid(data) {
+			return 0, 0, len(data), nil
+		}
+		n, err := fr.toStringAddLayer(LayerIPv4, ip, data)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		// Fragments do not contain a full L4 header
+		if ip.ipHdr.FragOff != 0 || ip.ipHdr.Flags&ipv4.MoreFragments != 0 {
+			return n, 0, ip.ipHdr.TotalLen, nil
+		}
+		return n, ip.ipHdr.Protocol, ip.ipHdr.TotalLen, nil
+	case EtherTypeIPv6:
+		ip := IPv6New(fr)
+		if !ip.decodeValid(data) {
+			return 0, 0, len(data), nil
+		}
+		n, err := fr.toStringAddLayer(LayerIPv6, ip, data)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return n, ip.ip6Hdr.NextHeader, n + ip.ip6Hdr.PayloadLen, nil
+	case EtherTypeARP:
+		arp := ARPNew(fr)
+		if !arp.decodeValid(data) {
+			return 0, 0, len(data), nil
+		}
+		n, err := fr.toStringAddLayer(LayerARP, arp, data)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return n, 0, n, nil
+	}
+
+	return 0, 0, len(data), nil
+}
+
+// toStringSCTP decodes the SCTP common header and the chunks following the header. The
+// chunks are decoded until a chunk is not supported or is a DATA chunk, which must be the
+// last chunk. The number of bytes consumed and the DATA chunk padding are returned.
+func (fr *Frame) toStringSCTP(data []byte) (int, int, error) {
+
+	sctp := SCTPNew(fr)
+	if !sctp.decodeValid(data) {
+		return 0, 0, nil
+	}
+	off, err := fr.toStringAddLayer(LayerSCTP, sctp, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for off+SCTPChunkHeaderLen <= len(data) {
+		var n int
+
+		chunk := data[off:]
+		switch chunk[0] {
+		case SCTPChunkInit:
+			l := SCTPInitNew(fr)
+			if !l.decodeValid(chunk) {
+				return off, 0, nil
+			}
+			if n, err = fr.toStringAddLayer(LayerSCTPInit, l, chunk); err != nil {
+				return 0, 0, err
+			}
+		case SCTPChunkHeartbeat:
+			l := SCTPHeartbeatNew(fr)
+			if !l.decodeValid(chunk) {
+				return off, 0, nil
+			}
+			if n, err = fr.toStringAddLayer(LayerSCTPHeartbeat, l, chunk); err != nil {
+				return 0, 0, err
+			}
+		case SCTPChunkData:
+			l := SCTPDataNew(fr)
+			if !l.decodeValid(chunk) {
+				return off, 0, nil
+			}
+			if n, err = fr.toStringAddLayer(LayerSCTPData, l, chunk); err != nil {
+				return 0, 0, err
+			}
+			// The user data is left for the payload
+			return off + n, len(chunk) - int(l.length), nil
+		default:
+			return off, 0, nil
+		}
+		off += n
+	}
+
+	return off, 0, nil
+}
+
+// toStringTunnel decodes an IPv4 or IPv6 packet encapsulated in an IP packet, the inner
+// packet must fill the outer packet payload. The inner L4 layer is decoded and the number
+// of bytes consumed plus the number of bytes which are not part of the payload are returned.
+func (fr *Frame) toStringTunnel(protocol int, data []byte) (int, int, error) {
+
+	etherType := uint16(EtherTypeIPv4)
+	if protocol == ProtocolIPv6 {
+		etherType = EtherTypeIPv6
+	}
+
+	// The inner packet length is computed from the layers when encoded
+	if !l3LengthMatches(etherType, data) {
+		return 0, 0, nil
+	}
+
+	n, protocol, _, err := fr.toStringL3(etherType, data)
+	if err != nil || n == 0 {
+		return 0, 0, err
+	}
+
+	m, trailer, err := fr.toStringL4(protocol, data[n:])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return n + m, trailer, nil
+}
+
+// toStringVxLan decodes the VXLAN header and the inner frame, the number of bytes consumed
+// and the number of bytes at the end of the inner frame which are not part of the payload
+// are returned.
+func (fr *Frame) toStringVxLan(data []byte) (int, int, error) {
+
+	vx := VxLanNew(fr)
+	if !vx.decodeValid(data) {
+		return 0, 0, nil
+	}
+	n, err := fr.toStringAddLayer(LayerVxLan, vx, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	off, _, trailer, err := fr.toStringEther(data[n:], true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return n + off, trailer, nil
+}
+
+// toStringGRE decodes the GRE or NVGRE header and the encapsulated IPv4, IPv6 or Ether
+// layers, the number of bytes consumed and the number of bytes at the end of the data
+// which are not part of the payload are returned.
+func (fr *Frame) toStringGRE(data []byte) (int, int, error) {
+
+	var n int
+	var err error
+
+	protocol := uint16(0)
+	if nv := NVGRENew(fr); nv.decodeValid(data) {
+		if n, err = fr.toStringAddLayer(LayerNVGRE, nv, data); err != nil {
+			return 0, 0, err
+		}
+		protocol = EtherTypeTEB
+	} else {
+		gre := GRENew(fr)
+		if !gre.decodeValid(data) {
+			return 0, 0, nil
+		}
+		if n, err = fr.toStringAddLayer(LayerGRE, gre, data); err != nil {
+			return 0, 0, err
+		}
+		protocol = gre.greHdr.Protocol
+	}
+
+	m, trailer, err := fr.toStringInner(protocol, data[n:])
+
+	return n + m, trailer, err
+}
+
+// toStringGeneve decodes the GENEVE header and the inner frame, the number of bytes
+// consumed and the number of bytes at the end of the inner frame which are not part of
+// the payload are returned.
+func (fr *Frame) toStringGeneve(data []byte) (int, int, error) {
+
+	gn := GeneveNew(fr)
+	if !gn.decodeValid(data) {
+		return 0, 0, nil
+	}
+	n, err := fr.toStringAddLayer(LayerGeneve, gn, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	m, trailer, err := fr.toStringInner(gn.protocol, data[n:])
+
+	return n + m, trailer, err
+}
+
+// toStringInner decodes the inner IPv4, IPv6 or Ether layers of a tunnel for the given
+// protocol type. The number of bytes consumed and the number of bytes at the end of the
+// data which are not part of the payload are returned.
+func (fr *Frame) toStringInner(protocol uint16, data []byte) (int, int, error) {
+
+	switch protocol {
+	case EtherTypeIPv4:
+		return fr.toStringTunnel(ProtocolIPv4, data)
+	case EtherTypeIPv6:
+		return fr.toStringTunnel(ProtocolIPv6, data)
+	case EtherTypeTEB:
+		if len(data) < EtherHeaderLen {
+			return 0, 0, nil
+		}
+		n, _, trailer, err := fr.toStringEther(data, true)
+		return n, trailer, err
+	case EtherTypeMPLS, EtherTypeMPLSMc:
+		n, etherType, err := fr.toStringMPLS(data)
+		if err != nil || n == 0 {
+			return 0, 0, err
+		}
+		m, trailer, err := fr.toStringInner(etherType, data[n:])
+		return n + m, trailer, err
+	}
+
+	return 0, 0, nil
+}
+
+// toStringL4 decodes the L4 layer for the given protocol ID and returns the number of
+// bytes consumed plus the number of bytes at the end of the data which are not part of
+// the payload. A zero length means the L4 layer is not supported.
+func (fr *Frame) toStringL4(protocol int, data []byte) (int, int, error) {
+
+	var n int
+	var err error
+
+	switch protocol {
+	case ProtocolUDP:
+		udp := UDPNew(fr)
+		if !udp.decodeValid(data) {
+			return 0, 0, nil
+		}
+		if n, err = fr.toStringAddLayer(LayerUDP, udp, data); err != nil {
+			return 0, 0, err
+		}
+		switch udp.udpHdr.DstPort {
+		case VxLanPort:
+			m, trailer, err := fr.toStringVxLan(data[n:])
+			return n + m, trailer, err
+		case GenevePort:
+			m, trailer, err := fr.toStringGeneve(data[n:])
+			return n + m, trailer, err
+		}
+	case ProtocolTCP:
+		tcp := TCPNew(fr)
+		if !tcp.decodeValid(data) {
+			return 0, 0, nil
+		}
+		n, err = fr.toStringAddLayer(LayerTCP, tcp, data)
+	case ProtocolICMPv4:
+		if _, ok := fr.outerLayer(nil, LayerIPv4, LayerIPv6).(*IPv4Layer); !ok {
+			return 0, 0, nil
+		}
+		icmp := ICMPv4New(fr)
+		if !icmp.decodeValid(data) {
+			return 0, 0, nil
+		}
+		n, err = fr.toStringAddLayer(LayerICMPv4, icmp, data)
+	case ProtocolICMPv6:
+		icmp := ICMPv6New(fr)
+		if !icmp.decodeValid(data) {
+			return 0, 0, nil
+		}
+		n, err = fr.toStringAddLayer(LayerICMPv6, icmp, data)
+	case ProtocolSCTP:
+		return fr.toStringSCTP(data)
+	case ProtocolIPv4, ProtocolIPv6:
+		return fr.toStringTunnel(protocol, data)
+	case ProtocolGRE:
+		return fr.toStringGRE(data)
+	}
+
+	return n, 0, err
+}
+
+// l3LengthMatches returns true if the length of the IPv4 or IPv6 packet in the data
+// slice is the length of the data slice.
+func l3LengthMatches(etherType uint16, data []byte) bool {
+
+	switch {
+	case etherType == EtherTypeIPv4 && len(data) >= IPv4MinLen:
+		return int(binary.BigEndian.Uint16(data[2:])) == len(data)
+	case etherType == EtherTypeIPv6 && len(data) >= ipv6.HeaderLen:
+		return ipv6.HeaderLen+int(binary.BigEndian.Uint16(data[4:])) == len(data)
+	}
+	return false
+}
+
+// toStringEther decodes the layers Ether -> VLAN tags -> L3 -> L4 from the data slice.
+// The number of bytes consumed, the end of the L3 packet and the number of bytes at the
+// end of the L3 packet which are not part of the payload are returned. When exact is true
+// the L3 packet must fill the data slice, as an inner frame of a tunnel has no padding.
+func (fr *Frame) toStringEther(data []byte, exact bool) (int, int, int, error) {
+
+	ether := EtherNew(fr)
+	if _, err := fr.toStringAddLayer(LayerEther, ether, data); err != nil {
+		return 0, 0, 0, err
+	}
+
+	// The VLAN tags are located in place of the EtherType and the EtherType of
+	// the Ether() layer is the EtherType after the VLAN tags.
+	off := EtherHeaderLen - 2
+	n, etherType, err := fr.toStringVlan(data[off:])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if n > 0 {
+		ether.ether.EtherType = etherType
+	}
+	off += n + 2
+
+	if etherType == EtherTypeMPLS || etherType == EtherTypeMPLSMc {
+		if n, etherType, err = fr.toStringMPLS(data[off:]); err != nil {
+			return 0, 0, 0, err
+		}
+		off += n
+	}
+
+	if exact && !l3LengthMatches(etherType, data[off:]) {
+		return off, len(data), 0, nil
+	}
+
+	n, protocol, end, err := fr.toStringL3(etherType, data[off:])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	end += off // Any data after the L3 packet is padding
+	off += n
+
+	trailer := 0
+	if n > 0 {
+		if n, trailer, err = fr.toStringL4(protocol, data[off:end]); err != nil {
+			return 0, 0, 0, err
+		}
+		off += n
+	}
+
+	return off, end, trailer, nil
+}
+
+// toStringFrame walks the binary frame data Ether -> VLAN tags -> L3 -> L4 -> Payload
+// and builds up the frame layers. Any data which can not be represented by a layer
+// is left in the payload, which means the frame string will always produce the same
+// binary frame data.
+func (fr *Frame) toStringFrame(data []byte) error {
+
+	if len(data) < EtherHeaderLen {
+		return fmt.Errorf("frame too short %d bytes, must be at least %d bytes", len(data), EtherHeaderLen)
+	}
+
+	off, end, trailer, err := fr.toStringEther(data, false)
+	if err != nil {
+		return err
+	}
+
+	// The pktgen signature is at the start of the payload
+	if tsc := TSCNew(fr); tsc.decodeValid(data[off : end-trailer]) {
+		n, err := fr.toStringAddLayer(LayerTSC, tsc, data[off:])
+		if err != nil {
+			return err
+		}
+		off += n
+	}
+
+	if off < end-trailer {
+		if _, err := fr.toStringAddLayer(LayerPayload, PayloadNew(fr), data[off:end-trailer]); err != nil {
+			return err
+		}
+	}
+
+	fr.frame.Append(data[:end])
+
+	return fr.toBinaryAddDefaultLayers()
+}
+
+// BinaryToString converts a binary frame to a frame string, the frame is added to the
+// FrameSerde using the given name. The frame string returned will produce the same binary
+// frame data when passed to StringToBinary(), except any padding after the L3 packet is
+// removed, as WritePCAP() pads the frame to the minimum frame length. A frame longer than
+// the MTU of the FrameSerde is an error.
+func (f *FrameSerde) BinaryToString(name string, data []byte) (string, error) {
+
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", fmt.Errorf("missing frame name")
+	}
+
+	key := FrameKey{name: name, ftype: NormalFrameType}
+	if _, ok := f.frames[key]; ok {
+		return "", fmt.Errorf("duplicate frame name: %v", name)
+	}
+
+	fr := &Frame{
+		serde:     f,
+		frameType: NormalFrameType,
+		name:      name,
+		layersMap: make(LayerMap, 0),
+		protocols: make([]*ProtoInfo, 0),
+		frame:     &MyBuffer{Buf: bytes.Buffer{}},
+	}
+
+	if err := fr.toStringFrame(data); err != nil {
+		return "", err
+	}
+
+	// The frame must be a frame StringToBinary() accepts for the MTU of the frame-serde
+	if el, ok := fr.GetLayer(LayerEther).(*EtherLayer); ok {
+		if err := el.checkMTU(fr.frame.Bytes()); err != nil {
+			return "", err
+		}
+	}
+
+	f.frames[key] = fr
+	f.frameNames = append(f.frameNames, fr.name)
+
+	return fr.String(), nil
+}
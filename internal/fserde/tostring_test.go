@@ -4,6 +4,8 @@
 package fserde
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/franela/goblin"
@@ -45,5 +47,85 @@ func TestSerializeBegin(t *testing.T) {
 				g.Assert(fg != nil).IsTrue("Create failed")
 			}
 		})
+
+		g.It("ToString round trip", func() {
+			fg, err := Create("Test 1", &FrameSerdeConfig{Defaults: toBinaryDefaultFrames})
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("create failed: %v", err))
+			defer fg.Destroy()
+
+			err = fg.StringsToBinary(toBinaryFrames)
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("StringsToBinary failed: %v", err))
+
+			for _, fr := range fg.GetFrames(NormalFrameType) {
+				fs, _ := Create("Test 1.1", nil)
+
+				str, err := fs.BinaryToString(fr.name, fr.frame.Bytes())
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+				df, _ := fs.GetFrame(fr.name, NormalFrameType)
+				g.Assert(df.GetProtocolID()).Equal(fr.GetProtocolID())
+
+				fb, _ := Create("Test 1.2", nil)
+				err = fb.StringToBinary(str)
+				g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary '%v' failed: %v", str, err))
+
+				rt, _ := fb.GetFrame(fr.name, NormalFrameType)
+				g.Assert(bytes.Equal(rt.frame.Bytes(), fr.frame.Bytes())).IsTrue(
+					fmt.Sprintf("frames differ\n%v%v", fr.FrameDump(), rt.FrameDump()))
+			}
+		})
+
+		g.It("ToString layers", func() {
+			fg, _ := Create("Test 2", nil)
+			defer fg.Destroy()
+
+			err := fg.StringToBinary("Frame1 := Ether(dst=00:11:22:33:44:55, proto=0x800)/" +
+				"IPv4(dst=10.0.0.1, src=10.0.0.2, id=0, flags=2)/" +
+				"TCP(sport=1234, dport=80, flags=[SYN])/Payload(size=6, string='abc')")
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary failed: %v", err))
+			fr, _ := fg.GetFrame("Frame1", NormalFrameType)
+
+			// Add Ethernet padding to the frame to the minimum frame length
+			data := append(bytes.Clone(fr.frame.Bytes()), make([]byte, 4)...)
+
+			fs, _ := Create("Test 2.1", nil)
+			_, err = fs.BinaryToString("Frame1", data)
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+
+			df, _ := fs.GetFrame("Frame1", NormalFrameType)
+			g.Assert(bytes.Equal(df.frame.Bytes(), fr.frame.Bytes())).IsTrue("padding not removed")
+
+			ip, ok := df.GetLayer(LayerIPv4).(*IPv4Layer)
+			g.Assert(ok && ip.ipHdr.ID == 0 && ip.ipHdr.Flags == 2).IsTrue("invalid IPv4 layer")
+			tcp, ok := df.GetLayer(LayerTCP).(*TCPLayer)
+			g.Assert(ok && tcp.tcpHdr.DstPort == 80 && tcp.tcpHdr.Flags == TCPSynFlag).IsTrue("invalid TCP layer")
+			pl, ok := df.GetLayer(LayerPayload).(*PayloadLayer)
+			g.Assert(ok && pl.length == 6 && pl.fill == fillStringType).IsTrue("invalid Payload layer")
+			g.Assert(df.GetOffset(LayerTCP)).Equal(uint16(34))
+
+			_, err = fs.BinaryToString("Frame1", data)
+			g.Assert(err != nil).IsTrue("duplicate frame name should fail")
+		})
+
+		g.It("ToString unknown protocols", func() {
+			fs, _ := Create("Test 3", nil)
+			defer fs.Destroy()
+
+			// Ether with an unknown EtherType, the rest is payload data
+			data := []byte{
+				0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x11, 0x22, 0x33, 0x44, 0x66, 0x88, 0xb5,
+				0xde, 0xad, 0xbe, 0xef, 0x00, 0x2f, 0x28, 0x29,
+			}
+			str, err := fs.BinaryToString("Frame1", data)
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("BinaryToString failed: %v", err))
+
+			fb, _ := Create("Test 3.1", nil)
+			err = fb.StringToBinary(str)
+			g.Assert(err == nil).IsTrue(fmt.Sprintf("StringToBinary '%v' failed: %v", str, err))
+			rt, _ := fb.GetFrame("Frame1", NormalFrameType)
+			g.Assert(rt.frame.Bytes()).Equal(data)
+
+			_, err = fs.BinaryToString("Frame2", data[:10])
+			g.Assert(err != nil).IsTrue("short frame should fail")
+		})
 	})
 }
@@ -0,0 +1,73 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Truncate() is a layer to cut the frame data to N bytes after the lengths and checksums
+// are written, which gives a malformed frame with lengths larger than the frame.
+//    e.g., Ether()/IPv4()/UDP()/Payload(size=100)/Truncate(60)
+
+// TruncateLayer the structure holding the information on each layer.
+type TruncateLayer struct {
+	hdr    *LayerHdr
+	length uint32
+}
+
+func (l *TruncateLayer) String() string {
+	return fmt.Sprintf("%s(%d)", l.hdr.layerName, l.length)
+}
+
+// TruncateNew creates a new TruncateLayer and is registered as the Truncate layer create function.
+func TruncateNew(fr *Frame) *TruncateLayer {
+	return &TruncateLayer{
+		hdr: LayerConstructor(fr, LayerTruncate, LayerTruncateType),
+	}
+}
+
+// Name returns the name of the layer.
+func (l *TruncateLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+// Parse parses the layer options string.
+func (l *TruncateLayer) Parse(opts string) error {
+
+	v, err := strconv.ParseUint(strings.TrimSpace(opts), 0, 32)
+	if err != nil {
+		return fmt.Errorf("truncate needs the frame length: [%s]", opts)
+	}
+	if v == 0 {
+		return fmt.Errorf("truncate length must be greater than zero")
+	}
+	l.length = uint32(v)
+
+	return nil
+}
+
+// ApplyDefaults applies the default values for the layer.
+func (l *TruncateLayer) ApplyDefaults() error {
+
+	return nil
+}
+
+// WriteLayer writes the layer to hdr.frame []byte.
+func (l *TruncateLayer) WriteLayer() error {
+
+	return nil
+}
+
+// truncate cuts the frame data to the length, a frame shorter than the length is not changed.
+func (l *TruncateLayer) truncate(b []byte) []byte {
+
+	if len(b) > int(l.length) {
+		return b[:l.length]
+	}
+	return b
+}
@@ -4,12 +4,59 @@
 
 package fserde
 
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The TSC() layer is the pktgen signature used for latency and loss measurement, it is
+// placed in front of the payload, i.e., Ether()/IPv4()/UDP()/TSC(stream=1)/Payload()
+//
+// magic - is the 32 bit signature value, defaults to 0x50475453 "PGTS".
+// [stream|sid] - is the 32 bit stream ID.
+// seq - is the 32 bit sequence number.
+// [ts|timestamp] - is the 64 bit timestamp, defaults to zero as a placeholder.
+//
+// All of the fields are in network byte order. The offsets of the fields in the frame are
+// returned by Frame.GetProtocol(LayerTSC).FieldOffset(TSCFieldSeq), which allows the
+// transmit path to update the sequence number and timestamp for each packet.
+
+const (
+	TSCHeaderLen       = 20
+	TSCMagic           = 0x50475453 // "PGTS" pktgen timestamp signature
+	TSCMagicOffset     = 0
+	TSCStreamOffset    = 4
+	TSCSeqOffset       = 8
+	TSCTimestampOffset = 12
+)
+
+// Names of the TSC fields used with ProtoInfo.FieldOffset()
+const (
+	TSCFieldMagic     = "magic"
+	TSCFieldStream    = "stream"
+	TSCFieldSeq       = "seq"
+	TSCFieldTimestamp = "timestamp"
+)
+
+type TSCHdr struct {
+	Magic     uint32 // Signature value
+	StreamID  uint32 // Stream ID
+	SeqNum    uint32 // Sequence number
+	Timestamp uint64 // Timestamp
+}
+
 type TSCLayer struct {
-	hdr *LayerHdr
+	hdr    *LayerHdr
+	tscHdr TSCHdr
 }
 
 func (l *TSCLayer) String() string {
-	return "TSC()"
+	h := l.tscHdr
+
+	return fmt.Sprintf("%s(magic=0x%08x, stream=%d, seq=%d, ts=%d)", l.Name(), h.Magic, h.StreamID, h.SeqNum, h.Timestamp)
 }
 
 func TSCNew(fr *Frame) *TSCLayer {
@@ -22,33 +69,167 @@ func (l *TSCLayer) Name() LayerName {
 	return l.hdr.layerName
 }
 
-func (l *TSCLayer) Parse(opts string) error {
+// setProtocol adds the TSC protocol with the offsets of the fields to the frame.
+func (l *TSCLayer) setProtocol() {
 
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 4
+	l.hdr.proto.length = TSCHeaderLen
+	l.hdr.proto.fields = map[string]uint16{
+		TSCFieldMagic:     TSCMagicOffset,
+		TSCFieldStream:    TSCStreamOffset,
+		TSCFieldSeq:       TSCSeqOffset,
+		TSCFieldTimestamp: TSCTimestampOffset,
+	}
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
+}
+
+func (l *TSCLayer) Parse(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "magic":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tscHdr.Magic = uint32(v)
+			}
+		case "stream", "sid":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tscHdr.StreamID = uint32(v)
+			}
+		case "seq":
+			if v, err := strconv.ParseUint(val, 0, 32); err != nil {
+				return err
+			} else {
+				l.tscHdr.SeqNum = uint32(v)
+			}
+		case "ts", "timestamp":
+			if v, err := strconv.ParseUint(val, 0, 64); err != nil {
+				return err
+			} else {
+				l.tscHdr.Timestamp = v
+			}
+		default:
+			return fmt.Errorf("unknown tsc option: [%s]", opt)
+		}
+	}
+
+	l.setProtocol()
 
 	return nil
 }
 
 func (l *TSCLayer) ApplyDefaults() error {
 
+	// The magic value defaults to the pktgen signature after the default frame values
+	defer func() {
+		if l.tscHdr.Magic == 0 {
+			l.tscHdr.Magic = TSCMagic
+		}
+	}()
+
 	d := l.hdr.fr.defaultsFrame
 	if d == nil {
 		return nil
 	}
 
-	_, ok := d.GetLayer(LayerTSC).(*TSCLayer)
+	dl, ok := d.GetLayerIndex(LayerTSC, l.hdr.index).(*TSCLayer)
 	if !ok {
 		return nil
 	}
 
+	if l.tscHdr.Magic == 0 && dl.tscHdr.Magic != 0 {
+		l.tscHdr.Magic = dl.tscHdr.Magic
+	}
+	if l.tscHdr.StreamID == 0 && dl.tscHdr.StreamID != 0 {
+		l.tscHdr.StreamID = dl.tscHdr.StreamID
+	}
+
 	return nil
 }
 
 func (l *TSCLayer) WriteLayer() error {
 
+	data := l.hdr.fr.frame
+
+	data.Append(l.tscHdr.Magic)
+	data.Append(l.tscHdr.StreamID)
+	data.Append(l.tscHdr.SeqNum)
+	data.Append(l.tscHdr.Timestamp)
+
 	return nil
 }
+
+// decodeValid returns true if the data slice starts with the pktgen signature.
+func (l *TSCLayer) decodeValid(data []byte) bool {
+
+	return len(data) >= TSCHeaderLen && binary.BigEndian.Uint32(data[TSCMagicOffset:]) == TSCMagic
+}
+
+// Decode the TSC signature from the binary frame data.
+func (l *TSCLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < TSCHeaderLen {
+		return 0, fmt.Errorf("tsc header too short: %d bytes", len(data))
+	}
+
+	l.tscHdr.Magic = binary.BigEndian.Uint32(data[TSCMagicOffset:])
+	l.tscHdr.StreamID = binary.BigEndian.Uint32(data[TSCStreamOffset:])
+	l.tscHdr.SeqNum = binary.BigEndian.Uint32(data[TSCSeqOffset:])
+	l.tscHdr.Timestamp = binary.BigEndian.Uint64(data[TSCTimestampOffset:])
+
+	l.setProtocol()
+
+	return TSCHeaderLen, nil
+}
+
+// TSCDecode returns the pktgen signature fields of a received frame. The frame headers
+// are decoded the same way as BinaryToString() and the signature must be at the start of
+// the payload following the inner most decoded layer. The L4 checksum must be valid or
+// zero when the fields are updated by the transmit path.
+func TSCDecode(data []byte) (TSCHdr, error) {
+
+	if len(data) < EtherHeaderLen {
+		return TSCHdr{}, fmt.Errorf("frame too short %d bytes, must be at least %d bytes", len(data), EtherHeaderLen)
+	}
+
+	fr := &Frame{
+		frameType: NormalFrameType,
+		layersMap: make(LayerMap, 0),
+		protocols: make([]*ProtoInfo, 0),
+		frame:     &MyBuffer{Buf: bytes.Buffer{}},
+	}
+
+	off, end, trailer, err := fr.toStringEther(data, false)
+	if err != nil {
+		return TSCHdr{}, err
+	}
+
+	l := TSCNew(fr)
+	if !l.decodeValid(data[off : end-trailer]) {
+		return TSCHdr{}, fmt.Errorf("tsc signature not found at offset %d", off)
+	}
+	if _, err := l.Decode(data[off:]); err != nil {
+		return TSCHdr{}, err
+	}
+
+	return l.tscHdr, nil
+}
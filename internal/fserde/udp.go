@@ -5,6 +5,7 @@
 package fserde
 
 import (
+	"encoding/binary"
 	"fmt"
 	"strconv"
 	"strings"
@@ -26,13 +27,21 @@ type UDPHdr struct {
 type UDPLayer struct {
 	hdr    *LayerHdr
 	udpHdr UDPHdr
+	lenSet bool             // Length was given in the options and is not computed
+	cksum  checksumOverride // Checksum given in the options
 }
 
 func (u *UDPLayer) String() string {
 	hdr := u.udpHdr
 
-	return fmt.Sprintf("UDP(sport=%d, dport=%d, length=%d, checksum=%v)",
-		hdr.SrcPort, hdr.DstPort, hdr.Length, hdr.Checksum)
+	s := fmt.Sprintf("UDP(sport=%d, dport=%d", hdr.SrcPort, hdr.DstPort)
+	if u.lenSet {
+		s += fmt.Sprintf(", length=%d", hdr.Length)
+	}
+	if u.cksum.set {
+		return s + fmt.Sprintf(", checksum=%v)", u.cksum)
+	}
+	return s + fmt.Sprintf(", checksum=%v)", hdr.Checksum)
 }
 
 func UDPNew(fr *Frame) *UDPLayer {
@@ -47,18 +56,20 @@ func (l *UDPLayer) Name() LayerName {
 
 func (u *UDPLayer) Parse(opts string) error {
 
-	options := strings.Split(opts, ",")
+	options := splitOptions(opts)
 
 	for _, opt := range options {
 		opt = strings.TrimSpace(opt)
-
-		kvp := strings.Split(opt, "=")
-		if len(kvp) != 2 {
-			return fmt.Errorf("invalid option: %s", opt)
+		if len(opt) == 0 {
+			continue
 		}
 
-		key := strings.ToLower(strings.TrimSpace(kvp[0]))
-		val := strings.ToLower(strings.TrimSpace(kvp[1]))
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
 
 		switch key {
 		case "sport", "srcport", "src":
@@ -73,6 +84,13 @@ func (u *UDPLayer) Parse(opts string) error {
 			} else {
 				u.udpHdr.DstPort = uint16(v)
 			}
+		case "len", "length":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				u.udpHdr.Length = uint16(v)
+				u.lenSet = true
+			}
 		case "checksum":
 			switch val {
 			case "on", "yes", "true", "enable", "enabled", "1":
@@ -80,11 +98,17 @@ func (u *UDPLayer) Parse(opts string) error {
 			case "off", "no", "false", "disable", "disabled", "0":
 				u.udpHdr.Checksum = false
 			default:
-				return fmt.Errorf("checksum invalid value: %s", val)
+				// A bad or fixed checksum is always written
+				if u.cksum, err = parseChecksumOverride(val); err != nil {
+					return err
+				}
+				u.udpHdr.Checksum = true
 			}
 		}
 	}
-	u.udpHdr.Length = UDPDefaultLen
+	if !u.lenSet {
+		u.udpHdr.Length = UDPDefaultLen
+	}
 
 	u.hdr.proto.name = u.Name()
 	u.hdr.proto.offset = u.hdr.fr.GetOffset(u.Name())
@@ -102,7 +126,7 @@ func (l *UDPLayer) ApplyDefaults() error {
 		return nil
 	}
 
-	dl, ok := df.GetLayer(LayerUDP).(*UDPLayer)
+	dl, ok := df.GetLayerIndex(LayerUDP, l.hdr.index).(*UDPLayer)
 	if !ok {
 		return nil
 	}
@@ -118,6 +142,9 @@ func (l *UDPLayer) ApplyDefaults() error {
 	if !l.udpHdr.Checksum && dl.udpHdr.Checksum {
 		l.udpHdr.Checksum = dl.udpHdr.Checksum
 	}
+	if !l.cksum.set && dl.cksum.set {
+		l.cksum = dl.cksum
+	}
 
 	return nil
 }
@@ -137,3 +164,73 @@ func (l *UDPLayer) WriteLayer() error {
 
 	return nil
 }
+
+// updateChecksum computes the UDP checksum using the IPv4 or IPv6 layer carrying the
+// datagram. The checksum is optional for IPv4 and mandatory for IPv6.
+func (l *UDPLayer) updateChecksum() error {
+
+	fr := l.hdr.fr
+	off := fr.protoOffset(&l.hdr.proto)
+	d := fr.frame.Bytes()[off+UDPHeaderLen : off+fr.protoLength(&l.hdr.proto)]
+
+	var cksum uint16
+	switch ip := fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6).(type) {
+	case *IPv4Layer:
+		if !l.udpHdr.Checksum {
+			return nil
+		}
+		cksum = IPv4UDPChecksum(&ip.ipHdr, &l.udpHdr, d)
+	case *IPv6Layer:
+		cksum = IPv6UDPChecksum(&ip.ip6Hdr, &l.udpHdr, d)
+	default:
+		return nil
+	}
+
+	return fr.frame.WriteValueAt(int(off+UDPChecksumOffset), l.cksum.checksum(cksum))
+}
+
+// decodeValid returns true if the data slice contains a UDP header the UDP layer can
+// represent, which is a UDP length matching the data and a valid checksum. The checksum
+// may be zero for IPv4 only.
+func (l *UDPLayer) decodeValid(data []byte) bool {
+
+	if len(data) < UDPHeaderLen || int(binary.BigEndian.Uint16(data[4:])) != len(data) {
+		return false
+	}
+
+	cksum := binary.BigEndian.Uint16(data[UDPChecksumOffset:])
+	hdr := UDPHdr{
+		SrcPort: binary.BigEndian.Uint16(data[0:]),
+		DstPort: binary.BigEndian.Uint16(data[2:]),
+		Length:  uint16(len(data)),
+	}
+
+	ipLayer := l.hdr.fr.outerLayer(&l.hdr.proto, LayerIPv4, LayerIPv6)
+	if ip, ok := ipLayer.(*IPv4Layer); ok {
+		return cksum == 0 || IPv4UDPChecksum(&ip.ipHdr, &hdr, data[UDPHeaderLen:]) == cksum
+	} else if ip, ok := ipLayer.(*IPv6Layer); ok {
+		return IPv6UDPChecksum(&ip.ip6Hdr, &hdr, data[UDPHeaderLen:]) == cksum
+	}
+	return false
+}
+
+// Decode the UDP header from the binary frame data.
+func (l *UDPLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < UDPHeaderLen {
+		return 0, fmt.Errorf("udp header too short: %d bytes", len(data))
+	}
+
+	l.udpHdr.SrcPort = binary.BigEndian.Uint16(data[0:])
+	l.udpHdr.DstPort = binary.BigEndian.Uint16(data[2:])
+	l.udpHdr.Length = binary.BigEndian.Uint16(data[4:])
+	l.udpHdr.Checksum = binary.BigEndian.Uint16(data[UDPChecksumOffset:]) != 0
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = UDPHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return UDPHeaderLen, nil
+}
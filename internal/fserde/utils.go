@@ -25,3 +25,18 @@ func Roundup(v, mul uint64) uint64 {
 	mul--
 	return (v + mul) &^ mul
 }
+
+// splitOptions splits a layer options string on the ',' delimiter, but only when
+// the ',' is not nested inside of a (), {} or [] pair or a quoted string.
+// i.e., "Dot1q{vlan=1, pcp=2}, Dot1q{vlan=3}" to ["Dot1q{vlan=1, pcp=2}", "Dot1q{vlan=3}"]
+func splitOptions(opts string) []string {
+
+	options := make([]string, 0)
+
+	start := 0
+	for _, i := range topLevelIndex(opts, ',') {
+		options = append(options, opts[start:i])
+		start = i + 1
+	}
+	return append(options, opts[start:])
+}
@@ -0,0 +1,153 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// The frame strings may reference the variables of the FrameSerdeConfig, the references are
+// replaced with the variable values before the frame string is parsed. The references are:
+//
+// ${NAME} - the value of the variable NAME from FrameSerdeConfig.Vars.
+// ${PORT<n>_NAME} - the value of NAME from the variables of port n, FrameSerdeConfig.PortVars[n].
+// ${NAME}+N, ${NAME}-N - the value plus or minus N, the value is an integer, MAC address,
+//                        IPv4 or IPv6 address, i.e., IPv4(dst=${BASE_IP}+3).
+//
+// A reference to an undefined variable is an error, the variable values are not expanded.
+
+// varPattern matches a variable reference and the optional +N or -N following it.
+var varPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}(\s*([+-])\s*(0[xX][0-9a-fA-F]+|[0-9]+))?`)
+
+// varName matches a valid variable name.
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// newFrameVars returns the variables of the configuration, the port variables are added as
+// PORT<n>_<NAME> for the port index n.
+func newFrameVars(cfg *FrameSerdeConfig) (map[string]string, error) {
+
+	vars := make(map[string]string)
+	if cfg == nil {
+		return vars, nil
+	}
+
+	for name, val := range cfg.Vars {
+		if !varName.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name: %q", name)
+		}
+		vars[name] = val
+	}
+
+	for port, pv := range cfg.PortVars {
+		for name, val := range pv {
+			if !varName.MatchString(name) {
+				return nil, fmt.Errorf("invalid port %d variable name: %q", port, name)
+			}
+			key := fmt.Sprintf("PORT%d_%s", port, name)
+			if _, ok := vars[key]; ok {
+				return nil, fmt.Errorf("port %d variable %s is also defined as %s", port, name, key)
+			}
+			vars[key] = val
+		}
+	}
+
+	return vars, nil
+}
+
+// offsetVarValue returns the variable value plus or minus n.
+func offsetVarValue(val, op, n string) (string, error) {
+
+	v, ftype, err := parseFieldValue(val)
+	if err != nil {
+		return "", err
+	}
+	delta, ok := new(big.Int).SetString(n, 0)
+	if !ok {
+		return "", fmt.Errorf("invalid variable offset: %s", n)
+	}
+
+	if op == "-" {
+		v.Sub(v, delta)
+	} else {
+		v.Add(v, delta)
+	}
+	if v.Sign() < 0 {
+		return "", fmt.Errorf("value %s%s%s is less than zero", val, op, n)
+	}
+	if bits, ok := fieldBits[ftype]; ok && v.BitLen() > int(bits) {
+		return "", fmt.Errorf("value %s%s%s is out of range", val, op, n)
+	}
+
+	return formatFieldValue(v, ftype), nil
+}
+
+// stringPosition returns the line and column of the byte offset in the string.
+func stringPosition(s string, off int) position {
+
+	pos := position{line: 1, col: 1}
+	for _, r := range s[:off] {
+		if r == '\n' {
+			pos.line++
+			pos.col = 1
+		} else {
+			pos.col++
+		}
+	}
+	return pos
+}
+
+// expandVars replaces the variable references in the frame string with the variable values,
+// an error is returned for a reference to an undefined variable.
+func (f *FrameSerde) expandVars(frameString string) (string, error) {
+
+	if !strings.Contains(frameString, "${") {
+		return frameString, nil
+	}
+
+	name, _, _ := strings.Cut(frameString, ":=")
+	name = strings.TrimSpace(name)
+
+	// A reference not matching the pattern, i.e., ${} or a missing '}', is an error
+	invalid := func(start, end int) error {
+		if i := strings.Index(frameString[start:end], "${"); i >= 0 {
+			return parseError(name, stringPosition(frameString, start+i), "invalid variable reference")
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range varPattern.FindAllStringSubmatchIndex(frameString, -1) {
+		if err := invalid(last, m[0]); err != nil {
+			return "", err
+		}
+		ref := frameString[m[2]:m[3]]
+
+		val, ok := f.vars[ref]
+		if !ok {
+			return "", parseError(name, stringPosition(frameString, m[0]), "undefined variable ${%s}", ref)
+		}
+		if m[4] >= 0 {
+			v, err := offsetVarValue(val, frameString[m[6]:m[7]], frameString[m[8]:m[9]])
+			if err != nil {
+				return "", parseError(name, stringPosition(frameString, m[0]), "variable ${%s}: %v", ref, err)
+			}
+			val = v
+		}
+
+		sb.WriteString(frameString[last:m[0]])
+		sb.WriteString(val)
+		last = m[1]
+	}
+	if err := invalid(last, len(frameString)); err != nil {
+		return "", err
+	}
+	sb.WriteString(frameString[last:])
+
+	return sb.String(), nil
+}
@@ -0,0 +1,267 @@
+/* SPDX-License-Identifier: BSD-3-Clause
+ * Copyright (c) 2023-2025 Intel Corporation.
+ */
+
+package fserde
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The Vlan() protocol layer is a VLAN tag, the layer may be repeated to stack the tags to
+// any depth, i.e., Ether()/Vlan(vid=100)/Vlan(vid=200)/Vlan(vid=300)/IPv4().
+//
+// tpid - is the tag protocol ID, defaults to 0x88a8 for a tag followed by another tag
+//        and 0x8100 for the inner most tag.
+// [pcp|prio], [dei|cfi], [vid|vlan] - are the priority, drop eligible and VLAN ID values.
+// tci - is the tag control information, the pcp, dei and vid values are ignored.
+//
+// The Dot1Q() and Dot1AD() layers are aliases of the Vlan() layer with the TPID 0x8100 and
+// 0x88a8. A warning is added to the frame when a 0x88a8 service tag follows a 0x8100 tag.
+
+const (
+	VlanTagLen = 4 // Length of a VLAN tag
+)
+
+type VlanLayer struct {
+	hdr       *LayerHdr
+	tpid      uint16 // Tag protocol ID
+	tci       uint16 // Contains pcp 3bits, dei 1bit, vid 12bits
+	pcp       uint16 // Part of tci
+	dei       uint16 // Part of tci
+	vid       uint16 // Part of tci
+	tpidSet   bool   // TPID was given in the options
+	vidSet    bool   // VLAN ID was given in the options
+	tciSet    bool   // TCI was given in the options
+	aliasTPID uint16 // TPID of the alias layer, zero when derived from the following layer
+}
+
+func (l *VlanLayer) String() string {
+	return fmt.Sprintf("%s(tpid=0x%04x, pcp=%v, dei=%v, vid=%v)", l.Name(), l.tpid, (l.tci >> 13),
+		(l.tci>>12)&0x1, l.tci&0x0fff)
+}
+
+func (l *VlanLayer) Name() LayerName {
+	return l.hdr.layerName
+}
+
+func VlanNew(fr *Frame) *VlanLayer {
+	return &VlanLayer{
+		hdr: LayerConstructor(fr, LayerVlan, LayerVlanType),
+	}
+}
+
+// isVlanLayer returns true if the layer name is one of the VLAN tag layers.
+func isVlanLayer(name LayerName) bool {
+	return name == LayerVlan || name == LayerDot1Q || name == LayerQinQ || name == LayerDot1AD
+}
+
+// isVlanTPID returns true if the value is the TPID of a VLAN tag.
+func isVlanTPID(tpid uint16) bool {
+	return tpid == Dot1QID || tpid == QinQID
+}
+
+// parseTag parses the tag options, which are the options of the layer or of a QinQ tag.
+func (l *VlanLayer) parseTag(opts string) error {
+
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "tpid":
+			if v, err := strconv.ParseUint(val, 0, 16); err != nil {
+				return err
+			} else {
+				l.tpid = uint16(v)
+				l.tpidSet = true
+			}
+		case "prio", "pcp":
+			if v, err := strconv.ParseUint(val, 0, 0); err != nil {
+				return err
+			} else {
+				l.pcp = uint16(v) & 0x07
+			}
+		case "cfi", "dei":
+			if v, err := strconv.ParseUint(val, 0, 0); err != nil {
+				if strings.EqualFold(val, "true") {
+					l.dei = 1
+				} else if strings.EqualFold(val, "false") {
+					l.dei = 0
+				} else {
+					return fmt.Errorf("invalid boolean value: %s", val)
+				}
+			} else {
+				l.dei = uint16(v) & 1
+			}
+		case "vlan", "vid":
+			if v, err := strconv.ParseUint(val, 0, 0); err != nil {
+				return err
+			} else {
+				l.vid = (uint16(v) & 0x0FFF)
+				l.vidSet = true
+			}
+		case "tci": // when tci is set then vid, pcp and dei are ignored
+			if v, err := strconv.ParseUint(val, 0, 0); err != nil {
+				return err
+			} else {
+				l.tci = (uint16(v) & 0xFFFF)
+				l.tciSet = true
+			}
+		default:
+			return fmt.Errorf("unknown %s option: [%s]", strings.ToLower(string(l.Name())), opt)
+		}
+	}
+
+	if !l.tpidSet {
+		l.tpid = l.aliasTPID
+	}
+	l.updateTCI()
+
+	return nil
+}
+
+// updateTCI sets the TCI from the pcp, dei and vid values unless the TCI was given.
+func (l *VlanLayer) updateTCI() {
+
+	if l.tciSet {
+		return
+	}
+	if l.vid == 0 && !l.vidSet {
+		l.vid = DefaultVid
+	}
+	l.tci = uint16(l.pcp<<13 | l.dei<<12 | l.vid)
+}
+
+func (l *VlanLayer) Parse(opts string) error {
+
+	if err := l.parseTag(opts); err != nil {
+		return err
+	}
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = VlanTagLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return nil
+}
+
+func (l *VlanLayer) ApplyDefaults() error {
+
+	d := l.hdr.fr.defaultsFrame
+	if d == nil {
+		return nil
+	}
+
+	dl, ok := d.GetLayerIndex(l.Name(), l.hdr.index).(*VlanLayer)
+	if !ok {
+		return nil
+	}
+
+	if !l.tpidSet && dl.tpidSet {
+		l.tpid = dl.tpid
+		l.tpidSet = true
+	}
+	if !l.tciSet && !l.vidSet && (dl.tciSet || dl.vidSet) {
+		l.pcp, l.dei, l.vid, l.tci = dl.pcp, dl.dei, dl.vid, dl.tci
+		l.vidSet, l.tciSet = dl.vidSet, dl.tciSet
+	}
+
+	return nil
+}
+
+// inferTPID sets the TPID of a Vlan() tag not given in the options, the TPID is the service
+// tag TPID when the tag is followed by another tag. A warning is added when a service tag
+// follows a customer tag.
+func (l *VlanLayer) inferTPID() {
+
+	fr := l.hdr.fr
+	next := fr.nextProtocol(&l.hdr.proto)
+
+	if l.tpid == 0 {
+		l.tpid = Dot1QID
+		if next != nil && isVlanLayer(next.name) {
+			l.tpid = QinQID
+		}
+	}
+
+	prev, ok := fr.outerLayer(&l.hdr.proto, LayerVlan, LayerDot1Q, LayerDot1AD).(*VlanLayer)
+	if ok && fr.nextProtocol(&prev.hdr.proto) == &l.hdr.proto && prev.tpid == Dot1QID && l.tpid == QinQID {
+		fr.warnings = append(fr.warnings, fmt.Sprintf("%s tpid=%#04x follows the %s tpid=%#04x tag, the service tag must be the outer tag",
+			l.Name(), l.tpid, prev.Name(), prev.tpid))
+	}
+}
+
+func (l *VlanLayer) WriteLayer() error {
+
+	writeVlanTags(l.hdr.fr, &l.hdr.proto, l.tpid, l.tci)
+
+	return nil
+}
+
+// writeVlanTags inserts the VLAN tag values in front of the EtherType of the Ether layer
+// carrying the tags, which is in front of the given protocol as the tags in front of the
+// protocol have been inserted.
+func writeVlanTags(fr *Frame, proto *ProtoInfo, tags ...uint16) {
+
+	data := fr.frame
+
+	off := int(fr.protoOffset(proto)) - 2
+
+	headData := make([]byte, off)
+	copy(headData, data.Bytes()[:off])
+	restData := make([]byte, data.Len()-off)
+	copy(restData, data.Bytes()[off:])
+
+	data.Reset()
+	data.Append(headData)
+	for _, tag := range tags {
+		data.Append(tag)
+	}
+	data.Append(restData)
+}
+
+// decodeTag decodes the VLAN tag TPID and TCI values from the data slice.
+func (l *VlanLayer) decodeTag(data []byte) {
+
+	l.tpid = binary.BigEndian.Uint16(data[0:])
+	l.tci = binary.BigEndian.Uint16(data[2:])
+	l.pcp = l.tci >> 13
+	l.dei = (l.tci >> 12) & 0x1
+	l.vid = l.tci & 0x0FFF
+	l.tpidSet, l.vidSet = true, true
+}
+
+// Decode the VLAN tag from the binary frame data starting at the TPID value.
+func (l *VlanLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < VlanTagLen {
+		return 0, fmt.Errorf("%s tag too short: %d bytes", strings.ToLower(string(l.Name())), len(data))
+	}
+
+	l.decodeTag(data)
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = VlanTagLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return VlanTagLen, nil
+}
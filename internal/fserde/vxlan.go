@@ -5,17 +5,41 @@
 package fserde
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// The VxLan() protocol layer follows the outer UDP layer and is followed by the inner
+// frame starting with an Ether() layer, i.e., Ether()/IPv4()/UDP()/VxLan(vni=10)/Ether()/IPv4()/...
+//
+// vni - is the 24 bit VXLAN network identifier.
+// flags - is the 8 bit flags value, defaults to 0x08 the valid VNI flag.
+//
+// The outer UDP destination port defaults to 4789 and the outer UDP source port defaults
+// to a flow entropy value computed from a hash of the inner frame headers.
+
+const (
+	VxLanHeaderLen    = 8
+	VxLanPort         = 4789 // IANA assigned VXLAN UDP port
+	VxLanValidVNIFlag = 0x08 // The VNI is valid flag
+	VxLanMaxVNI       = 0xFFFFFF
+	VxLanMinSrcPort   = 49152 // Start of the flow entropy source port range
+	VxLanSrcPortRange = 16384 // Number of flow entropy source ports
 )
 
 type VxLanLayer struct {
 	hdr       *LayerHdr
 	vlanFlags uint8  // 8 bits VLAN flags
+	flagsSet  bool   // Flags were given in the options
 	vni       uint32 // 24 bits VNI value
+	entropy   bool   // The outer UDP source port is the flow entropy value
 }
 
 func (vx *VxLanLayer) String() string {
-	return fmt.Sprintf("VxLan: { flags=0x%02x, vni=0x%06x}", vx.vlanFlags, vx.vni)
+	return fmt.Sprintf("%s(flags=0x%02x, vni=0x%06x)", vx.Name(), vx.vlanFlags, vx.vni)
 }
 
 func VxLanNew(fr *Frame) *VxLanLayer {
@@ -30,9 +54,47 @@ func (l *VxLanLayer) Name() LayerName {
 
 func (l *VxLanLayer) Parse(opts string) error {
 
+	options := splitOptions(opts)
+
+	for _, opt := range options {
+		opt = strings.TrimSpace(opt)
+		if len(opt) == 0 {
+			continue
+		}
+
+		key, val, err := splitKeyValue(opt)
+		if err != nil {
+			return err
+		}
+		key = strings.ToLower(key)
+		val = strings.ToLower(val)
+
+		switch key {
+		case "vni":
+			if v, err := strconv.ParseUint(val, 0, 24); err != nil {
+				return fmt.Errorf("invalid vxlan vni: %s", val)
+			} else {
+				l.vni = uint32(v)
+			}
+		case "flags":
+			if v, err := strconv.ParseUint(val, 0, 8); err != nil {
+				return err
+			} else {
+				l.vlanFlags = uint8(v)
+				l.flagsSet = true
+			}
+		default:
+			return fmt.Errorf("unknown vxlan option: [%s]", opt)
+		}
+	}
+
+	if !l.flagsSet {
+		l.vlanFlags = VxLanValidVNIFlag
+	}
+
 	l.hdr.proto.name = l.Name()
 	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
-	l.hdr.proto.length = 0
+	l.hdr.proto.length = VxLanHeaderLen
 
 	l.hdr.fr.AddProtocol(&l.hdr.proto)
 
@@ -41,22 +103,114 @@ func (l *VxLanLayer) Parse(opts string) error {
 
 func (l *VxLanLayer) ApplyDefaults() error {
 
+	// The outer UDP destination port defaults to the VXLAN port after the UDP
+	// layer has applied the default frame values.
+	defer func() {
+		if udp, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerUDP).(*UDPLayer); ok && udp.udpHdr.DstPort == 0 {
+			udp.udpHdr.DstPort = VxLanPort
+		}
+	}()
+
 	d := l.hdr.fr.defaultsFrame
 	if d == nil {
 		return nil
 	}
 
-	dl, ok := d.GetLayer(LayerVxLan).(*VxLanLayer)
+	dl, ok := d.GetLayerIndex(LayerVxLan, l.hdr.index).(*VxLanLayer)
 	if !ok {
 		return nil
 	}
 
-	fmt.Printf("%s.ApplyDefaults: %T\n", l.Name(), dl)
+	if l.vni == 0 && dl.vni != 0 {
+		l.vni = dl.vni
+	}
+	if !l.flagsSet && dl.flagsSet {
+		l.vlanFlags = dl.vlanFlags
+	}
 
 	return nil
 }
 
 func (l *VxLanLayer) WriteLayer() error {
 
+	data := l.hdr.fr.frame
+
+	data.Append(uint32(l.vlanFlags) << 24)
+	data.Append((l.vni & VxLanMaxVNI) << 8)
+
 	return nil
 }
+
+// entropySpan returns the start and end offsets of the inner frame headers hashed for the
+// flow entropy value, which are the bytes between the VXLAN header and the payload.
+func (l *VxLanLayer) entropySpan() (uint16, uint16) {
+
+	fr := l.hdr.fr
+
+	start := fr.protoOffset(&l.hdr.proto) + VxLanHeaderLen
+	end := fr.protoOffset(nil)
+	if payload, ok := fr.outerLayer(nil, LayerPayload).(*PayloadLayer); ok {
+		if off := fr.protoOffset(&payload.hdr.proto); off > start {
+			end = off
+		}
+	}
+	return start, end
+}
+
+// updateSourcePort sets the outer UDP source port to a flow entropy value computed from
+// a hash of the inner frame headers, when the source port is not given. Must be called
+// after the inner frame is written and before the outer UDP checksum is computed.
+func (l *VxLanLayer) updateSourcePort() error {
+
+	fr := l.hdr.fr
+
+	udp, ok := fr.outerLayer(&l.hdr.proto, LayerUDP).(*UDPLayer)
+	if !ok || udp.udpHdr.SrcPort != 0 {
+		return nil
+	}
+
+	start, end := l.entropySpan()
+
+	h := fnv.New32a()
+	h.Write(fr.frame.Bytes()[start:end])
+	udp.udpHdr.SrcPort = uint16(VxLanMinSrcPort + h.Sum32()%VxLanSrcPortRange)
+	l.entropy = true
+
+	return fr.frame.WriteValueAt(int(fr.protoOffset(&udp.hdr.proto)), udp.udpHdr.SrcPort)
+}
+
+// decodeValid returns true if the data slice contains a VXLAN header with the valid VNI
+// flag set and the reserved fields zero, followed by an inner Ether header. The outer UDP
+// source port must be set, as a zero source port is replaced by the flow entropy value.
+func (l *VxLanLayer) decodeValid(data []byte) bool {
+
+	if len(data) < VxLanHeaderLen+EtherHeaderLen {
+		return false
+	}
+	if data[0] != VxLanValidVNIFlag || data[1] != 0 || data[2] != 0 || data[3] != 0 || data[7] != 0 {
+		return false
+	}
+
+	udp, ok := l.hdr.fr.outerLayer(&l.hdr.proto, LayerUDP).(*UDPLayer)
+	return ok && udp.udpHdr.SrcPort != 0
+}
+
+// Decode the VXLAN header from the binary frame data.
+func (l *VxLanLayer) Decode(data []byte) (int, error) {
+
+	if len(data) < VxLanHeaderLen {
+		return 0, fmt.Errorf("vxlan header too short: %d bytes", len(data))
+	}
+
+	l.vlanFlags = data[0]
+	l.flagsSet = true
+	l.vni = binary.BigEndian.Uint32(data[4:]) >> 8
+
+	l.hdr.proto.name = l.Name()
+	l.hdr.proto.offset = l.hdr.fr.GetOffset(l.Name())
+	l.hdr.proto.length = VxLanHeaderLen
+
+	l.hdr.fr.AddProtocol(&l.hdr.proto)
+
+	return VxLanHeaderLen, nil
+}